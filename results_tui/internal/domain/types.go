@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 const (
@@ -12,11 +13,13 @@ const (
 )
 
 type ResultFile struct {
-	Path           string
-	DirName        string
-	FileName       string
-	Temperature    string
-	RunLabel       string
+	Path        string
+	DirName     string
+	FileName    string
+	Temperature string
+	RunLabel    string
+	// Run parameters from run.json; nil for results written before it existed
+	Run            *RunInfo
 	Headers        []string
 	NumericColumns map[string]bool
 	ReadError      error
@@ -37,6 +40,34 @@ func (f ResultFile) Label() string {
 	return label
 }
 
+// Group returns the parameters shared by comparable runs; empty when the run parameters are unknown.
+func (f ResultFile) Group() string {
+	if f.Run == nil {
+		return ""
+	}
+	return f.Run.Group()
+}
+
+// RunInfo holds the parameters of a run recorded in its run.json.
+type RunInfo struct {
+	Temperature    int
+	SimulationTime float64
+	Seed           uint64
+	StartedAt      time.Time
+	StopReason     string
+	Elements       []string
+	MatrixLenX     int
+	MatrixLenY     int
+}
+
+func (r RunInfo) Group() string {
+	return fmt.Sprintf("%s %dx%d %dK", strings.Join(r.Elements, "+"), r.MatrixLenX, r.MatrixLenY, r.Temperature)
+}
+
+func (r RunInfo) Label() string {
+	return fmt.Sprintf("%s t=%g seed=%d %s", r.StartedAt.Format("2006-01-02 15:04:05"), r.SimulationTime, r.Seed, r.StopReason)
+}
+
 type ColumnOption struct {
 	Name    string
 	Source  map[int][]string
@@ -86,7 +86,7 @@ func buildLineChart(files []domain.ResultFile, graph domain.PlotConfig) (*charts
 
 	seriesCount := 0
 	for _, file := range files {
-		columns, err := results.ReadExcelColumns(file.Path, []string{graph.XAxis, graph.YAxis}, graph.MergeSuffix)
+		columns, err := results.ReadColumns(file.Path, []string{graph.XAxis, graph.YAxis}, graph.MergeSuffix)
 		if err != nil {
 			continue
 		}
@@ -0,0 +1,268 @@
+package results
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+	// Registers the pure Go "sqlite" driver
+	_ "modernc.org/sqlite"
+)
+
+// Extensions of the result tables written by the simulator, in order of preference
+// when a run has several of them.
+var tableExtensions = []string{".xlsx", ".csv", ".jsonl", ".sqlite"}
+
+func isTableFile(name string) bool {
+	return tableRank(name) >= 0
+}
+
+func tableRank(name string) int {
+	ext := strings.ToLower(filepath.Ext(name))
+	for i, candidate := range tableExtensions {
+		if ext == candidate {
+			return i
+		}
+	}
+	return -1
+}
+
+// readRows reads a result table as rows of strings, the first row being the header.
+func readRows(path string) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xlsx":
+		return readExcelRows(path)
+	case ".csv":
+		return readCSVRows(path)
+	case ".jsonl":
+		return readJSONLRows(path)
+	case ".sqlite":
+		return readSQLiteRows(path)
+	default:
+		return nil, fmt.Errorf("unsupported result format %s", filepath.Ext(path))
+	}
+}
+
+// Sheets and part files an xlsx table is split into once it outgrows a sheet.
+var (
+	excelSheetPattern = regexp.MustCompile(`^Sheet(\d+)$`)
+	excelPartPattern  = regexp.MustCompile(`_part\d+\.xlsx$`)
+	// CSV journal an xlsx table and its further tables are written to until the run closes them:
+	// result.xlsx.journal.csv and result.xlsx.<table>.journal.csv
+	excelJournalPattern = regexp.MustCompile(`\.xlsx(\.[^.]+)?\.journal\.csv$`)
+)
+
+// excelJournalSuffix ends the journal of the results table of a run, as opposed to those of its further tables.
+const excelJournalSuffix = ".xlsx.journal.csv"
+
+// isExcelJournal reports whether the file is the journal of an xlsx table rather than a result table.
+func isExcelJournal(name string) bool {
+	return excelJournalPattern.MatchString(strings.ToLower(name))
+}
+
+// excelJournalTable returns the xlsx table the file is the journal of, false unless it is the journal
+// of a results table.
+func excelJournalTable(name string) (string, bool) {
+	if !strings.HasSuffix(strings.ToLower(name), excelJournalSuffix) {
+		return "", false
+	}
+	return name[:len(name)-len(".journal.csv")], true
+}
+
+// isExcelPart reports whether the file continues the table of another xlsx file.
+func isExcelPart(name string) bool {
+	return excelPartPattern.MatchString(strings.ToLower(name))
+}
+
+func excelPartPath(path string, number int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s_part%d%s", strings.TrimSuffix(path, ext), number, ext)
+}
+
+// readExcelRows reads Sheet1, Sheet2, ... of the file and of its _partN files,
+// dropping the repeated header of every sheet after the first.
+func readExcelRows(path string) ([][]string, error) {
+	var rows [][]string
+	for number := 1; ; number++ {
+		partPath := path
+		if number > 1 {
+			partPath = excelPartPath(path, number)
+			if _, err := os.Stat(partPath); err != nil {
+				return rows, nil
+			}
+		}
+
+		sheets, err := readExcelSheets(partPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, sheet := range sheets {
+			if len(sheet) == 0 {
+				continue
+			}
+			if rows != nil {
+				sheet = sheet[1:]
+			}
+			rows = append(rows, sheet...)
+		}
+	}
+}
+
+func readExcelSheets(path string) ([][][]string, error) {
+	file, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	numbers := make(map[string]int)
+	var names []string
+	for _, name := range file.GetSheetList() {
+		match := excelSheetPattern.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+		numbers[name], _ = strconv.Atoi(match[1])
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return numbers[names[i]] < numbers[names[j]]
+	})
+
+	sheets := make([][][]string, 0, len(names))
+	for _, name := range names {
+		sheet, err := file.GetRows(name)
+		if err != nil {
+			return nil, err
+		}
+		sheets = append(sheets, sheet)
+	}
+	return sheets, nil
+}
+
+func readCSVRows(path string) ([][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	return reader.ReadAll()
+}
+
+func readJSONLRows(path string) ([][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rows [][]string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		keys, values, err := decodeJSONLine(scanner.Bytes())
+		if err != nil {
+			continue
+		}
+		if rows == nil {
+			rows = append(rows, keys)
+		}
+		rows = append(rows, values)
+	}
+	return rows, scanner.Err()
+}
+
+// decodeJSONLine decodes a flat JSON object keeping the order of its keys.
+func decodeJSONLine(line []byte) ([]string, []string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	if token != json.Delim('{') {
+		return nil, nil, errors.New("not an object")
+	}
+
+	var keys, values []string
+	for decoder.More() {
+		token, err = decoder.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key, ok := token.(string)
+		if !ok {
+			return nil, nil, errors.New("invalid key")
+		}
+
+		token, err = decoder.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		value := ""
+		switch v := token.(type) {
+		case json.Number:
+			value = v.String()
+		case string:
+			value = v
+		}
+
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+	return keys, values, nil
+}
+
+func readSQLiteRows(path string) ([][]string, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	result, err := db.Query("SELECT * FROM results ORDER BY rowid")
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	headers, err := result.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := [][]string{headers}
+	values := make([]sql.NullFloat64, len(headers))
+	pointers := make([]interface{}, len(headers))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for result.Next() {
+		if err = result.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		row := make([]string, len(headers))
+		for i, value := range values {
+			if value.Valid {
+				row[i] = strconv.FormatFloat(value.Float64, 'g', -1, 64)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, result.Err()
+}
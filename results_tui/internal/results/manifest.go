@@ -0,0 +1,59 @@
+package results
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"results_tui/internal/domain"
+)
+
+// manifestFileName is the run manifest the simulator writes next to the results.
+const manifestFileName = "run.json"
+
+type runManifest struct {
+	Temperature    int       `json:"temperature"`
+	SimulationTime float64   `json:"simulationTime"`
+	Seed           uint64    `json:"seed"`
+	StartedAt      time.Time `json:"startedAt"`
+	StopReason     string    `json:"stopReason"`
+	Config         struct {
+		Simulating struct {
+			MatrixLenX int `json:"matrixLenX"`
+			MatrixLenY int `json:"matrixLenY"`
+		} `json:"simulating"`
+		Elements []struct {
+			Name string `json:"name"`
+		} `json:"elements"`
+	} `json:"config"`
+}
+
+// readRunInfo reads the run manifest of a result directory. It returns nil when there is none.
+func readRunInfo(dirPath string) *domain.RunInfo {
+	content, err := os.ReadFile(filepath.Join(dirPath, manifestFileName))
+	if err != nil {
+		return nil
+	}
+
+	var manifest runManifest
+	if err = json.Unmarshal(content, &manifest); err != nil {
+		return nil
+	}
+
+	elements := make([]string, 0, len(manifest.Config.Elements))
+	for _, element := range manifest.Config.Elements {
+		elements = append(elements, element.Name)
+	}
+
+	return &domain.RunInfo{
+		Temperature:    manifest.Temperature,
+		SimulationTime: manifest.SimulationTime,
+		Seed:           manifest.Seed,
+		StartedAt:      manifest.StartedAt,
+		StopReason:     manifest.StopReason,
+		Elements:       elements,
+		MatrixLenX:     manifest.Config.Simulating.MatrixLenX,
+		MatrixLenY:     manifest.Config.Simulating.MatrixLenY,
+	}
+}
@@ -2,6 +2,7 @@ package results
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -61,21 +62,23 @@ func Scan(root string) []domain.ResultFile {
 			continue
 		}
 
-		for _, child := range children {
-			if child.IsDir() || !strings.EqualFold(filepath.Ext(child.Name()), ".xlsx") {
-				continue
-			}
-
-			path := filepath.Join(dirPath, child.Name())
+		run := readRunInfo(dirPath)
+		for _, name := range preferredTables(children) {
+			path := filepath.Join(dirPath, name)
 			result := domain.ResultFile{
 				Path:        path,
 				DirName:     entry.Name(),
-				FileName:    child.Name(),
-				Temperature: parseTemperature(entry.Name(), child.Name()),
-				RunLabel:    parseRunLabel(entry.Name(), child.Name()),
+				FileName:    name,
+				Temperature: parseTemperature(entry.Name(), name),
+				RunLabel:    parseRunLabel(entry.Name(), name),
+				Run:         run,
+			}
+			if run != nil {
+				result.Temperature = fmt.Sprintf("T%dK", run.Temperature)
+				result.RunLabel = run.Label()
 			}
 
-			headers, numeric, readErr := readExcelMetadata(path)
+			headers, numeric, readErr := readMetadata(path)
 			result.Headers = headers
 			result.NumericColumns = numeric
 			result.ReadError = readErr
@@ -83,12 +86,67 @@ func Scan(root string) []domain.ResultFile {
 		}
 	}
 
-	sort.Slice(files, func(i, j int) bool {
-		return files[i].Path < files[j].Path
+	// Runs with the same parameters are listed together, in the order they were started;
+	// runs without a manifest come last.
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if (a.Run == nil) != (b.Run == nil) {
+			return a.Run != nil
+		}
+		if a.Run != nil {
+			if a.Group() != b.Group() {
+				return a.Group() < b.Group()
+			}
+			if !a.Run.StartedAt.Equal(b.Run.StartedAt) {
+				return a.Run.StartedAt.Before(b.Run.StartedAt)
+			}
+		}
+		return a.Path < b.Path
 	})
 	return files
 }
 
+// preferredTables returns the result tables of a directory, keeping a single format
+// when the same run was written in several of them. A run that crashed or was killed before
+// closing its xlsx table left the rows in the CSV journal only, which is listed in its place.
+func preferredTables(children []os.DirEntry) []string {
+	present := make(map[string]bool, len(children))
+	for _, child := range children {
+		present[child.Name()] = true
+	}
+
+	best := make(map[string]string)
+	ranks := make(map[string]int)
+	for _, child := range children {
+		name := child.Name()
+		if child.IsDir() || isExcelPart(name) {
+			continue
+		}
+
+		base, rank := strings.TrimSuffix(name, filepath.Ext(name)), tableRank(name)
+		if isExcelJournal(name) {
+			table, ok := excelJournalTable(name)
+			if !ok || present[table] {
+				continue
+			}
+			base, rank = strings.TrimSuffix(table, filepath.Ext(table)), len(tableExtensions)
+		}
+		if rank < 0 {
+			continue
+		}
+		if current, ok := ranks[base]; !ok || rank < current {
+			best[base], ranks[base] = name, rank
+		}
+	}
+
+	names := make([]string, 0, len(best))
+	for _, name := range best {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func hasResultDirs(root string) bool {
 	entries, err := os.ReadDir(root)
 	if err != nil {
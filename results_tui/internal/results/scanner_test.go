@@ -0,0 +1,59 @@
+package results
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPreferredTablesJournals(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"result_T600K.xlsx",
+		"result_T600K.csv",
+		"result_T600K_part2.xlsx",
+		"result_T600K.xlsx.journal.csv",
+		"result_T600K.xlsx.mean_squared_displacement.journal.csv",
+		"result_T700K.xlsx.journal.csv",
+		"result_T800K.jsonl",
+		"run.json",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	children, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The journal of the T700K run stands for the xlsx table its crash left unwritten
+	want := []string{"result_T600K.xlsx", "result_T700K.xlsx.journal.csv", "result_T800K.jsonl"}
+	if got := preferredTables(children); !reflect.DeepEqual(got, want) {
+		t.Errorf("preferredTables = %v, want %v", got, want)
+	}
+}
+
+func TestScanReadsJournalOfUnclosedXlsx(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "result 2026-01-02 03_04_05 T600K")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	journal := "Simulation time,Surface coverage\n0,0\n1e-06,0.25\n"
+	if err := os.WriteFile(filepath.Join(dir, "result_T600K.xlsx.journal.csv"), []byte(journal), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	files := Scan(root)
+	if len(files) != 1 {
+		t.Fatalf("Scan found %d tables, want the journal", len(files))
+	}
+	if files[0].ReadError != nil {
+		t.Fatal(files[0].ReadError)
+	}
+	if want := []string{"Simulation time", "Surface coverage"}; !reflect.DeepEqual(files[0].Headers, want) {
+		t.Errorf("headers %v, want %v", files[0].Headers, want)
+	}
+}
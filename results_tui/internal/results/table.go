@@ -5,24 +5,16 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/xuri/excelize/v2"
-
 	"results_tui/internal/domain"
 )
 
-func readExcelMetadata(path string) ([]string, map[string]bool, error) {
-	file, err := excelize.OpenFile(path)
-	if err != nil {
-		return nil, nil, err
-	}
-	defer file.Close()
-
-	rows, err := file.GetRows("Sheet1")
+func readMetadata(path string) ([]string, map[string]bool, error) {
+	rows, err := readRows(path)
 	if err != nil {
 		return nil, nil, err
 	}
 	if len(rows) < 2 || len(rows[0]) == 0 {
-		return nil, nil, errors.New("Sheet1 has no data")
+		return nil, nil, errors.New("table has no data")
 	}
 
 	headers := make([]string, 0, len(rows[0]))
@@ -61,14 +53,8 @@ func readExcelMetadata(path string) ([]string, map[string]bool, error) {
 	return headers, numeric, metadataError(headers, numeric)
 }
 
-func ReadExcelColumns(path string, required []string, mergeSuffix bool) (map[string][]float64, error) {
-	file, err := excelize.OpenFile(path)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	rows, err := file.GetRows("Sheet1")
+func ReadColumns(path string, required []string, mergeSuffix bool) (map[string][]float64, error) {
+	rows, err := readRows(path)
 	if err != nil {
 		return nil, err
 	}
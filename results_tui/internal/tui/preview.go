@@ -22,7 +22,7 @@ func (m Model) previewChart(width, height int) (string, error) {
 	}
 
 	file := files[0]
-	columns, err := results.ReadExcelColumns(file.Path, []string{graph.XAxis, graph.YAxis}, graph.MergeSuffix)
+	columns, err := results.ReadColumns(file.Path, []string{graph.XAxis, graph.YAxis}, graph.MergeSuffix)
 	if err != nil {
 		return "", err
 	}
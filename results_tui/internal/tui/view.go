@@ -43,11 +43,19 @@ func (m Model) renderPanel(panelID panel, title, body string, width, height int)
 
 func (m Model) renderFiles(height int) string {
 	if len(m.files) == 0 {
-		return mutedStyle.Render("No .xlsx files found in result folders.")
+		return mutedStyle.Render("No result tables found in result folders.")
 	}
 
 	lines := make([]string, 0, len(m.files)+2)
+	group := ""
 	for i, file := range m.files {
+		if file.Group() != group {
+			group = file.Group()
+			if group != "" {
+				lines = append(lines, titleStyle.Render(group))
+			}
+		}
+
 		cursor := " "
 		if m.focus == panelFiles && i == m.fileCursor {
 			cursor = ">"
@@ -6,6 +6,8 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -75,7 +77,7 @@ func main() {
 				continue
 			}
 			for _, sub := range subEntries {
-				if !sub.IsDir() && strings.HasSuffix(sub.Name(), ".xlsx") {
+				if !sub.IsDir() && strings.HasSuffix(sub.Name(), ".xlsx") && !partFilePattern.MatchString(sub.Name()) {
 					excelFiles = append(excelFiles, filepath.Join(entry.Name(), sub.Name()))
 				}
 			}
@@ -129,14 +131,66 @@ func main() {
 	log.Printf("Successfully generated combined combined_results.html with data from %d files", len(allData))
 }
 
-func readExcel(exelFilePath string, graphicsToPlot []graphicToPlot) (map[string][]float64, error) {
-	file, err := excelize.OpenFile(exelFilePath)
-	if err != nil {
-		return nil, err
+// Sheets and part files a result table is split into once it outgrows a sheet.
+var (
+	sheetPattern    = regexp.MustCompile(`^Sheet(\d+)$`)
+	partFilePattern = regexp.MustCompile(`_part\d+\.xlsx$`)
+)
+
+// readExcelRows reads Sheet1, Sheet2, ... of the file and of its _partN files,
+// dropping the repeated header of every sheet after the first.
+func readExcelRows(exelFilePath string) ([][]string, error) {
+	var rows [][]string
+	for number := 1; ; number++ {
+		path := exelFilePath
+		if number > 1 {
+			path = fmt.Sprintf("%s_part%d.xlsx", strings.TrimSuffix(exelFilePath, ".xlsx"), number)
+			if _, err := os.Stat(path); err != nil {
+				return rows, nil
+			}
+		}
+
+		file, err := excelize.OpenFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		sheets := file.GetSheetList()
+		sort.Slice(sheets, func(i, j int) bool {
+			return sheetNumber(sheets[i]) < sheetNumber(sheets[j])
+		})
+		for _, sheet := range sheets {
+			if sheetNumber(sheet) < 0 {
+				continue
+			}
+			sheetRows, err := file.GetRows(sheet)
+			if err != nil {
+				file.Close()
+				return nil, err
+			}
+			if len(sheetRows) == 0 {
+				continue
+			}
+			if rows != nil {
+				sheetRows = sheetRows[1:]
+			}
+			rows = append(rows, sheetRows...)
+		}
+		file.Close()
+	}
+}
+
+func sheetNumber(name string) int {
+	match := sheetPattern.FindStringSubmatch(name)
+	if match == nil {
+		return -1
 	}
-	defer file.Close()
+	number, _ := strconv.Atoi(match[1])
+	return number
+}
 
-	rows, err := file.GetRows("Sheet1")
+func readExcel(exelFilePath string, graphicsToPlot []graphicToPlot) (map[string][]float64, error) {
+	rows, err := readExcelRows(exelFilePath)
 	if err != nil {
 		return nil, err
 	}
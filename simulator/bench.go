@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/zipliZ/surface-atoms/simulator/configs"
+	"github.com/zipliZ/surface-atoms/simulator/internal/progress"
+	"github.com/zipliZ/surface-atoms/simulator/internal/simulation"
+	"log/slog"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Physical time of a benchmark case; long enough that the case always ends on its wall-clock budget.
+const benchSimulationTime = 1e3
+
+// runBench handles "bench": it measures the events per second of the simulation loop for every
+// combination of lattice size and number of elements, in memory and for a fixed wall-clock time.
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	var cfgFlags configFlags
+	cfgFlags.register(fs)
+	sizes := fs.String("sizes", "50,200,1000", "lattice sizes, every size N is run on an NxN lattice")
+	elementCounts := fs.String("elements", "1,2", "numbers of elements, taken in order from the config")
+	duration := fs.Duration("duration", 3*time.Second, "wall-clock time of every case")
+	temperature := fs.Int("temperature", 600, "surface temperature in Kelvin")
+	seed := fs.Uint64("seed", 1, "seed of every case")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, _, err := configs.Load(cfgFlags.sources())
+	if err != nil {
+		return err
+	}
+	sizeList, err := parseInts(*sizes)
+	if err != nil {
+		return fmt.Errorf("-sizes: %w", err)
+	}
+	countList, err := parseInts(*elementCounts)
+	if err != nil {
+		return fmt.Errorf("-elements: %w", err)
+	}
+
+	slog.SetLogLoggerLevel(slog.LevelError)
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "lattice\telements\tevents\tevents/s\tns/event\tallocs/event\tcoverage\tlattice MiB\tatoms MiB\t")
+	for _, size := range sizeList {
+		for _, count := range countList {
+			if count < 1 || count > len(cfg.Elements) {
+				return fmt.Errorf("the config has %d elements, cannot run %d", len(cfg.Elements), count)
+			}
+
+			caseCfg := cfg
+			caseCfg.Elements = cfg.Elements[:count]
+			caseCfg.Simulating.MatrixLenX = size
+			caseCfg.Simulating.MatrixLenY = size
+			caseCfg.Simulating.Seed = *seed
+			caseCfg.Simulating.StopOnQuasiSteady = false
+			caseCfg.Simulating.Progress.Output = ""
+			caseCfg.Simulating.Progress.Bar = progress.BarOff
+
+			result, elapsed, allocs, err := benchCase(caseCfg, *temperature, *duration)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(table, "%dx%d\t%d\t%d\t%.0f\t%.0f\t%.2f\t%.3f\t%.1f\t%.1f\t\n",
+				size, size, count, result.Events,
+				float64(result.Events)/elapsed.Seconds(),
+				float64(elapsed.Nanoseconds())/float64(result.Events),
+				float64(allocs)/float64(result.Events),
+				result.Total.Density,
+				float64(result.Memory.LatticeBytes)/(1<<20),
+				float64(result.Memory.AtomsBytes)/(1<<20))
+		}
+	}
+
+	return table.Flush()
+}
+
+// benchCase runs the simulation loop for the given wall-clock time and returns the result, the time spent
+// in the loop and the number of heap allocations made by it.
+func benchCase(cfg configs.Config, temperature int, duration time.Duration) (simulation.Result, time.Duration, uint64, error) {
+	simulator, err := simulation.NewSimulator(cfg, temperature, benchSimulationTime, simulation.WithoutFiles())
+	if err != nil {
+		return simulation.Result{}, 0, 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	err = simulator.Simulate(ctx)
+
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	if err != nil {
+		return simulation.Result{}, 0, 0, err
+	}
+
+	return simulator.Result(), elapsed, after.Mallocs - before.Mallocs, nil
+}
+
+func parseInts(list string) ([]int, error) {
+	var values []int
+	for _, field := range strings.Split(list, ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/zipliZ/surface-atoms/simulator/configs"
+	"github.com/zipliZ/surface-atoms/simulator/internal/progress"
+	"github.com/zipliZ/surface-atoms/simulator/internal/simulation"
+	"log/slog"
+	"math"
+	"os"
+	"slices"
+	"text/tabwriter"
+)
+
+// runCheckParallel handles "check-parallel": it runs the same config with the serial algorithm and in
+// parallel mode, with a different seed every run, and compares the final coverages and counters of every
+// element. The difference of the means of every quantity is tested with Welch's t-test, and the p-values
+// of all quantities with the Holm-Bonferroni method: the command fails when one quantity differs, and
+// does so for algorithms that agree with a probability of at most -alpha, whatever the number of
+// quantities compared.
+func runCheckParallel(args []string) error {
+	fs := flag.NewFlagSet("check-parallel", flag.ExitOnError)
+	var cfgFlags configFlags
+	cfgFlags.register(fs)
+	runs := fs.Int("runs", 8, "number of runs of every algorithm")
+	domains := fs.Int("domains", 2, "number of domains of the parallel runs")
+	simulationTime := fs.Float64("time", 1e-6, "physical time of every run in seconds")
+	temperature := fs.Int("temperature", 600, "surface temperature in Kelvin")
+	seed := fs.Uint64("seed", 1, "seed of the first run, the next runs take the following seeds")
+	alpha := fs.Float64("alpha", 0.01, "probability that the check fails although the algorithms agree")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, _, err := configs.Load(cfgFlags.sources())
+	if err != nil {
+		return err
+	}
+	if *runs < 2 {
+		return fmt.Errorf("-runs: at least 2 runs are needed, got %d", *runs)
+	}
+	if *alpha <= 0 || *alpha >= 1 {
+		return fmt.Errorf("-alpha: must be between 0 and 1, got %g", *alpha)
+	}
+	if *domains < 2 {
+		return fmt.Errorf("-domains: parallel mode needs at least 2 domains, got %d", *domains)
+	}
+	cfg.Simulating.StopOnQuasiSteady = false
+	cfg.Simulating.Progress.Output = ""
+	cfg.Simulating.Progress.Bar = progress.BarOff
+
+	slog.SetLogLoggerLevel(slog.LevelError)
+
+	var serial, parallel []map[string]float64
+	for i := range *runs {
+		runCfg := cfg
+		runCfg.Simulating.Seed = *seed + uint64(i)
+
+		runCfg.Simulating.Parallel.Domains = 0
+		result, err := checkParallelRun(runCfg, *temperature, *simulationTime)
+		if err != nil {
+			return err
+		}
+		serial = append(serial, result)
+
+		runCfg.Simulating.Parallel.Domains = *domains
+		if result, err = checkParallelRun(runCfg, *temperature, *simulationTime); err != nil {
+			return err
+		}
+		parallel = append(parallel, result)
+	}
+
+	type comparison struct {
+		quantity                       string
+		serialMean, serialVariance     float64
+		parallelMean, parallelVariance float64
+		t, p                           float64
+		differs                        bool
+	}
+	var comparisons []*comparison
+	for _, element := range cfg.Elements {
+		for _, name := range checkParallelQuantities {
+			c := &comparison{quantity: element.Name + " " + name}
+			c.serialMean, c.serialVariance = meanVariance(serial, c.quantity)
+			c.parallelMean, c.parallelVariance = meanVariance(parallel, c.quantity)
+			c.t, c.p = welchTest(c.serialMean, c.serialVariance, c.parallelMean, c.parallelVariance, *runs)
+			comparisons = append(comparisons, c)
+		}
+	}
+
+	// Holm-Bonferroni: the k-th smallest of m p-values is compared with alpha/(m-k) until one is larger
+	byP := slices.Clone(comparisons)
+	slices.SortStableFunc(byP, func(a, b *comparison) int { return cmp.Compare(a.p, b.p) })
+	disagreeing := 0
+	for k, c := range byP {
+		if c.p > *alpha/float64(len(byP)-k) {
+			break
+		}
+		c.differs = true
+		disagreeing++
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "quantity\tserial mean\tserial sd\tparallel mean\tparallel sd\tt\tp\t")
+	for _, c := range comparisons {
+		mark := ""
+		if c.differs {
+			mark = " *"
+		}
+		fmt.Fprintf(table, "%s\t%.6g\t%.3g\t%.6g\t%.3g\t%.2f\t%.2g%s\t\n", c.quantity,
+			c.serialMean, math.Sqrt(c.serialVariance), c.parallelMean, math.Sqrt(c.parallelVariance), c.t, c.p, mark)
+	}
+	if err = table.Flush(); err != nil {
+		return err
+	}
+
+	if disagreeing > 0 {
+		return fmt.Errorf("%d quantities differ at a false-alarm rate of %g", disagreeing, *alpha)
+	}
+	fmt.Printf("parallel mode with %d domains agrees with the serial algorithm\n", *domains)
+	return nil
+}
+
+var checkParallelQuantities = []string{"coverage", "adsorbed", "desorbed", "recomb Er", "recomb Lh F", "recomb Lh S"}
+
+// checkParallelRun runs the config in memory and returns the quantities compared by check-parallel.
+func checkParallelRun(cfg configs.Config, temperature int, simulationTime float64) (map[string]float64, error) {
+	simulator, err := simulation.NewSimulator(cfg, temperature, simulationTime, simulation.WithoutFiles())
+	if err != nil {
+		return nil, err
+	}
+	if err = simulator.Simulate(context.Background()); err != nil {
+		return nil, err
+	}
+
+	quantities := make(map[string]float64)
+	for name, info := range simulator.Result().Elements {
+		quantities[name+" coverage"] = info.Density
+		quantities[name+" adsorbed"] = float64(info.AdsorbedAtoms)
+		quantities[name+" desorbed"] = float64(info.DesorbedAtoms)
+		quantities[name+" recomb Er"] = info.RecombEr
+		quantities[name+" recomb Lh F"] = info.RecombLhF
+		quantities[name+" recomb Lh S"] = info.RecombLhS
+	}
+	return quantities, nil
+}
+
+// meanVariance returns the mean and the sample variance of the quantity over the runs.
+func meanVariance(runs []map[string]float64, quantity string) (float64, float64) {
+	mean := 0.0
+	for _, run := range runs {
+		mean += run[quantity]
+	}
+	mean /= float64(len(runs))
+
+	variance := 0.0
+	for _, run := range runs {
+		variance += (run[quantity] - mean) * (run[quantity] - mean)
+	}
+	return mean, variance / float64(len(runs)-1)
+}
+
+// welchTest returns Welch's t statistic of the difference of two means over the same number of runs and
+// its two-sided p-value. Quantities without variance differ with p 0 unless their means are equal.
+func welchTest(mean1, variance1, mean2, variance2 float64, runs int) (float64, float64) {
+	a, b := variance1/float64(runs), variance2/float64(runs)
+	if a+b == 0 {
+		if mean1 == mean2 {
+			return 0, 1
+		}
+		return math.Inf(1), 0
+	}
+
+	t := (mean2 - mean1) / math.Sqrt(a+b)
+	// Welch-Satterthwaite degrees of freedom
+	df := (a + b) * (a + b) / ((a*a + b*b) / float64(runs-1))
+	return t, regularizedBeta(df/(df+t*t), df/2, 0.5)
+}
+
+// regularizedBeta returns the regularized incomplete beta function I_x(a, b), evaluated by its continued
+// fraction (Numerical Recipes, 6.4).
+func regularizedBeta(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lgammaAB, _ := math.Lgamma(a + b)
+	lgammaA, _ := math.Lgamma(a)
+	lgammaB, _ := math.Lgamma(b)
+	front := math.Exp(lgammaAB - lgammaA - lgammaB + a*math.Log(x) + b*math.Log(1-x))
+	// The continued fraction converges quickly below (a+1)/(a+b+2); above it, I_x(a, b) = 1 - I_1-x(b, a)
+	if x > (a+1)/(a+b+2) {
+		return 1 - front*betaFraction(1-x, b, a)/b
+	}
+	return front * betaFraction(x, a, b) / a
+}
+
+// betaFraction evaluates the continued fraction of the incomplete beta function by Lentz's method.
+func betaFraction(x, a, b float64) float64 {
+	const (
+		maxIterations = 300
+		epsilon       = 1e-15
+		tiny          = 1e-300
+	)
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	fraction := d
+	for m := 1; m <= maxIterations; m++ {
+		m2 := float64(2 * m)
+		// Even step
+		numerator := float64(m) * (b - float64(m)) * x / ((a + m2 - 1) * (a + m2))
+		d = 1 + numerator*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + numerator/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		fraction *= d * c
+		// Odd step
+		numerator = -(a + float64(m)) * (a + b + float64(m)) * x / ((a + m2) * (a + m2 + 1))
+		d = 1 + numerator*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + numerator/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		fraction *= delta
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return fraction
+}
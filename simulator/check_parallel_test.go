@@ -0,0 +1,29 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWelchTestPValue(t *testing.T) {
+	// Two-sided p-values of Student's t distribution
+	for _, c := range []struct{ t, df, p float64 }{
+		{2.145, 14, 0.05},
+		{2.977, 14, 0.01},
+		{1.96, 1e6, 0.05},
+		{0, 5, 1},
+	} {
+		if p := regularizedBeta(c.df/(c.df+c.t*c.t), c.df/2, 0.5); math.Abs(p-c.p) > 5e-4 {
+			t.Errorf("t %g with %g degrees of freedom: p %g, want %g", c.t, c.df, p, c.p)
+		}
+	}
+
+	// Equal variances over 8 runs give 14 degrees of freedom
+	tStat, p := welchTest(0, 1, 1.0725, 1, 8)
+	if math.Abs(tStat-2.145) > 1e-3 || math.Abs(p-0.05) > 5e-4 {
+		t.Errorf("welchTest = %g, %g, want 2.145, 0.05", tStat, p)
+	}
+	if _, p = welchTest(3, 0, 3, 0, 8); p != 1 {
+		t.Errorf("constant equal quantities have p %g", p)
+	}
+}
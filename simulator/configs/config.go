@@ -1,15 +1,23 @@
 package configs
 
 import (
-	"github.com/knadh/koanf/parsers/yaml"
-	"github.com/knadh/koanf/providers/file"
+	"fmt"
+	"maps"
+	"slices"
+
 	"github.com/knadh/koanf/v2"
 )
 
 type Config struct {
 	Simulating Simulating `json:"simulating"`
 	Constants  Constants  `json:"consts"`
-	Elements   []Element  `json:"elements"`
+	// Gas phase by pressure and composition; agDensity and the surface temperature are used when omitted
+	Gas *Gas `json:"gas,omitempty"`
+	// Unit of element energies given as plain numbers; always J/mol once loaded
+	EnergyUnit string `json:"energyUnit"`
+	// Directory of element preset YAML files, relative to the config file; the bundled library is always available
+	PresetsDir string    `json:"presetsDir,omitempty"`
+	Elements   []Element `json:"elements"`
 }
 
 type Simulating struct {
@@ -23,6 +31,92 @@ type Simulating struct {
 	StopOnQuasiSteady    bool             `json:"stopOnQuasiSteady"`
 	RequiredStableChecks int              `json:"requiredStableChecks"`
 	CheckParameters      []CheckParameter `json:"checkParameters"` // ["density", "densityF", "densityS"]
+	// Directory the result directories are created in; the working directory when omitted
+	OutputDir string `json:"outputDir"`
+	// Output backends of the results table: "xlsx", "csv", "jsonl", "sqlite"; xlsx when omitted
+	Outputs []string `json:"outputs"`
+	// Rows per xlsx sheet including the header, at most and by default 1048576
+	XlsxRowLimit int `json:"xlsxRowLimit"`
+	// Where an xlsx table continues once a sheet is full: "sheets" (Sheet2, ...) or "files" (_part2.xlsx, ...)
+	XlsxRollover string `json:"xlsxRollover"`
+	// What happens to an atom whose hop is blocked by an occupied cell: "desorb", "reject" or "retry"
+	BlockedHopPolicy string `json:"blockedHopPolicy"`
+	// Write checkpoint.json.gz next to the results when the run is interrupted, to resume it with -resume
+	CheckpointOnInterrupt bool `json:"checkpointOnInterrupt"`
+	// Write every executed event to events.bin next to the results, with the state the run starts from
+	// in events_start.json.gz, to replay the run with the replay command
+	EventLog bool `json:"eventLog"`
+	// Seed of the random number generator; a random seed is drawn and recorded in run.json when 0
+	Seed uint64 `json:"seed"`
+	// Machine-readable progress reporting
+	Progress Progress `json:"progress"`
+	// Parallel simulation of large lattices split into domains
+	Parallel Parallel `json:"parallel"`
+	// Scaling down of the hop rate while hops are much more frequent than all other events
+	Acceleration Acceleration `json:"acceleration"`
+	// Occupancy maps of the lattice written during the run
+	Snapshots Snapshots `json:"snapshots"`
+	// Spatial statistics of the atoms computed at every row of the results
+	Spatial Spatial `json:"spatial"`
+	// Collect the residence times of the atoms by element and exit channel
+	ResidenceTimes bool `json:"residenceTimes"`
+	// Estimate the tracer diffusion coefficient of every element from the displacements of its atoms
+	TracerDiffusion bool `json:"tracerDiffusion"`
+	// Write the rate of every process and the times it was chosen at every row of the results,
+	// and the share of every process in the events and the wall time of the run
+	ProcessBudget bool `json:"processBudget"`
+}
+
+type Spatial struct {
+	Enabled bool `json:"enabled"`
+	// Largest distance of the pair correlation and of the distances to S-centers in cells; 10 when 0
+	MaxDistance float64 `json:"maxDistance"`
+	// Width of the distance bins in cells; 1 when 0
+	BinWidth float64 `json:"binWidth"`
+}
+
+type Snapshots struct {
+	// Physical times in seconds to write a map at
+	Times []float64 `json:"times"`
+	// Percentage of the simulation time between two maps, from the start of the run; off when 0
+	Percent float64 `json:"percent"`
+	// Formats of every map: "png", "csv", "npy"; png when omitted
+	Formats []string `json:"formats"`
+	// Animations of all maps of the run written at its end: "gif", "html"; none when omitted
+	Animations []string `json:"animations"`
+	// Side of a cell in the images in pixels; 4 when 0
+	CellPixels int `json:"cellPixels"`
+	// Time every map is shown for in the animations in hundredths of a second; 20 when 0
+	FrameDelay int `json:"frameDelay"`
+}
+
+type Acceleration struct {
+	Enabled bool `json:"enabled"`
+	// Number of events after which the hops are compared with the other events and the scales adjusted; 10000 when 0
+	Window int `json:"window"`
+	// Hops of an element per other event kept at least while its hops are scaled; larger values give
+	// a smaller error and a smaller acceleration; 100 when 0
+	Separation float64 `json:"separation"`
+	// Factor the hop rate is scaled down or back up by at a time; 2 when 0
+	Step float64 `json:"step"`
+}
+
+type Parallel struct {
+	// Number of domains simulated by separate goroutines, every one made of two strips of rows
+	// simulated in turn; 0 or 1 runs the serial algorithm
+	Domains int `json:"domains"`
+	// Physical time every strip is simulated for between two synchronisations, in seconds;
+	// the mean time between events on a single site when 0
+	CycleTime float64 `json:"cycleTime"`
+}
+
+type Progress struct {
+	// JSON-lines progress stream: "stdout" or a file path; disabled when empty
+	Output string `json:"output"`
+	// Minimal wall time between reports in seconds, 1 when omitted
+	Interval float64 `json:"interval"`
+	// Progress bar on stderr: "auto" (when it is a terminal), "on" or "off"
+	Bar string `json:"bar"`
 }
 
 type CheckParameter struct {
@@ -32,6 +126,8 @@ type CheckParameter struct {
 }
 
 type Element struct {
+	// Preset the parameters were taken from, e.g. "O@silica"; fields given next to it override the preset
+	Preset            string  `json:"preset,omitempty"`
 	Name              string  `json:"name"`
 	Mass              float64 `json:"mass"`
 	Edes              float64 `json:"edes"`
@@ -45,6 +141,46 @@ type Element struct {
 	ErlhSHet          float64 `json:"erlhshet"`
 	AgDensity         float64 `json:"agDensity"`
 	Electronegativity float64 `json:"electronegativity"`
+	// Rate model per process ("desorption", "diffusion"); plain Arrhenius when omitted
+	RateModels map[string]RateModel `json:"rateModels,omitempty"`
+}
+
+type RateModel struct {
+	// "arrhenius", "modifiedArrhenius" or "tst"
+	Model string `json:"model"`
+	// Temperature exponent n of the modified Arrhenius form A·T^n·exp(-E/RT)
+	N float64 `json:"n"`
+}
+
+// Rate models of the processes with a prefactor.
+const (
+	RateModelArrhenius         = "arrhenius"
+	RateModelModifiedArrhenius = "modifiedArrhenius"
+	RateModelTST               = "tst"
+)
+
+// Processes whose rate model can be configured.
+const (
+	RateProcessDesorption = "desorption"
+	RateProcessDiffusion  = "diffusion"
+)
+
+// ValidateRateModels checks that the rate models of the element are given for known processes and name
+// known models, so that a misspelt key is not silently left to the Arrhenius default.
+func (e Element) ValidateRateModels() error {
+	for _, process := range slices.Sorted(maps.Keys(e.RateModels)) {
+		if process != RateProcessDesorption && process != RateProcessDiffusion {
+			return fmt.Errorf("element %s: unknown process %q in rateModels, expected %q or %q",
+				e.Name, process, RateProcessDesorption, RateProcessDiffusion)
+		}
+		switch model := e.RateModels[process].Model; model {
+		case "", RateModelArrhenius, RateModelModifiedArrhenius, RateModelTST:
+		default:
+			return fmt.Errorf("element %s: unknown rate model %q for %s, expected %q, %q or %q",
+				e.Name, model, process, RateModelArrhenius, RateModelModifiedArrhenius, RateModelTST)
+		}
+	}
+	return nil
 }
 
 type GraphicToPlot struct {
@@ -57,17 +193,47 @@ type Constants struct {
 	SDensity float64 `json:"sDensity"`
 }
 
-func New() (Config, error) {
-	k := koanf.New(".")
+// Load merges the config layers, normalises element energies and resolves element presets.
+// It also returns where every value came from.
+func Load(sources Sources) (Config, Origins, error) {
+	k, origins, err := sources.merge()
+	if err != nil {
+		return Config{}, nil, err
+	}
+
+	if elements, ok := k.Get("elements").([]interface{}); ok {
+		if err = normalizeEnergies(elements, k.String("energyUnit")); err != nil {
+			return Config{}, nil, err
+		}
+
+		baseFile := ""
+		if len(sources.Files) > 0 {
+			baseFile = sources.Files[0]
+		}
+		presets, err := LoadPresets(presetsDir(baseFile, k.String("presetsDir")))
+		if err != nil {
+			return Config{}, nil, err
+		}
+		if err = presets.resolvePresets(elements); err != nil {
+			return Config{}, nil, err
+		}
 
-	if err := k.Load(file.Provider("./../config.yaml"), yaml.Parser()); err != nil {
-		return Config{}, err
+		if err = k.Set("elements", elements); err != nil {
+			return Config{}, nil, err
+		}
 	}
 
 	var configStruct Config
-	if err := k.UnmarshalWithConf("", &configStruct, koanf.UnmarshalConf{Tag: "json"}); err != nil {
-		return Config{}, err
+	if err = k.UnmarshalWithConf("", &configStruct, koanf.UnmarshalConf{Tag: "json"}); err != nil {
+		return Config{}, nil, err
 	}
+	configStruct.EnergyUnit = EnergyUnitJoulePerMol
 
-	return configStruct, nil
+	return configStruct, origins, nil
+}
+
+// New loads the default config file with environment overrides.
+func New() (Config, error) {
+	cfg, _, err := Load(DefaultSources())
+	return cfg, err
 }
@@ -0,0 +1,26 @@
+package configs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateRateModels(t *testing.T) {
+	for _, c := range []struct {
+		models map[string]RateModel
+		err    string
+	}{
+		{nil, ""},
+		{map[string]RateModel{"desorption": {Model: "tst"}, "diffusion": {Model: "modifiedArrhenius", N: 1}}, ""},
+		{map[string]RateModel{"desorbtion": {Model: "tst"}}, `unknown process "desorbtion"`},
+		{map[string]RateModel{"diffusion": {Model: "TST"}}, `unknown rate model "TST"`},
+	} {
+		err := Element{Name: "N", RateModels: c.models}.ValidateRateModels()
+		if c.err == "" && err != nil {
+			t.Errorf("%v: %v", c.models, err)
+		}
+		if c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)) {
+			t.Errorf("%v: error %v, want %q", c.models, err, c.err)
+		}
+	}
+}
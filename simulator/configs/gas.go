@@ -0,0 +1,96 @@
+package configs
+
+import "fmt"
+
+// Gas describes the gas phase by total pressure, gas temperature and composition.
+// When it is set, atom fluxes are calculated from it instead of the element agDensity and the surface temperature.
+type Gas struct {
+	// Total pressure, Pa
+	Pressure float64 `json:"pressure"`
+	// Gas temperature, K
+	Temperature float64 `json:"temperature"`
+	// Mole fractions of atoms by element name, in the gas before the molecules of Dissociation dissociate
+	MoleFractions map[string]float64 `json:"moleFractions,omitempty"`
+	// Dissociation of X2 molecules by element name, for elements without a mole fraction
+	Dissociation map[string]Dissociation `json:"dissociation,omitempty"`
+}
+
+type Dissociation struct {
+	// Mole fraction of the X2 molecule before dissociation
+	MoleculeFraction float64 `json:"moleculeFraction"`
+	// Degree of dissociation, 0..1
+	Degree float64 `json:"degree"`
+}
+
+// MoleFraction returns the mole fraction of atoms of the element in the gas once the molecules have
+// dissociated. Every dissociated molecule adds one particle to the gas, which then holds 1 + Σαy moles
+// per mole before dissociation: the fraction is x / (1 + Σαy) for an element given by its mole fraction x
+// and 2αy / (1 + Σαy) for a dissociated element.
+func (g Gas) MoleFraction(elementName string) (float64, error) {
+	if fraction, ok := g.MoleFractions[elementName]; ok {
+		return fraction / g.totalMoles(), nil
+	}
+
+	dissociation, ok := g.Dissociation[elementName]
+	if !ok {
+		return 0, fmt.Errorf("element %s is not in the gas composition", elementName)
+	}
+
+	return 2 * dissociation.Degree * dissociation.MoleculeFraction / g.totalMoles(), nil
+}
+
+// totalMoles returns the moles of the gas after dissociation per mole before it.
+func (g Gas) totalMoles() float64 {
+	totalMoles := 1.0
+	for _, d := range g.Dissociation {
+		totalMoles += d.Degree * d.MoleculeFraction
+	}
+	return totalMoles
+}
+
+// PartialPressure returns the partial pressure of atoms of the element, Pa.
+func (g Gas) PartialPressure(elementName string) (float64, error) {
+	fraction, err := g.MoleFraction(elementName)
+	if err != nil {
+		return 0, err
+	}
+
+	return fraction * g.Pressure, nil
+}
+
+// Validate checks that the gas block is physically meaningful: the mole fractions of the atoms and of
+// the molecules before dissociation sum to at most 1, so that those after it do as well.
+func (g Gas) Validate() error {
+	if g.Pressure <= 0 {
+		return fmt.Errorf("gas pressure must be positive, got %g", g.Pressure)
+	}
+	if g.Temperature <= 0 {
+		return fmt.Errorf("gas temperature must be positive, got %g", g.Temperature)
+	}
+
+	total := 0.0
+	for name, fraction := range g.MoleFractions {
+		if fraction < 0 || fraction > 1 {
+			return fmt.Errorf("mole fraction of %s must be within [0, 1], got %g", name, fraction)
+		}
+		total += fraction
+	}
+
+	for name, d := range g.Dissociation {
+		if _, ok := g.MoleFractions[name]; ok {
+			return fmt.Errorf("%s has both a mole fraction and a dissociation", name)
+		}
+		if d.Degree < 0 || d.Degree > 1 {
+			return fmt.Errorf("degree of dissociation of %s must be within [0, 1], got %g", name, d.Degree)
+		}
+		if d.MoleculeFraction < 0 || d.MoleculeFraction > 1 {
+			return fmt.Errorf("molecule fraction of %s must be within [0, 1], got %g", name, d.MoleculeFraction)
+		}
+		total += d.MoleculeFraction
+	}
+	if total > 1 {
+		return fmt.Errorf("mole fractions of atoms and molecules sum to %g, more than 1", total)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package configs
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGasMoleFraction(t *testing.T) {
+	gas := Gas{
+		Pressure:      100,
+		Temperature:   1500,
+		MoleFractions: map[string]float64{"N": 0.01},
+		Dissociation:  map[string]Dissociation{"O": {MoleculeFraction: 0.21, Degree: 0.1}},
+	}
+	if err := gas.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 0.021 moles of O2 dissociate into 0.042 moles of O: 1.021 moles in all
+	for name, want := range map[string]float64{"N": 0.01 / 1.021, "O": 0.042 / 1.021} {
+		fraction, err := gas.MoleFraction(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(fraction-want) > 1e-12 {
+			t.Errorf("mole fraction of %s = %g, want %g", name, fraction, want)
+		}
+		pressure, err := gas.PartialPressure(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(pressure-want*100) > 1e-10 {
+			t.Errorf("partial pressure of %s = %g, want %g", name, pressure, want*100)
+		}
+	}
+
+	if _, err := gas.MoleFraction("Ar"); err == nil {
+		t.Error("an element outside the composition has a mole fraction")
+	}
+}
+
+func TestGasValidate(t *testing.T) {
+	for name, gas := range map[string]Gas{
+		"no pressure":       {Temperature: 300},
+		"negative fraction": {Pressure: 1, Temperature: 300, MoleFractions: map[string]float64{"N": -0.1}},
+		"atoms and molecules above 1": {Pressure: 1, Temperature: 300,
+			MoleFractions: map[string]float64{"N": 0.5},
+			Dissociation:  map[string]Dissociation{"O": {MoleculeFraction: 0.6, Degree: 0.5}}},
+		"fraction and dissociation": {Pressure: 1, Temperature: 300,
+			MoleFractions: map[string]float64{"O": 0.1},
+			Dissociation:  map[string]Dissociation{"O": {MoleculeFraction: 0.1, Degree: 0.5}}},
+		"degree above 1": {Pressure: 1, Temperature: 300,
+			Dissociation: map[string]Dissociation{"O": {MoleculeFraction: 0.1, Degree: 1.5}}},
+	} {
+		if err := gas.Validate(); err == nil {
+			t.Errorf("%s: the gas is valid", name)
+		}
+	}
+}
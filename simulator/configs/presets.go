@@ -0,0 +1,127 @@
+package configs
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/knadh/koanf/parsers/yaml"
+)
+
+// bundledPresets is the built-in element library.
+//
+//go:embed presets/*.yaml
+var bundledPresets embed.FS
+
+// Presets maps a preset name such as "O@silica" to element parameters with energies in J/mol.
+type Presets map[string]map[string]interface{}
+
+// LoadPresets reads the bundled element library and then the YAML files in dir.
+// Presets from dir override bundled presets of the same name. An empty dir loads only the bundled library.
+func LoadPresets(dir string) (Presets, error) {
+	presets := make(Presets)
+
+	bundled, err := fs.Sub(bundledPresets, "presets")
+	if err != nil {
+		return nil, err
+	}
+	if err = presets.loadDir(bundled); err != nil {
+		return nil, err
+	}
+
+	if dir == "" {
+		return presets, nil
+	}
+	if err = presets.loadDir(os.DirFS(dir)); err != nil {
+		return nil, fmt.Errorf("presets dir %s: %w", dir, err)
+	}
+
+	return presets, nil
+}
+
+func (p Presets) loadDir(dir fs.FS) error {
+	files, err := fs.Glob(dir, "*.yaml")
+	if err != nil {
+		return err
+	}
+
+	for _, fileName := range files {
+		content, err := fs.ReadFile(dir, fileName)
+		if err != nil {
+			return err
+		}
+		if err = p.load(content); err != nil {
+			return fmt.Errorf("%s: %w", fileName, err)
+		}
+	}
+
+	return nil
+}
+
+// load parses a preset file: an optional energyUnit and a presets map of element parameters.
+func (p Presets) load(content []byte) error {
+	file, err := yaml.Parser().Unmarshal(content)
+	if err != nil {
+		return err
+	}
+
+	energyUnit, _ := file["energyUnit"].(string)
+	presets, _ := file["presets"].(map[string]interface{})
+	for name, rawPreset := range presets {
+		preset, ok := rawPreset.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("preset %s is not a map", name)
+		}
+		if err = normalizeEnergies([]interface{}{preset}, energyUnit); err != nil {
+			return fmt.Errorf("preset %s: %w", name, err)
+		}
+		p[name] = preset
+	}
+
+	return nil
+}
+
+// resolvePresets replaces every element with a preset key by the preset parameters
+// overridden with the fields given in the element itself.
+func (p Presets) resolvePresets(elements []interface{}) error {
+	for i, rawElement := range elements {
+		element, ok := rawElement.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		name, ok := element["preset"].(string)
+		if !ok {
+			continue
+		}
+		preset, ok := p[name]
+		if !ok {
+			return fmt.Errorf("elements[%d]: unknown preset %q, known presets: %s",
+				i, name, strings.Join(slices.Sorted(maps.Keys(p)), ", "))
+		}
+
+		resolved := make(map[string]interface{}, len(preset)+len(element))
+		for key, value := range preset {
+			resolved[key] = value
+		}
+		for key, value := range element {
+			resolved[key] = value
+		}
+		elements[i] = resolved
+	}
+
+	return nil
+}
+
+// presetsDir resolves a presets directory relative to the directory of the config file.
+func presetsDir(configFile string, dir string) string {
+	if dir == "" || filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(filepath.Dir(configFile), dir)
+}
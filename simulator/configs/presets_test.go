@@ -0,0 +1,62 @@
+package configs
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPresetsOverride(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.yaml")
+	content := `
+presetsDir: "./presets"
+energyUnit: "eV"
+elements:
+  - preset: "O@silica"
+    edes: 0.65
+  - preset: "N@alumina"
+`
+	if err := os.WriteFile(config, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "presets"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	library := `
+energyUnit: "kJ/mol"
+presets:
+  N@alumina: { name: "N", mass: 14.007, edes: 40, edif: 20 }
+`
+	if err := os.WriteFile(filepath.Join(dir, "presets", "alumina.yaml"), []byte(library), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, _, err := Load(Sources{Files: []string{config}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oxygen, nitrogen := cfg.Elements[0], cfg.Elements[1]
+	if oxygen.Name != "O" || oxygen.Edif != 30000 {
+		t.Errorf("O@silica resolved to %+v", oxygen)
+	}
+	if want := 0.65 * joulePerMolPerElectronVolt; math.Abs(oxygen.Edes-want) > 1e-6 {
+		t.Errorf("overridden edes = %g J/mol, want %g", oxygen.Edes, want)
+	}
+	if nitrogen.Edes != 40000 || nitrogen.Edif != 20000 {
+		t.Errorf("N@alumina from the presets dir resolved to %+v", nitrogen)
+	}
+}
+
+func TestUnknownPreset(t *testing.T) {
+	presets, err := LoadPresets("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = presets.resolvePresets([]interface{}{map[string]interface{}{"preset": "N@alumina"}})
+	if err == nil || !strings.Contains(err.Error(), "N@silica") {
+		t.Errorf("unknown preset error %v does not list the known presets", err)
+	}
+}
@@ -0,0 +1,73 @@
+package configs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+)
+
+// Print writes every config value as key = value with the layer it came from.
+func Print(w io.Writer, cfg Config, origins Origins) error {
+	keys, values, err := Flatten(cfg)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, key := range keys {
+		fmt.Fprintf(tw, "%s\t= %s\t# %s\n", key, values[key], origins.Of(key))
+	}
+
+	return tw.Flush()
+}
+
+// Flatten returns the JSON form of v as sorted dotted keys such as simulating.matrixLenX
+// with their JSON-encoded values.
+func Flatten(v interface{}) ([]string, map[string]string, error) {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var tree interface{}
+	if err = json.Unmarshal(content, &tree); err != nil {
+		return nil, nil, err
+	}
+
+	values := make(map[string]string)
+	flatten(tree, "", values)
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys, values, nil
+}
+
+func flatten(value interface{}, key string, values map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for name, nested := range v {
+			flatten(nested, joinKey(key, name), values)
+		}
+	case []interface{}:
+		for i, nested := range v {
+			flatten(nested, joinKey(key, strconv.Itoa(i)), values)
+		}
+	default:
+		content, _ := json.Marshal(v)
+		values[key] = string(content)
+	}
+}
+
+func joinKey(prefix string, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
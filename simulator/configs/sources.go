@@ -0,0 +1,199 @@
+package configs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/knadh/koanf/parsers/json"
+	"github.com/knadh/koanf/parsers/toml/v2"
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/providers/file"
+	"github.com/knadh/koanf/v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// DefaultConfigPath is the base config used when no file is given.
+const DefaultConfigPath = "./../config.yaml"
+
+// EnvPrefix prefixes environment variables overriding config values, e.g. SURFACE_SIMULATING_MATRIXLENX=500.
+const EnvPrefix = "SURFACE_"
+
+// Origins of values that were not set by any layer.
+const originDefault = "default"
+
+// Sources lists the configuration layers, applied in order: files, environment, --set overrides.
+type Sources struct {
+	// Base config first, then overlays. YAML, JSON and TOML are accepted by extension.
+	Files []string
+	// Environment in os.Environ form; only SURFACE_* variables are used
+	Env []string
+	// key=value overrides, e.g. simulating.matrixLenX=500
+	Set []string
+}
+
+// Origins maps a config key to the layer its value came from.
+type Origins map[string]string
+
+// Of returns the origin of the key, or of the closest parent key that was set as a whole.
+func (o Origins) Of(key string) string {
+	for {
+		if origin, ok := o[key]; ok {
+			return origin
+		}
+		i := strings.LastIndex(key, ".")
+		if i < 0 {
+			return originDefault
+		}
+		key = key[:i]
+	}
+}
+
+// merge loads the layers into a single koanf instance and records where every key came from.
+func (s Sources) merge() (*koanf.Koanf, Origins, error) {
+	k := koanf.New(".")
+	origins := make(Origins)
+
+	for _, path := range s.Files {
+		if err := loadFile(k, origins, path, nil); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	keys := configKeys(reflect.TypeFor[Config](), "")
+	for _, variable := range s.Env {
+		name, value, ok := strings.Cut(variable, "=")
+		if !ok || !strings.HasPrefix(name, EnvPrefix) {
+			continue
+		}
+		envKey := strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(name, EnvPrefix), "_", "."))
+		key, known := keys[envKey]
+		if !known {
+			continue
+		}
+		if err := setValue(k, origins, key, value, "env "+name); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, override := range s.Set {
+		key, value, ok := strings.Cut(override, "=")
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid override %q, expected key=value", override)
+		}
+		// Keys are matched whatever their case, as the config is decoded, and set in their canonical spelling
+		// so that they override the values of the files and are reported with their origin
+		key = strings.TrimSpace(key)
+		if canonical, known := keys[strings.ToLower(key)]; known {
+			key = canonical
+		}
+		if err := setValue(k, origins, key, value, "--set"); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return k, origins, nil
+}
+
+// loadFile merges the file into k. Files listed in its include key are merged first, relative to it.
+func loadFile(k *koanf.Koanf, origins Origins, path string, seen []string) error {
+	for _, included := range seen {
+		if included == path {
+			return fmt.Errorf("include cycle: %s", strings.Join(append(seen, path), " -> "))
+		}
+	}
+
+	parser, err := parserFor(path)
+	if err != nil {
+		return err
+	}
+
+	layer := koanf.New(".")
+	if err = layer.Load(file.Provider(path), parser); err != nil {
+		return err
+	}
+
+	for _, include := range layer.Strings("include") {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+		if err = loadFile(k, origins, include, append(seen, path)); err != nil {
+			return err
+		}
+	}
+	layer.Delete("include")
+
+	if err = k.Merge(layer); err != nil {
+		return err
+	}
+	for _, key := range layer.Keys() {
+		origins[key] = path
+	}
+
+	return nil
+}
+
+func parserFor(path string) (koanf.Parser, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yaml.Parser(), nil
+	case ".json":
+		return json.Parser(), nil
+	case ".toml":
+		return toml.Parser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported config format %s", path)
+	}
+}
+
+// setValue sets a single key parsing the value as YAML, so that numbers, booleans and lists keep their types.
+func setValue(k *koanf.Koanf, origins Origins, key string, value string, origin string) error {
+	var parsed interface{}
+	if err := yamlv3.Unmarshal([]byte(value), &parsed); err != nil {
+		return fmt.Errorf("%s: %w", origin, err)
+	}
+
+	if err := k.Set(key, parsed); err != nil {
+		return err
+	}
+	origins[key] = origin
+
+	return nil
+}
+
+// configKeys maps lowercased config keys to their canonical spelling, following the json tags of the type.
+func configKeys(t reflect.Type, prefix string) map[string]string {
+	keys := make(map[string]string)
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		key := prefix + name
+		keys[strings.ToLower(key)] = key
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Struct {
+			for lower, nested := range configKeys(fieldType, key+".") {
+				keys[lower] = nested
+			}
+		}
+	}
+
+	return keys
+}
+
+// DefaultSources returns the default base config with the process environment.
+func DefaultSources() Sources {
+	return Sources{
+		Files: []string{DefaultConfigPath},
+		Env:   os.Environ(),
+	}
+}
@@ -0,0 +1,32 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetKeyCase(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(config, []byte("simulating:\n  matrixLenX: 100\n  matrixLenY: 100\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, origins, err := Load(Sources{
+		Files: []string{config},
+		Env:   []string{"SURFACE_SIMULATING_MATRIXLENY=300"},
+		Set:   []string{"simulating.matrixlenx=200"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Simulating.MatrixLenX != 200 || cfg.Simulating.MatrixLenY != 300 {
+		t.Errorf("matrix %dx%d, want 200x300", cfg.Simulating.MatrixLenX, cfg.Simulating.MatrixLenY)
+	}
+	if origin := origins.Of("simulating.matrixLenX"); origin != "--set" {
+		t.Errorf("origin of simulating.matrixLenX = %q, want --set", origin)
+	}
+	if origin := origins.Of("simulating.matrixLenY"); origin != "env SURFACE_SIMULATING_MATRIXLENY" {
+		t.Errorf("origin of simulating.matrixLenY = %q, want the environment", origin)
+	}
+}
@@ -0,0 +1,121 @@
+package configs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Energy units accepted in energyUnit and in per-field values such as "0.52 eV".
+// Energies are normalised to J/mol at load.
+const (
+	EnergyUnitJoulePerMol     = "J/mol"
+	EnergyUnitKiloJoulePerMol = "kJ/mol"
+	EnergyUnitKiloCalPerMol   = "kcal/mol"
+	EnergyUnitElectronVolt    = "eV"
+	EnergyUnitKelvin          = "K"
+)
+
+const (
+	// J/mol per eV, e·N_A
+	joulePerMolPerElectronVolt = 96485.33212
+	// J/mol per K, R
+	joulePerMolPerKelvin = 8.314462618
+	// J/mol per kcal/mol
+	joulePerMolPerKiloCal = 4184
+)
+
+// energyFields are the element keys holding activation energies.
+var energyFields = []string{"edes", "edif", "er", "erlhf", "erlhs", "erlhfhet", "erlhshet"}
+
+// energyUnits are the units a value can end with, longest first so that "kJ/mol" is not taken for "J/mol".
+var energyUnits = []string{
+	EnergyUnitKiloCalPerMol,
+	EnergyUnitKiloJoulePerMol,
+	EnergyUnitJoulePerMol,
+	EnergyUnitElectronVolt,
+	EnergyUnitKelvin,
+}
+
+// normalizeEnergies converts the energies of every element in the raw config to J/mol.
+// A value is either a number in defaultUnit or a string with its own unit, e.g. "0.52 eV".
+func normalizeEnergies(elements []interface{}, defaultUnit string) error {
+	for i, rawElement := range elements {
+		element, ok := rawElement.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for _, field := range energyFields {
+			value, exists := element[field]
+			if !exists {
+				continue
+			}
+
+			energy, err := ParseEnergy(value, defaultUnit)
+			if err != nil {
+				return fmt.Errorf("elements[%d].%s: %w", i, field, err)
+			}
+			element[field] = energy
+		}
+	}
+
+	return nil
+}
+
+// ParseEnergy converts a number in defaultUnit or a "<value> <unit>" string to J/mol.
+// The space may be left out, e.g. "0.52eV".
+func ParseEnergy(value interface{}, defaultUnit string) (float64, error) {
+	switch v := value.(type) {
+	case int:
+		return ToJoulePerMol(float64(v), defaultUnit)
+	case int64:
+		return ToJoulePerMol(float64(v), defaultUnit)
+	case float64:
+		return ToJoulePerMol(v, defaultUnit)
+	case string:
+		number, unit := splitEnergy(strings.TrimSpace(v))
+		if unit == "" {
+			unit = defaultUnit
+		}
+
+		parsed, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid energy %q", v)
+		}
+		return ToJoulePerMol(parsed, unit)
+	default:
+		return 0, fmt.Errorf("invalid energy %v", value)
+	}
+}
+
+// splitEnergy splits an energy into its number and its unit, given after a space or right after the number.
+func splitEnergy(value string) (string, string) {
+	if number, unit, found := strings.Cut(value, " "); found {
+		return number, strings.TrimSpace(unit)
+	}
+	for _, unit := range energyUnits {
+		if len(value) > len(unit) && strings.EqualFold(value[len(value)-len(unit):], unit) {
+			return value[:len(value)-len(unit)], unit
+		}
+	}
+	return value, ""
+}
+
+// ToJoulePerMol converts an energy from the given unit to J/mol.
+func ToJoulePerMol(value float64, unit string) (float64, error) {
+	switch strings.ToLower(unit) {
+	case "", "j/mol":
+		return value, nil
+	case "kj/mol":
+		return value * 1000, nil
+	case "kcal/mol":
+		return value * joulePerMolPerKiloCal, nil
+	case "ev":
+		return value * joulePerMolPerElectronVolt, nil
+	case "k":
+		return value * joulePerMolPerKelvin, nil
+	default:
+		return 0, fmt.Errorf("unknown energy unit %q", unit)
+	}
+}
@@ -0,0 +1,42 @@
+package configs
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseEnergy(t *testing.T) {
+	for _, c := range []struct {
+		value       interface{}
+		defaultUnit string
+		want        float64
+	}{
+		{50000, "J/mol", 50000},
+		{int64(50), "kJ/mol", 50000},
+		{0.52, "eV", 0.52 * joulePerMolPerElectronVolt},
+		{"0.52 eV", "J/mol", 0.52 * joulePerMolPerElectronVolt},
+		{"0.52eV", "J/mol", 0.52 * joulePerMolPerElectronVolt},
+		{" 0.52  ev ", "J/mol", 0.52 * joulePerMolPerElectronVolt},
+		{"50kJ/mol", "eV", 50000},
+		{"12 kcal/mol", "eV", 12 * joulePerMolPerKiloCal},
+		{"6000K", "J/mol", 6000 * joulePerMolPerKelvin},
+		{"1e3", "kJ/mol", 1e6},
+		{"1e-1eV", "J/mol", 0.1 * joulePerMolPerElectronVolt},
+		{"14000", "", 14000},
+	} {
+		got, err := ParseEnergy(c.value, c.defaultUnit)
+		if err != nil {
+			t.Errorf("ParseEnergy(%v, %q): %v", c.value, c.defaultUnit, err)
+			continue
+		}
+		if math.Abs(got-c.want) > 1e-9*c.want {
+			t.Errorf("ParseEnergy(%v, %q) = %g, want %g", c.value, c.defaultUnit, got, c.want)
+		}
+	}
+
+	for _, value := range []interface{}{"0.52 eVV", "eV", "0.52 erg", "fast", true} {
+		if _, err := ParseEnergy(value, "J/mol"); err == nil {
+			t.Errorf("ParseEnergy(%v) did not fail", value)
+		}
+	}
+}
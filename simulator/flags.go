@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"github.com/zipliZ/surface-atoms/simulator/configs"
+	"os"
+	"strings"
+)
+
+// stringList is a flag that may be repeated.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// configFlags selects the config layers: a base file, overlays and key=value overrides.
+type configFlags struct {
+	path     string
+	overlays stringList
+	set      stringList
+}
+
+func (f *configFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.path, "config", configs.DefaultConfigPath, "base config file (YAML, JSON or TOML)")
+	fs.Var(&f.overlays, "overlay", "config file applied over the base config, may be repeated")
+	fs.Var(&f.set, "set", "override a single value, e.g. simulating.matrixLenX=500, may be repeated")
+}
+
+func (f *configFlags) sources() configs.Sources {
+	return configs.Sources{
+		Files: append([]string{f.path}, f.overlays...),
+		Env:   os.Environ(),
+		Set:   f.set,
+	}
+}
@@ -2,29 +2,29 @@ package graphic_plotter
 
 import (
 	"fmt"
+	"github.com/zipliZ/surface-atoms/simulator/configs"
+	"github.com/zipliZ/surface-atoms/simulator/internal/output"
 	"io"
 	"log/slog"
-	"main/configs"
 	"os"
-	"strconv"
 
 	"github.com/go-echarts/go-echarts/v2/charts"
 	"github.com/go-echarts/go-echarts/v2/components"
 	"github.com/go-echarts/go-echarts/v2/opts"
 	"github.com/go-echarts/go-echarts/v2/types"
-	"github.com/xuri/excelize/v2"
 )
 
 type GraphicPlotter struct {
-	ExelFilePath   string
+	// Results table in any of the output formats
+	TableFilePath  string
 	OutputFilePath string
 	LineLabel      string
 	GraphicsToPlot []configs.GraphicToPlot
 }
 
-func New(exelFilePath string, outputFilePath string, lineLabel string, graphicsToPlot []configs.GraphicToPlot) *GraphicPlotter {
+func New(tableFilePath string, outputFilePath string, lineLabel string, graphicsToPlot []configs.GraphicToPlot) *GraphicPlotter {
 	return &GraphicPlotter{
-		ExelFilePath:   exelFilePath,
+		TableFilePath:  tableFilePath,
 		OutputFilePath: outputFilePath,
 		GraphicsToPlot: graphicsToPlot,
 		LineLabel:      lineLabel,
@@ -36,7 +36,7 @@ func (p *GraphicPlotter) Plot() error {
 		return nil
 	}
 	slog.Info("start plot graphics", "count", len(p.GraphicsToPlot))
-	columnValues, err := p.readExcel()
+	columnValues, err := p.readTable()
 	if err != nil {
 		return err
 	}
@@ -62,33 +62,17 @@ func (p *GraphicPlotter) Plot() error {
 	return page.Render(io.MultiWriter(f))
 }
 
-func (p *GraphicPlotter) readExcel() (columnValues map[string][]float64, err error) {
-	file, err := excelize.OpenFile(p.ExelFilePath)
+func (p *GraphicPlotter) readTable() (columnValues map[string][]float64, err error) {
+	headers, rows, err := output.ReadTable(p.TableFilePath)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
-
-	rows, err := file.GetRows("Sheet1")
-	if err != nil {
-		return nil, err
-	}
-	if len(rows) < 2 {
+	if len(rows) < 1 {
 		return nil, fmt.Errorf("no data")
 	}
 
-	requiredColumns := map[string]struct{}{}
-	for _, graphicAxis := range p.GraphicsToPlot {
-		if _, ok := requiredColumns[graphicAxis.XAxis]; !ok {
-			requiredColumns[graphicAxis.XAxis] = struct{}{}
-		}
-		if _, ok := requiredColumns[graphicAxis.YAxis]; !ok {
-			requiredColumns[graphicAxis.YAxis] = struct{}{}
-		}
-	}
-
 	columnsIndexes := make(map[int]string)
-	for i, header := range rows[0] {
+	for i, header := range headers {
 		// check if this header matches any xAxis exactly
 		for _, graphicAxis := range p.GraphicsToPlot {
 			if header == graphicAxis.XAxis {
@@ -107,11 +91,9 @@ func (p *GraphicPlotter) readExcel() (columnValues map[string][]float64, err err
 
 	columnValues = make(map[string][]float64)
 	// Читаем данные по индексам колонок
-	for i := 1; i < len(rows); i++ {
-		row := rows[i]
+	for _, row := range rows {
 		for index, columnName := range columnsIndexes {
-			value, _ := strconv.ParseFloat(row[index], 64)
-			columnValues[columnName] = append(columnValues[columnName], value)
+			columnValues[columnName] = append(columnValues[columnName], row[index])
 		}
 	}
 
@@ -0,0 +1,158 @@
+package occupancy
+
+import (
+	"bufio"
+	"compress/lzw"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"os"
+)
+
+// GIFWriter writes an animated GIF a map at a time, so that the maps of a long run need not be kept
+// until its end. Every map is shown for the same delay and the animation loops forever.
+type GIFWriter struct {
+	file       *os.File
+	w          *bufio.Writer
+	width      int
+	height     int
+	palette    color.Palette
+	cellPixels int
+	delay      int
+	// Bits of the codes of the colours, at least 2 as GIF requires
+	litWidth int
+}
+
+// NewGIFWriter creates the GIF file of maps of lenX by lenY cells and writes its header.
+func NewGIFWriter(path string, lenX, lenY int, palette color.Palette, cellPixels int, delay int) (*GIFWriter, error) {
+	width, height := lenX*cellPixels, lenY*cellPixels
+	if width > 0xffff || height > 0xffff {
+		return nil, fmt.Errorf("a GIF image is at most 65535 pixels wide and high, got %dx%d", width, height)
+	}
+
+	// The global colour table has 2^bits colours
+	bits := 1
+	for 1<<bits < len(palette) {
+		bits++
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	g := &GIFWriter{
+		file:       file,
+		w:          bufio.NewWriter(file),
+		width:      width,
+		height:     height,
+		palette:    palette,
+		cellPixels: cellPixels,
+		delay:      delay,
+		litWidth:   max(2, bits),
+	}
+
+	// Header and logical screen descriptor with the global colour table
+	g.w.WriteString("GIF89a")
+	g.writeUint16(width, height)
+	g.w.Write([]byte{0x80 | byte(bits-1)<<4 | byte(bits-1), 0, 0})
+	for i := range 1 << bits {
+		var r, gr, b uint32
+		if i < len(palette) {
+			r, gr, b, _ = palette[i].RGBA()
+		}
+		g.w.Write([]byte{byte(r >> 8), byte(gr >> 8), byte(b >> 8)})
+	}
+	// Application extension looping the animation forever
+	g.w.Write([]byte{0x21, 0xff, 0x0b})
+	g.w.WriteString("NETSCAPE2.0")
+	if _, err = g.w.Write([]byte{0x03, 0x01, 0x00, 0x00, 0x00}); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return g, nil
+}
+
+// WriteFrame appends the map to the animation.
+func (g *GIFWriter) WriteFrame(m Map) error {
+	if m.LenX*g.cellPixels != g.width || m.LenY*g.cellPixels != g.height {
+		return fmt.Errorf("map of %dx%d cells does not fit the %dx%d animation", m.LenX, m.LenY, g.width, g.height)
+	}
+	img := m.Image(g.palette, g.cellPixels)
+
+	// Graphic control extension with the delay, then the image descriptor of the whole screen
+	g.w.Write([]byte{0x21, 0xf9, 0x04, 0x00})
+	g.writeUint16(g.delay)
+	g.w.Write([]byte{0x00, 0x00, 0x2c})
+	g.writeUint16(0, 0, g.width, g.height)
+	g.w.Write([]byte{0x00, byte(g.litWidth)})
+
+	blocks := &subBlocks{w: g.w}
+	compressor := lzw.NewWriter(blocks, lzw.LSB, g.litWidth)
+	if _, err := compressor.Write(img.Pix); err != nil {
+		return err
+	}
+	if err := compressor.Close(); err != nil {
+		return err
+	}
+	return blocks.close()
+}
+
+// Close ends the animation and closes the file.
+func (g *GIFWriter) Close() error {
+	if err := g.w.WriteByte(0x3b); err != nil {
+		_ = g.file.Close()
+		return err
+	}
+	if err := g.w.Flush(); err != nil {
+		_ = g.file.Close()
+		return err
+	}
+	return g.file.Close()
+}
+
+func (g *GIFWriter) writeUint16(values ...int) {
+	for _, value := range values {
+		_ = binary.Write(g.w, binary.LittleEndian, uint16(value))
+	}
+}
+
+// subBlocks splits the compressed image into the data sub-blocks of at most 255 bytes of GIF.
+type subBlocks struct {
+	w   *bufio.Writer
+	buf [256]byte
+	n   int
+}
+
+func (b *subBlocks) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		copied := copy(b.buf[1+b.n:], p)
+		b.n += copied
+		written += copied
+		p = p[copied:]
+		if b.n == 255 {
+			if err := b.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+func (b *subBlocks) flush() error {
+	if b.n == 0 {
+		return nil
+	}
+	b.buf[0] = byte(b.n)
+	_, err := b.w.Write(b.buf[:1+b.n])
+	b.n = 0
+	return err
+}
+
+// close writes the last sub-block and the block terminator.
+func (b *subBlocks) close() error {
+	if err := b.flush(); err != nil {
+		return err
+	}
+	return b.w.WriteByte(0)
+}
@@ -0,0 +1,68 @@
+package occupancy
+
+import (
+	"image/gif"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGIFWriter(t *testing.T) {
+	const lenX, lenY, cellPixels, delay = 7, 5, 3, 15
+	palette := Palette(2)
+	path := filepath.Join(t.TempDir(), "occupancy.gif")
+	writer, err := NewGIFWriter(path, lenX, lenY, palette, cellPixels, delay)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var maps []Map
+	for frame := range 4 {
+		m := NewMap(float64(frame), lenX, lenY)
+		for i := range m.Cells {
+			m.Cells[i] = uint8((i + frame) % len(palette))
+		}
+		maps = append(maps, m)
+		if err = writer.WriteFrame(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.WriteFrame(NewMap(0, lenX+1, lenY)); err == nil {
+		t.Error("a map of another size was written")
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	animation, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(animation.Image) != len(maps) {
+		t.Fatalf("decoded %d frames, want %d", len(animation.Image), len(maps))
+	}
+	if animation.LoopCount != 0 {
+		t.Errorf("LoopCount = %d, want 0 (forever)", animation.LoopCount)
+	}
+	for i, img := range animation.Image {
+		if animation.Delay[i] != delay {
+			t.Errorf("frame %d delay %d, want %d", i, animation.Delay[i], delay)
+		}
+		want := maps[i].Image(palette, cellPixels)
+		if img.Bounds() != want.Bounds() {
+			t.Fatalf("frame %d bounds %v, want %v", i, img.Bounds(), want.Bounds())
+		}
+		for y := range want.Rect.Dy() {
+			for x := range want.Rect.Dx() {
+				if img.ColorIndexAt(x, y) != want.ColorIndexAt(x, y) {
+					t.Fatalf("frame %d pixel (%d, %d) is %d, want %d", i, x, y, img.ColorIndexAt(x, y), want.ColorIndexAt(x, y))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package occupancy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"image/color"
+	"image/png"
+)
+
+// MaxHTMLFrames is the most maps an HTML animation keeps: the page holds every map as an encoded image.
+const MaxHTMLFrames = 256
+
+// HTMLAnimation collects the maps of a self-contained HTML page playing them in turn, with a slider,
+// the physical time of the map shown and the legend of the cell states. When it holds MaxHTMLFrames maps
+// it drops every other one and from then on keeps every other map added, so the page covers the whole
+// run at an evenly thinned rate.
+type HTMLAnimation struct {
+	legend     []string
+	palette    color.Palette
+	cellPixels int
+	delay      int
+	frames     []htmlFrame
+	// Every stride-th map added is kept, added counts the maps added so far
+	stride int
+	added  int
+}
+
+// NewHTMLAnimation returns an empty animation showing every map for delay hundredths of a second.
+func NewHTMLAnimation(legend []string, palette color.Palette, cellPixels int, delay int) *HTMLAnimation {
+	return &HTMLAnimation{legend: legend, palette: palette, cellPixels: cellPixels, delay: delay, stride: 1}
+}
+
+// Add encodes the map as a frame of the animation, unless the thinning skips it.
+func (a *HTMLAnimation) Add(m Map) error {
+	added := a.added
+	a.added++
+	if added%a.stride != 0 {
+		return nil
+	}
+
+	var image bytes.Buffer
+	if err := png.Encode(&image, m.Image(a.palette, a.cellPixels)); err != nil {
+		return err
+	}
+	a.frames = append(a.frames, htmlFrame{
+		Image: template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(image.Bytes())),
+		Time:  fmt.Sprintf("%g s", m.PhysicalTime),
+	})
+	if len(a.frames) == MaxHTMLFrames {
+		for i := range len(a.frames) / 2 {
+			a.frames[i] = a.frames[2*i]
+		}
+		clear(a.frames[len(a.frames)/2:])
+		a.frames = a.frames[:len(a.frames)/2]
+		a.stride *= 2
+	}
+	return nil
+}
+
+// Write writes the page of the maps kept. An animation without maps writes nothing.
+func (a *HTMLAnimation) Write(path string) error {
+	if len(a.frames) == 0 {
+		return nil
+	}
+	page := htmlPage{Delay: a.delay * 10, Frames: a.frames}
+	for i, description := range a.legend {
+		r, g, b, _ := a.palette[i].RGBA()
+		page.Legend = append(page.Legend, htmlLegend{
+			Color:       template.CSS(fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)),
+			Description: description,
+		})
+	}
+
+	return writeFile(path, func(w *bufio.Writer) error {
+		return htmlTemplate.Execute(w, page)
+	})
+}
+
+type htmlPage struct {
+	// Milliseconds every frame is shown for
+	Delay  int
+	Frames []htmlFrame
+	Legend []htmlLegend
+}
+
+type htmlFrame struct {
+	Image template.URL
+	Time  string
+}
+
+type htmlLegend struct {
+	Color       template.CSS
+	Description string
+}
+
+var htmlTemplate = template.Must(template.New("animation").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Surface Atoms - occupancy</title>
+<style>
+body { font-family: sans-serif; }
+#frame { image-rendering: pixelated; border: 1px solid #ccc; }
+.legend span { display: inline-block; width: 1em; height: 1em; margin: 0 0.3em 0 1em; vertical-align: middle; }
+</style>
+</head>
+<body>
+<div><img id="frame" src="{{(index .Frames 0).Image}}"></div>
+<div>
+<button id="play">Pause</button>
+<input id="slider" type="range" min="0" value="0" style="width: 50%">
+<span id="time">{{(index .Frames 0).Time}}</span>
+</div>
+<div class="legend">{{range .Legend}}<span style="background: {{.Color}}"></span>{{.Description}}{{end}}</div>
+<script>
+const frames = [{{range .Frames}}{image: {{.Image}}, time: {{.Time}}},{{end}}];
+const frame = document.getElementById("frame");
+const slider = document.getElementById("slider");
+const time = document.getElementById("time");
+const play = document.getElementById("play");
+slider.max = frames.length - 1;
+let current = 0;
+let timer = null;
+function show(i) {
+	current = i;
+	frame.src = frames[i].image;
+	time.textContent = frames[i].time;
+	slider.value = i;
+}
+function start() {
+	timer = setInterval(() => show((current + 1) % frames.length), {{.Delay}});
+	play.textContent = "Pause";
+}
+function stop() {
+	clearInterval(timer);
+	timer = null;
+	play.textContent = "Play";
+}
+play.onclick = () => timer === null ? start() : stop();
+slider.oninput = () => { stop(); show(Number(slider.value)); };
+start();
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,29 @@
+package occupancy
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHTMLAnimationThinsFrames(t *testing.T) {
+	a := NewHTMLAnimation(Legend([]string{"N"}), Palette(1), 1, 20)
+	const maps = 3*MaxHTMLFrames + 5
+	for i := range maps {
+		if err := a.Add(NewMap(float64(i), 2, 2)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(a.frames) >= MaxHTMLFrames {
+		t.Fatalf("kept %d frames, want fewer than %d", len(a.frames), MaxHTMLFrames)
+	}
+	// Every kept map is evenly spaced from the first one
+	for i, frame := range a.frames {
+		if want := fmt.Sprintf("%g s", float64(i*a.stride)); frame.Time != want {
+			t.Fatalf("frame %d is the map at %s, want %s", i, frame.Time, want)
+		}
+	}
+	if last := (len(a.frames) - 1) * a.stride; last+a.stride < maps {
+		t.Errorf("the last frame is map %d of %d with stride %d", last, maps, a.stride)
+	}
+}
@@ -0,0 +1,185 @@
+package occupancy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"strconv"
+)
+
+// Formats of the occupancy maps.
+const (
+	FormatPNG = "png"
+	FormatCSV = "csv"
+	FormatNPY = "npy"
+)
+
+// Formats of the animation of the maps of a run.
+const (
+	AnimationGIF  = "gif"
+	AnimationHTML = "html"
+)
+
+// States of a cell in a map. An atom of the element with index i is AtomOnF+2*i on an F-center
+// and AtomOnS+2*i on an S-center.
+const (
+	FreeF   = 0
+	FreeS   = 1
+	AtomOnF = 2
+	AtomOnS = 3
+)
+
+// MaxElements is the number of elements the cell states can tell apart.
+const MaxElements = (256 - AtomOnF) / 2
+
+// Map is the state of every cell of the lattice at a physical time, row by row: the cell (x, y) is Cells[y*LenX+x].
+type Map struct {
+	PhysicalTime float64
+	LenX         int
+	LenY         int
+	Cells        []uint8
+}
+
+// NewMap returns a map of free F-centers.
+func NewMap(physicalTime float64, lenX, lenY int) Map {
+	return Map{PhysicalTime: physicalTime, LenX: lenX, LenY: lenY, Cells: make([]uint8, lenX*lenY)}
+}
+
+// Set sets the state of the cell (x, y).
+func (m Map) Set(x, y int, state uint8) {
+	m.Cells[y*m.LenX+x] = state
+}
+
+// AtomState returns the state of a cell holding an atom of the element with the given index on the center.
+func AtomState(element int, center rune) uint8 {
+	if center == 'S' {
+		return uint8(AtomOnS + 2*element)
+	}
+	return uint8(AtomOnF + 2*element)
+}
+
+// Legend returns the description of every cell state for the elements.
+func Legend(elements []string) []string {
+	legend := []string{"free F", "free S"}
+	for _, element := range elements {
+		legend = append(legend, element+" on F", element+" on S")
+	}
+	return legend
+}
+
+// Colours of the elements, cycled when there are more elements; an atom on an S-center has a darker shade.
+var elementColors = []color.RGBA{
+	{R: 0x1f, G: 0x77, B: 0xb4, A: 0xff},
+	{R: 0xd6, G: 0x27, B: 0x28, A: 0xff},
+	{R: 0x2c, G: 0xa0, B: 0x2c, A: 0xff},
+	{R: 0xff, G: 0x7f, B: 0x0e, A: 0xff},
+	{R: 0x94, G: 0x67, B: 0xbd, A: 0xff},
+	{R: 0x8c, G: 0x56, B: 0x4b, A: 0xff},
+	{R: 0xe3, G: 0x77, B: 0xc2, A: 0xff},
+	{R: 0x17, G: 0xbe, B: 0xcf, A: 0xff},
+}
+
+// Palette returns the colour of every cell state for the given number of elements:
+// light grey for free F-centers, dark grey for free S-centers.
+func Palette(elements int) color.Palette {
+	palette := color.Palette{
+		color.RGBA{R: 0xf2, G: 0xf2, B: 0xf2, A: 0xff},
+		color.RGBA{R: 0x9e, G: 0x9e, B: 0x9e, A: 0xff},
+	}
+	for i := range elements {
+		c := elementColors[i%len(elementColors)]
+		dark := color.RGBA{R: uint8(uint16(c.R) * 3 / 5), G: uint8(uint16(c.G) * 3 / 5), B: uint8(uint16(c.B) * 3 / 5), A: 0xff}
+		palette = append(palette, c, dark)
+	}
+	return palette
+}
+
+// Image draws the map with every cell a square of cellPixels pixels; row y of the image is the row y of the lattice.
+func (m Map) Image(palette color.Palette, cellPixels int) *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, m.LenX*cellPixels, m.LenY*cellPixels), palette)
+	for y := range m.LenY {
+		row := img.Pix[y*cellPixels*img.Stride : (y*cellPixels+1)*img.Stride]
+		for x, state := range m.Cells[y*m.LenX : (y+1)*m.LenX] {
+			for i := range cellPixels {
+				row[x*cellPixels+i] = state
+			}
+		}
+		for i := 1; i < cellPixels; i++ {
+			copy(img.Pix[(y*cellPixels+i)*img.Stride:], row)
+		}
+	}
+	return img
+}
+
+// WritePNG writes the map as a PNG image.
+func WritePNG(path string, m Map, palette color.Palette, cellPixels int) error {
+	return writeFile(path, func(w *bufio.Writer) error {
+		return png.Encode(w, m.Image(palette, cellPixels))
+	})
+}
+
+// WriteCSV writes the states of the cells, a line per row of the lattice.
+func WriteCSV(path string, m Map) error {
+	return writeFile(path, func(w *bufio.Writer) error {
+		line := make([]byte, 0, 4*m.LenX)
+		for y := range m.LenY {
+			line = line[:0]
+			for x, state := range m.Cells[y*m.LenX : (y+1)*m.LenX] {
+				if x > 0 {
+					line = append(line, ',')
+				}
+				line = strconv.AppendUint(line, uint64(state), 10)
+			}
+			line = append(line, '\n')
+			if _, err := w.Write(line); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// WriteNPY writes the states of the cells as a NumPy uint8 array of shape (LenY, LenX).
+func WriteNPY(path string, m Map) error {
+	return writeFile(path, func(w *bufio.Writer) error {
+		header := fmt.Sprintf("{'descr': '|u1', 'fortran_order': False, 'shape': (%d, %d), }", m.LenY, m.LenX)
+		// The magic, the version and the header length take 10 bytes; the header is padded to 64 bytes
+		for (10+len(header)+1)%64 != 0 {
+			header += " "
+		}
+		header += "\n"
+
+		if _, err := w.WriteString("\x93NUMPY\x01\x00"); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, uint16(len(header))); err != nil {
+			return err
+		}
+		if _, err := w.WriteString(header); err != nil {
+			return err
+		}
+		_, err := w.Write(m.Cells)
+		return err
+	})
+}
+
+func writeFile(path string, write func(w *bufio.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err = write(w); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = w.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
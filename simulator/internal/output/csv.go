@@ -0,0 +1,125 @@
+package output
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+)
+
+type csvWriter struct {
+	path   string
+	file   *os.File
+	writer *csv.Writer
+	tables []*csvWriter
+}
+
+func newCSVWriter(path string) (*csvWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &csvWriter{
+		path:   path,
+		file:   file,
+		writer: csv.NewWriter(file),
+	}, nil
+}
+
+func (w *csvWriter) Path() string {
+	return w.path
+}
+
+func (w *csvWriter) WriteHeader(headers []string) error {
+	return w.write(headers)
+}
+
+func (w *csvWriter) WriteRow(values []float64) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return w.write(record)
+}
+
+// write appends a record and hands it to the OS, so it survives a crash of the process.
+func (w *csvWriter) write(record []string) error {
+	if err := w.writer.Write(record); err != nil {
+		return err
+	}
+	w.writer.Flush()
+
+	return w.writer.Error()
+}
+
+// Table writes the further table to a file in TablesDir.
+func (w *csvWriter) Table(name string) (Writer, error) {
+	path, err := tablePath(w.path, name)
+	if err != nil {
+		return nil, err
+	}
+	table, err := newCSVWriter(path)
+	if err != nil {
+		return nil, err
+	}
+	w.tables = append(w.tables, table)
+	return table, nil
+}
+
+func (w *csvWriter) Close() error {
+	var err error
+	for _, table := range w.tables {
+		if closeErr := table.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	w.writer.Flush()
+	if err == nil {
+		err = w.writer.Error()
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func readCSV(path string) (headers []string, rows [][]float64, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	headers, err = reader.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		// A crashed run may leave a partially written last line
+		if len(record) != len(headers) {
+			continue
+		}
+
+		row := make([]float64, len(record))
+		for i, value := range record {
+			row[i], _ = strconv.ParseFloat(value, 64)
+		}
+		rows = append(rows, row)
+	}
+
+	return headers, rows, nil
+}
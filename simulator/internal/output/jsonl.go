@@ -0,0 +1,189 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+)
+
+// jsonlWriter writes every row as a JSON object keyed by the column names, one object per line.
+type jsonlWriter struct {
+	path    string
+	file    *os.File
+	keys    [][]byte
+	buffer  bytes.Buffer
+	written bool
+	tables  []*jsonlWriter
+}
+
+func newJSONLWriter(path string) (*jsonlWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jsonlWriter{path: path, file: file}, nil
+}
+
+func (w *jsonlWriter) Path() string {
+	return w.path
+}
+
+func (w *jsonlWriter) WriteHeader(headers []string) error {
+	w.keys = make([][]byte, len(headers))
+	for i, header := range headers {
+		key, err := json.Marshal(header)
+		if err != nil {
+			return err
+		}
+		w.keys[i] = key
+	}
+	return nil
+}
+
+// WriteRow writes the object by hand to keep the columns in order.
+func (w *jsonlWriter) WriteRow(values []float64) error {
+	if len(values) != len(w.keys) {
+		return fmt.Errorf("row has %d values, header has %d columns", len(values), len(w.keys))
+	}
+
+	w.buffer.Reset()
+	w.buffer.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			w.buffer.WriteByte(',')
+		}
+		w.buffer.Write(w.keys[i])
+		w.buffer.WriteByte(':')
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			w.buffer.WriteString("null")
+		} else {
+			w.buffer.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+		}
+	}
+	w.buffer.WriteString("}\n")
+
+	_, err := w.file.Write(w.buffer.Bytes())
+	return err
+}
+
+// Table writes the further table to a file in TablesDir.
+func (w *jsonlWriter) Table(name string) (Writer, error) {
+	path, err := tablePath(w.path, name)
+	if err != nil {
+		return nil, err
+	}
+	table, err := newJSONLWriter(path)
+	if err != nil {
+		return nil, err
+	}
+	w.tables = append(w.tables, table)
+	return table, nil
+}
+
+func (w *jsonlWriter) Close() error {
+	var err error
+	for _, table := range w.tables {
+		if closeErr := table.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func readJSONL(path string) (headers []string, rows [][]float64, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		keys, values, err := decodeOrderedObject(line)
+		if err != nil {
+			// A crashed run may leave a partially written last line
+			continue
+		}
+		if headers == nil {
+			headers = keys
+		}
+		if len(values) != len(headers) {
+			continue
+		}
+		rows = append(rows, values)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if headers == nil {
+		return nil, nil, errors.New("no data")
+	}
+
+	return headers, rows, nil
+}
+
+// decodeOrderedObject decodes a flat JSON object of numbers, keeping the order of its keys.
+func decodeOrderedObject(line []byte) (keys []string, values []float64, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	if token != json.Delim('{') {
+		return nil, nil, errors.New("not an object")
+	}
+
+	for decoder.More() {
+		token, err = decoder.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key, ok := token.(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("unexpected key %v", token)
+		}
+
+		token, err = decoder.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		value := math.NaN()
+		if token != nil {
+			number, ok := token.(json.Number)
+			if !ok {
+				return nil, nil, fmt.Errorf("value of %s is not a number", key)
+			}
+			if value, err = number.Float64(); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+
+	if _, err = decoder.Token(); err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, err
+	}
+
+	return keys, values, nil
+}
@@ -0,0 +1,105 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Formats of the results table.
+const (
+	FormatXlsx   = "xlsx"
+	FormatCSV    = "csv"
+	FormatJSONL  = "jsonl"
+	FormatSQLite = "sqlite"
+)
+
+// Ways to continue an xlsx table once the row limit of a sheet is reached.
+const (
+	// RolloverSheets continues on Sheet2, Sheet3, ... of the same file.
+	RolloverSheets = "sheets"
+	// RolloverFiles continues in numbered part files: result_part2.xlsx, result_part3.xlsx, ...
+	RolloverFiles = "files"
+)
+
+// MaxXlsxRows is the row limit of an Excel sheet.
+const MaxXlsxRows = 1048576
+
+// Options tune the backends.
+type Options struct {
+	// Rows per xlsx sheet including the header; MaxXlsxRows when zero
+	XlsxRowLimit int
+	// RolloverSheets or RolloverFiles; RolloverSheets when empty
+	XlsxRollover string
+}
+
+// Writer appends rows to a results table. All backends produce the same column schema:
+// a header row of column names followed by numeric rows.
+type Writer interface {
+	// Path returns the file the table is written to.
+	Path() string
+	WriteHeader(headers []string) error
+	WriteRow(values []float64) error
+	Close() error
+}
+
+// MetadataWriter is implemented by backends that can store run metadata next to the table.
+type MetadataWriter interface {
+	// SetMetadata sets key-value pairs written when the writer is closed.
+	SetMetadata(keys []string, values map[string]string)
+}
+
+// TableWriter is implemented by backends that can store further tables of the run next to the results table.
+type TableWriter interface {
+	// Table returns a writer of the further table with the name, closed with the results table:
+	// a sheet of the xlsx file, a table of the SQLite database or a file in TablesDir next to a CSV or JSON-lines table.
+	Table(name string) (Writer, error)
+}
+
+// TablesDir is the directory of the further tables of CSV and JSON-lines results.
+const TablesDir = "tables"
+
+// tablePath returns the file of a further table of the results table at path, creating TablesDir if needed:
+// "Pair correlation" of result.csv is tables/pair_correlation.csv.
+func tablePath(path, name string) (string, error) {
+	dir := filepath.Join(filepath.Dir(path), TablesDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	fileName := strings.ReplaceAll(strings.ToLower(name), " ", "_") + filepath.Ext(path)
+	return filepath.Join(dir, fileName), nil
+}
+
+// New creates a writer of the given format. The extension of the format is added to basePath.
+func New(format string, basePath string, options Options) (Writer, error) {
+	path := basePath + "." + format
+	switch format {
+	case FormatXlsx:
+		return newXlsxWriter(path, options)
+	case FormatCSV:
+		return newCSVWriter(path)
+	case FormatJSONL:
+		return newJSONLWriter(path)
+	case FormatSQLite:
+		return newSQLiteWriter(path)
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// ReadTable reads a results table written by any of the backends, choosing the reader by the file extension.
+func ReadTable(path string) (headers []string, rows [][]float64, err error) {
+	switch strings.ToLower(strings.TrimPrefix(filepath.Ext(path), ".")) {
+	case FormatXlsx:
+		return readXlsx(path)
+	case FormatCSV:
+		return readCSV(path)
+	case FormatJSONL:
+		return readJSONL(path)
+	case FormatSQLite:
+		return readSQLite(path)
+	default:
+		return nil, nil, fmt.Errorf("unknown output format of %s", path)
+	}
+}
@@ -0,0 +1,139 @@
+package output
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+
+	// Registers the pure Go "sqlite" driver
+	_ "modernc.org/sqlite"
+)
+
+const sqliteTable = "results"
+
+// sqliteWriter writes rows into the results table of a SQLite database, one REAL column per header.
+// The writers of the further tables share the database of the results table.
+type sqliteWriter struct {
+	path   string
+	db     *sql.DB
+	table  string
+	insert *sql.Stmt
+	tables []*sqliteWriter
+}
+
+func newSQLiteWriter(path string) (*sqliteWriter, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// WAL keeps the cost of a committed row constant and the database readable during the run
+	if _, err = db.Exec("PRAGMA journal_mode=WAL; PRAGMA synchronous=NORMAL"); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &sqliteWriter{path: path, db: db, table: sqliteTable}, nil
+}
+
+func (w *sqliteWriter) Path() string {
+	return w.path
+}
+
+func (w *sqliteWriter) WriteHeader(headers []string) error {
+	columns := make([]string, len(headers))
+	placeholders := make([]string, len(headers))
+	for i, header := range headers {
+		columns[i] = quoteIdentifier(header) + " REAL"
+		placeholders[i] = "?"
+	}
+
+	table := quoteIdentifier(w.table)
+	_, err := w.db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s; CREATE TABLE %s (%s)",
+		table, table, strings.Join(columns, ", ")))
+	if err != nil {
+		return err
+	}
+
+	w.insert, err = w.db.Prepare(fmt.Sprintf("INSERT INTO %s VALUES (%s)", table, strings.Join(placeholders, ", ")))
+	return err
+}
+
+func (w *sqliteWriter) WriteRow(values []float64) error {
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+		args[i] = value
+	}
+
+	_, err := w.insert.Exec(args...)
+	return err
+}
+
+// Table writes the further table to a table of the same database.
+func (w *sqliteWriter) Table(name string) (Writer, error) {
+	table := &sqliteWriter{path: w.path, db: w.db, table: name}
+	w.tables = append(w.tables, table)
+	return table, nil
+}
+
+// Close closes the database, once the writers of the further tables are closed.
+func (w *sqliteWriter) Close() error {
+	for _, table := range w.tables {
+		_ = table.Close()
+	}
+	if w.insert != nil {
+		_ = w.insert.Close()
+	}
+	if w.table != sqliteTable {
+		return nil
+	}
+	return w.db.Close()
+}
+
+func readSQLite(path string) (headers []string, rows [][]float64, err error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer db.Close()
+
+	result, err := db.Query("SELECT * FROM " + sqliteTable + " ORDER BY rowid")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer result.Close()
+
+	headers, err = result.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	values := make([]sql.NullFloat64, len(headers))
+	pointers := make([]interface{}, len(headers))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for result.Next() {
+		if err = result.Scan(pointers...); err != nil {
+			return nil, nil, err
+		}
+		row := make([]float64, len(headers))
+		for i, value := range values {
+			row[i] = math.NaN()
+			if value.Valid {
+				row[i] = value.Float64
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return headers, rows, result.Err()
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
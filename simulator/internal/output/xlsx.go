@@ -0,0 +1,410 @@
+package output
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	sheetPrefix   = "Sheet"
+	metaSheet     = "Meta"
+	journalSuffix = ".journal.csv"
+)
+
+// xlsxWriter appends rows to a CSV journal next to the Excel file, so that writing a row costs the same
+// however long the run is and a crashed run still leaves every row written so far.
+// The journal is converted to the Excel file on Close and removed; until then ReadTable reads the journal.
+type xlsxWriter struct {
+	path     string
+	rowLimit int
+	rollover string
+	journal  *csvWriter
+	tables   []*xlsxTable
+
+	metaKeys   []string
+	metaValues map[string]string
+}
+
+// xlsxTable journals a further table like the results table. It is converted to sheets named after
+// the table, continued on "<name> 2", "<name> 3", ... once a sheet is full.
+type xlsxTable struct {
+	*csvWriter
+	name string
+	path string
+}
+
+func (t *xlsxTable) Path() string {
+	return t.path
+}
+
+func newXlsxWriter(path string, options Options) (*xlsxWriter, error) {
+	rowLimit := options.XlsxRowLimit
+	if rowLimit <= 0 || rowLimit > MaxXlsxRows {
+		rowLimit = MaxXlsxRows
+	}
+	if rowLimit < 2 {
+		return nil, fmt.Errorf("xlsx row limit %d leaves no room for data", rowLimit)
+	}
+
+	rollover := options.XlsxRollover
+	switch rollover {
+	case "":
+		rollover = RolloverSheets
+	case RolloverSheets, RolloverFiles:
+	default:
+		return nil, fmt.Errorf("unknown xlsx rollover %q", rollover)
+	}
+
+	journal, err := newCSVWriter(path + journalSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &xlsxWriter{path: path, rowLimit: rowLimit, rollover: rollover, journal: journal}, nil
+}
+
+func (w *xlsxWriter) Path() string {
+	return w.path
+}
+
+func (w *xlsxWriter) WriteHeader(headers []string) error {
+	return w.journal.WriteHeader(headers)
+}
+
+func (w *xlsxWriter) WriteRow(values []float64) error {
+	return w.journal.WriteRow(values)
+}
+
+// SetMetadata adds a Meta sheet of key-value rows to the first file of the table.
+func (w *xlsxWriter) SetMetadata(keys []string, values map[string]string) {
+	w.metaKeys = keys
+	w.metaValues = values
+}
+
+// Table journals the further table next to the Excel file; it becomes sheets of the first file on Close.
+func (w *xlsxWriter) Table(name string) (Writer, error) {
+	journal, err := newCSVWriter(fmt.Sprintf("%s.%s%s", w.path, strings.ReplaceAll(strings.ToLower(name), " ", "_"), journalSuffix))
+	if err != nil {
+		return nil, err
+	}
+	table := &xlsxTable{csvWriter: journal, name: name, path: w.path}
+	w.tables = append(w.tables, table)
+	return table, nil
+}
+
+func (w *xlsxWriter) Close() error {
+	if err := w.journal.Close(); err != nil {
+		return err
+	}
+	for _, table := range w.tables {
+		if err := table.csvWriter.Close(); err != nil {
+			return err
+		}
+	}
+
+	if err := w.convertJournal(); err != nil {
+		return err
+	}
+
+	for _, table := range w.tables {
+		if err := os.Remove(table.csvWriter.Path()); err != nil {
+			return err
+		}
+	}
+	return os.Remove(w.journal.Path())
+}
+
+// xlsxPart is the sheet being written by convertJournal.
+type xlsxPart struct {
+	file   *excelize.File
+	path   string
+	writer *excelize.StreamWriter
+	rows   int
+}
+
+// convertJournal writes the rows of the CSV journal to the Excel file, streaming them one by one.
+// Once a sheet holds rowLimit rows, the table continues on the next sheet or part file,
+// which starts with the header again. Values that parse as numbers are written as numbers.
+func (w *xlsxWriter) convertJournal() error {
+	journal, err := os.Open(w.journal.Path())
+	if err != nil {
+		return err
+	}
+	defer journal.Close()
+
+	reader := csv.NewReader(journal)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return err
+	}
+
+	part := &xlsxPart{file: excelize.NewFile(), path: w.path}
+	defer func() { _ = part.file.Close() }()
+	if err = w.writeMeta(part.file); err != nil {
+		return err
+	}
+	for _, table := range w.tables {
+		if err = w.convertTable(part, table); err != nil {
+			return err
+		}
+	}
+	if err = part.startSheet(sheetName(1), header); err != nil {
+		return err
+	}
+
+	for sheetNumber, partNumber := 1, 1; ; {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if part.rows == w.rowLimit {
+			if w.rollover == RolloverFiles {
+				if err = part.save(); err != nil {
+					return err
+				}
+				partNumber++
+				part = &xlsxPart{file: excelize.NewFile(), path: PartPath(w.path, partNumber)}
+			} else {
+				if err = part.writer.Flush(); err != nil {
+					return err
+				}
+				sheetNumber++
+				if _, err = part.file.NewSheet(sheetName(sheetNumber)); err != nil {
+					return err
+				}
+			}
+			if err = part.startSheet(sheetName(sheetNumber), header); err != nil {
+				return err
+			}
+		}
+
+		if err = part.writeRow(record); err != nil {
+			return err
+		}
+	}
+
+	return part.save()
+}
+
+// convertTable writes the rows of the journal of a further table to its sheets of the part.
+func (w *xlsxWriter) convertTable(part *xlsxPart, table *xlsxTable) error {
+	journal, err := os.Open(table.csvWriter.Path())
+	if err != nil {
+		return err
+	}
+	defer journal.Close()
+
+	reader := csv.NewReader(journal)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for sheetNumber := 1; ; sheetNumber++ {
+		name := table.name
+		if sheetNumber > 1 {
+			name = fmt.Sprintf("%s %d", table.name, sheetNumber)
+		}
+		if _, err = part.file.NewSheet(name); err != nil {
+			return err
+		}
+		if err = part.startSheet(name, header); err != nil {
+			return err
+		}
+
+		for part.rows < w.rowLimit {
+			record, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				return part.writer.Flush()
+			}
+			if err != nil {
+				return err
+			}
+			if err = part.writeRow(record); err != nil {
+				return err
+			}
+		}
+		if err = part.writer.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+func (w *xlsxWriter) writeMeta(file *excelize.File) error {
+	if len(w.metaKeys) == 0 {
+		return nil
+	}
+
+	if _, err := file.NewSheet(metaSheet); err != nil {
+		return err
+	}
+	if err := file.SetSheetRow(metaSheet, "A1", &[]interface{}{"Key", "Value"}); err != nil {
+		return err
+	}
+	for i, key := range w.metaKeys {
+		cell, err := excelize.CoordinatesToCellName(1, i+2)
+		if err != nil {
+			return err
+		}
+		if err = file.SetSheetRow(metaSheet, cell, &[]interface{}{key, w.metaValues[key]}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *xlsxPart) startSheet(name string, header []string) error {
+	writer, err := p.file.NewStreamWriter(name)
+	if err != nil {
+		return err
+	}
+	p.writer = writer
+	p.rows = 0
+
+	return p.writeRow(header)
+}
+
+func (p *xlsxPart) writeRow(record []string) error {
+	cells := make([]interface{}, len(record))
+	for j, value := range record {
+		if number, parseErr := strconv.ParseFloat(value, 64); parseErr == nil && !strings.EqualFold(value, "NaN") {
+			cells[j] = number
+		} else {
+			cells[j] = value
+		}
+	}
+
+	p.rows++
+	cell, err := excelize.CoordinatesToCellName(1, p.rows)
+	if err != nil {
+		return err
+	}
+	return p.writer.SetRow(cell, cells)
+}
+
+func (p *xlsxPart) save() error {
+	if err := p.writer.Flush(); err != nil {
+		return err
+	}
+	if err := p.file.SaveAs(p.path); err != nil {
+		return err
+	}
+	return p.file.Close()
+}
+
+func sheetName(number int) string {
+	return sheetPrefix + strconv.Itoa(number)
+}
+
+// PartPath returns the path of the numbered part file of an xlsx table: result.xlsx -> result_part2.xlsx.
+func PartPath(path string, number int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s_part%d%s", strings.TrimSuffix(path, ext), number, ext)
+}
+
+// readXlsx reads the table from Sheet1, Sheet2, ... of the file and then of its part files, in order.
+// A table never closed, e.g. by a run that crashed or was killed, is read from its journal.
+func readXlsx(path string) (headers []string, rows [][]float64, err error) {
+	if _, err = os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		if _, journalErr := os.Stat(path + journalSuffix); journalErr == nil {
+			return readCSV(path + journalSuffix)
+		}
+	}
+
+	paths := []string{path}
+	for number := 2; ; number++ {
+		partPath := PartPath(path, number)
+		if _, err = os.Stat(partPath); err != nil {
+			break
+		}
+		paths = append(paths, partPath)
+	}
+
+	for _, partPath := range paths {
+		records, err := readXlsxSheets(partPath)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, sheet := range records {
+			if len(sheet) == 0 {
+				continue
+			}
+			if headers == nil {
+				headers = sheet[0]
+			}
+			for _, record := range sheet[1:] {
+				row := make([]float64, len(headers))
+				for i := range row {
+					if i < len(record) {
+						row[i], _ = strconv.ParseFloat(record[i], 64)
+					}
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	if headers == nil {
+		return nil, nil, errors.New("no data")
+	}
+
+	return headers, rows, nil
+}
+
+// readXlsxSheets returns the rows of every SheetN of the file, ordered by N.
+func readXlsxSheets(path string) ([][][]string, error) {
+	file, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	sheetPattern := regexp.MustCompile(`^` + sheetPrefix + `(\d+)$`)
+	numbers := make(map[string]int)
+	var names []string
+	for _, name := range file.GetSheetList() {
+		match := sheetPattern.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+		numbers[name], _ = strconv.Atoi(match[1])
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return numbers[names[i]] < numbers[names[j]]
+	})
+
+	sheets := make([][][]string, 0, len(names))
+	for _, name := range names {
+		records, err := file.GetRows(name)
+		if err != nil {
+			return nil, err
+		}
+		sheets = append(sheets, records)
+	}
+
+	return sheets, nil
+}
@@ -0,0 +1,129 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestXlsxRollover(t *testing.T) {
+	headers := []string{"time", "coverage"}
+	var rows [][]float64
+	for i := range 8 {
+		rows = append(rows, []float64{float64(i), float64(i) / 10})
+	}
+
+	for _, rollover := range []string{RolloverSheets, RolloverFiles} {
+		t.Run(rollover, func(t *testing.T) {
+			base := filepath.Join(t.TempDir(), "result")
+			// Three data rows fit a sheet after the header
+			writer, err := New(FormatXlsx, base, Options{XlsxRowLimit: 4, XlsxRollover: rollover})
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := writer.Path()
+			writer.(MetadataWriter).SetMetadata([]string{"seed"}, map[string]string{"seed": "1"})
+			table, err := writer.(TableWriter).Table("Pair correlation")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = writer.WriteHeader(headers); err != nil {
+				t.Fatal(err)
+			}
+			if err = table.WriteHeader([]string{"r", "g"}); err != nil {
+				t.Fatal(err)
+			}
+			for i, row := range rows {
+				if err = writer.WriteRow(row); err != nil {
+					t.Fatal(err)
+				}
+				if i < 5 {
+					if err = table.WriteRow(row); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			// The journal holds every row before the table is converted, as a crashed run leaves it
+			_, journalRows, err := readCSV(path + journalSuffix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(journalRows, rows) {
+				t.Fatalf("journal rows %v, want %v", journalRows, rows)
+			}
+
+			if err = writer.Close(); err != nil {
+				t.Fatal(err)
+			}
+			journals, _ := filepath.Glob(path + "*" + journalSuffix)
+			if len(journals) != 0 {
+				t.Errorf("journals left after Close: %v", journals)
+			}
+
+			gotHeaders, gotRows, err := ReadTable(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gotHeaders, headers) || !reflect.DeepEqual(gotRows, rows) {
+				t.Errorf("read %v %v, want %v %v", gotHeaders, gotRows, headers, rows)
+			}
+
+			file, err := excelize.OpenFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			sheets := file.GetSheetList()
+			for _, sheet := range []string{metaSheet, "Pair correlation", "Pair correlation 2", "Sheet1"} {
+				if !slices.Contains(sheets, sheet) {
+					t.Errorf("sheets %v lack %q", sheets, sheet)
+				}
+			}
+
+			_, err = os.Stat(PartPath(path, 2))
+			if rollover == RolloverFiles && err != nil {
+				t.Errorf("no second part file: %v", err)
+			}
+			if rollover == RolloverSheets {
+				if err == nil {
+					t.Error("a part file was written with sheet rollover")
+				}
+				if !slices.Contains(sheets, "Sheet3") {
+					t.Errorf("sheets %v lack Sheet3", sheets)
+				}
+			}
+		})
+	}
+}
+
+func TestXlsxWithoutClose(t *testing.T) {
+	writer, err := New(FormatXlsx, filepath.Join(t.TempDir(), "result"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := []string{"time", "coverage"}
+	rows := [][]float64{{0, 0}, {1, 0.25}, {2, 0.5}}
+	if err = writer.WriteHeader(headers); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err = writer.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A crashed run never closes the writer: the table is read from the journal
+	gotHeaders, gotRows, err := ReadTable(writer.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotHeaders, headers) || !reflect.DeepEqual(gotRows, rows) {
+		t.Errorf("read %v %v, want %v %v", gotHeaders, gotRows, headers, rows)
+	}
+}
@@ -0,0 +1,173 @@
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+// Bar modes.
+const (
+	// BarAuto shows the bar when stderr is a terminal.
+	BarAuto = "auto"
+	BarOn   = "on"
+	BarOff  = "off"
+)
+
+// StdoutOutput writes the JSON-lines stream to the standard output instead of a file.
+const StdoutOutput = "stdout"
+
+const barWidth = 30
+
+// Report is a snapshot of the progress of a run.
+type Report struct {
+	PhysicalTime   float64 `json:"physicalTime"`
+	SimulationTime float64 `json:"simulationTime"`
+	// Share of the simulation time reached; 0 without a positive simulation time
+	Percent         float64 `json:"percent"`
+	Events          int64   `json:"events"`
+	EventsPerSecond float64 `json:"eventsPerSecond"`
+	WallTime        float64 `json:"wallTimeSeconds"`
+	// Estimated wall time left in seconds; -1 until the physical time has advanced or without a positive
+	// simulation time
+	ETA          float64 `json:"etaSeconds"`
+	Coverage     float64 `json:"coverage"`
+	StableChecks int     `json:"stableChecks"`
+	Done         bool    `json:"done,omitempty"`
+}
+
+// NewReport computes the rates and the ETA of a snapshot from the time the run started.
+// A simulation time of 0 leaves the percent at 0 and the ETA unknown, so that the report can be encoded as JSON.
+func NewReport(startedAt time.Time, physicalTime, simulationTime float64, events int64) Report {
+	wallTime := time.Since(startedAt).Seconds()
+	report := Report{
+		PhysicalTime:   physicalTime,
+		SimulationTime: simulationTime,
+		Events:         events,
+		WallTime:       wallTime,
+		ETA:            -1,
+	}
+	if wallTime > 0 {
+		report.EventsPerSecond = float64(events) / wallTime
+	}
+	if simulationTime > 0 {
+		report.Percent = math.Min(100, physicalTime/simulationTime*100)
+		if physicalTime > 0 {
+			report.ETA = math.Max(0, wallTime*(simulationTime-physicalTime)/physicalTime)
+		}
+	}
+
+	return report
+}
+
+// Reporter sends progress reports to a JSON-lines stream and/or draws a progress bar.
+// The zero value reports nothing.
+type Reporter struct {
+	interval time.Duration
+	last     time.Time
+
+	stream      io.Writer
+	streamClose func() error
+	bar         io.Writer
+}
+
+// New creates a reporter. output is "" for no stream, StdoutOutput or a file path; interval is the minimal
+// wall time between reports in seconds, 1 when not positive; bar is BarAuto, BarOn or BarOff.
+func New(output string, interval float64, bar string) (*Reporter, error) {
+	if interval <= 0 {
+		interval = 1
+	}
+	r := &Reporter{interval: time.Duration(interval * float64(time.Second))}
+
+	switch output {
+	case "":
+	case StdoutOutput:
+		r.stream = os.Stdout
+	default:
+		file, err := os.Create(output)
+		if err != nil {
+			return nil, err
+		}
+		r.stream = file
+		r.streamClose = file.Close
+	}
+
+	switch bar {
+	case "", BarAuto:
+		if isatty.IsTerminal(os.Stderr.Fd()) || isatty.IsCygwinTerminal(os.Stderr.Fd()) {
+			r.bar = os.Stderr
+		}
+	case BarOn:
+		r.bar = os.Stderr
+	case BarOff:
+	default:
+		return nil, fmt.Errorf("unknown progress bar mode %q", bar)
+	}
+
+	return r, nil
+}
+
+// Enabled reports whether the reporter has anywhere to report to.
+func (r *Reporter) Enabled() bool {
+	return r != nil && (r.stream != nil || r.bar != nil)
+}
+
+// HasBar reports whether a progress bar is drawn, in which case progress log lines would only break it.
+func (r *Reporter) HasBar() bool {
+	return r != nil && r.bar != nil
+}
+
+// Due reports whether the interval has passed since the last report.
+func (r *Reporter) Due() bool {
+	return r.Enabled() && time.Since(r.last) >= r.interval
+}
+
+// Report writes the report to the stream and redraws the bar.
+func (r *Reporter) Report(report Report) error {
+	if !r.Enabled() {
+		return nil
+	}
+	r.last = time.Now()
+
+	if r.stream != nil {
+		content, err := json.Marshal(report)
+		if err != nil {
+			return err
+		}
+		if _, err = r.stream.Write(append(content, '\n')); err != nil {
+			return err
+		}
+	}
+
+	if r.bar != nil {
+		filled := int(report.Percent / 100 * barWidth)
+		eta := "?"
+		if report.ETA >= 0 {
+			eta = (time.Duration(report.ETA) * time.Second).String()
+		}
+		fmt.Fprintf(r.bar, "\r\033[K[%s%s] %5.1f%% t=%.3g ev/s=%.3g coverage=%.3f ETA %s",
+			strings.Repeat("#", filled), strings.Repeat(".", barWidth-filled),
+			report.Percent, report.PhysicalTime, report.EventsPerSecond, report.Coverage, eta)
+		if report.Done {
+			fmt.Fprintln(r.bar)
+		}
+	}
+
+	return nil
+}
+
+// Close closes the stream file.
+func (r *Reporter) Close() error {
+	if r == nil || r.streamClose == nil {
+		return nil
+	}
+	closeStream := r.streamClose
+	r.streamClose = nil
+	return closeStream()
+}
@@ -0,0 +1,27 @@
+package progress
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestNewReportEncodes(t *testing.T) {
+	startedAt := time.Now().Add(-time.Second)
+	for _, simulationTime := range []float64{0, 1e-3} {
+		for _, physicalTime := range []float64{0, 5e-4} {
+			report := NewReport(startedAt, physicalTime, simulationTime, 10)
+			if _, err := json.Marshal(report); err != nil {
+				t.Errorf("simulation time %g, physical time %g: %v", simulationTime, physicalTime, err)
+			}
+		}
+	}
+
+	report := NewReport(startedAt, 5e-4, 1e-3, 10)
+	if report.Percent != 50 || report.ETA <= 0 {
+		t.Errorf("halfway report has percent %g and ETA %g", report.Percent, report.ETA)
+	}
+	if report = NewReport(startedAt, 5e-4, 0, 10); report.Percent != 0 || report.ETA != -1 {
+		t.Errorf("report without simulation time has percent %g and ETA %g", report.Percent, report.ETA)
+	}
+}
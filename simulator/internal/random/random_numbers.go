@@ -2,27 +2,95 @@ package random
 
 import (
 	"crypto/rand"
+	"encoding"
+	"encoding/binary"
+	"errors"
 	"log/slog"
-	"math"
-	"math/big"
+	"math/bits"
+	mathrand "math/rand/v2"
 )
 
-// Float64 generate number from [0, 1). Precision is 12
-func Float64() float64 {
-	precision := 12
-	randomNumber, err := rand.Int(rand.Reader, big.NewInt(int64(math.Pow10(precision))))
-	if err != nil {
-		slog.Error("Error generating random number", "error", err)
+// Rand is the source of every random number of a run: a PCG generator, so that a run can be repeated
+// by reusing its seed, or a source supplied by the caller. It is not safe for concurrent use; every run
+// owns its own generator.
+type Rand struct {
+	src  mathrand.Source
+	seed uint64
+}
+
+// New returns a generator started from the seed.
+func New(seed uint64) *Rand {
+	return &Rand{
+		src:  mathrand.NewPCG(seed, seed^0x9e3779b97f4a7c15),
+		seed: seed,
 	}
+}
 
-	return float64(randomNumber.Int64()) / math.Pow10(precision)
+// FromSource returns a generator drawing from src. Its seed is 0: the run can only be repeated by
+// supplying the same source again.
+func FromSource(src mathrand.Source) *Rand {
+	return &Rand{src: src}
 }
 
-func Int(n int) int {
-	randomNumber, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
-	if err != nil {
-		slog.Error("Error generating random number", "error", err)
+// NewSeed returns a random seed.
+func NewSeed() uint64 {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		slog.Error("Error generating random seed", "error", err)
+	}
+
+	return binary.LittleEndian.Uint64(buf[:])
+}
+
+// Seed returns the seed the generator was started from, 0 for a source supplied by the caller.
+func (r *Rand) Seed() uint64 {
+	return r.seed
+}
+
+// MarshalBinary returns the state of the generator, to continue its sequence with UnmarshalBinary.
+// It fails for a source supplied by the caller that cannot be saved.
+func (r *Rand) MarshalBinary() ([]byte, error) {
+	marshaler, ok := r.src.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, errors.New("random: the source cannot be saved")
+	}
+	return marshaler.MarshalBinary()
+}
+
+// UnmarshalBinary restores a state returned by MarshalBinary.
+func (r *Rand) UnmarshalBinary(data []byte) error {
+	unmarshaler, ok := r.src.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return errors.New("random: the source cannot be restored")
+	}
+	return unmarshaler.UnmarshalBinary(data)
+}
+
+// Float64 returns a number from [0, 1) with 53 random bits.
+func (r *Rand) Float64() float64 {
+	return float64(r.src.Uint64()>>11) / (1 << 53)
+}
+
+// Uint64 returns a uniformly distributed 64-bit number, e.g. the seed of another generator.
+func (r *Rand) Uint64() uint64 {
+	return r.src.Uint64()
+}
+
+// Int returns a number from [0, n). It panics if n is not positive.
+func (r *Rand) Int(n int) int {
+	if n <= 0 {
+		panic("random: invalid argument to Int")
+	}
+
+	// Lemire's multiply-shift with rejection: unbiased and almost never divides
+	bound := uint64(n)
+	hi, lo := bits.Mul64(r.src.Uint64(), bound)
+	if lo < bound {
+		threshold := -bound % bound
+		for lo < threshold {
+			hi, lo = bits.Mul64(r.src.Uint64(), bound)
+		}
 	}
 
-	return int(randomNumber.Int64())
+	return int(hi)
 }
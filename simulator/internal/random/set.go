@@ -0,0 +1,76 @@
+package random
+
+// Set is a set of non-negative integers that returns a random member in constant time.
+// The members are kept in a dense slice and the position of every value in an index slice,
+// so that adding and removing are constant time and do not allocate once the set has grown.
+type Set struct {
+	members []int32
+	// Position of every value in members, -1 when the value is not a member
+	index []int32
+}
+
+// NewSet returns an empty set with room for values from [0, capacity).
+func NewSet(capacity int) *Set {
+	index := make([]int32, capacity)
+	for i := range index {
+		index[i] = -1
+	}
+
+	return &Set{
+		members: make([]int32, 0, capacity),
+		index:   index,
+	}
+}
+
+func (s *Set) Add(value int) {
+	for value >= len(s.index) {
+		s.index = append(s.index, -1)
+	}
+	if s.index[value] >= 0 {
+		return
+	}
+
+	s.index[value] = int32(len(s.members))
+	s.members = append(s.members, int32(value))
+}
+
+func (s *Set) Remove(value int) {
+	if !s.Contains(value) {
+		return
+	}
+
+	// The last member takes the place of the removed one
+	position := s.index[value]
+	last := s.members[len(s.members)-1]
+	s.members[position] = last
+	s.index[last] = position
+	s.members = s.members[:len(s.members)-1]
+	s.index[value] = -1
+}
+
+func (s *Set) Contains(value int) bool {
+	return value < len(s.index) && s.index[value] >= 0
+}
+
+func (s *Set) Len() int {
+	return len(s.members)
+}
+
+// MemoryUsage returns the number of bytes allocated for the members and their positions.
+func (s *Set) MemoryUsage() int {
+	return 4 * (cap(s.members) + cap(s.index))
+}
+
+// Random returns a uniformly chosen member, or false if the set is empty.
+func (s *Set) Random(r *Rand) (int, bool) {
+	if len(s.members) == 0 {
+		return 0, false
+	}
+
+	return int(s.members[r.Int(len(s.members))]), true
+}
+
+// Members returns the members in no particular order. The slice is only valid until the set changes.
+func (s *Set) Members() []int32 {
+	return s.members
+}
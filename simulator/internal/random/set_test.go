@@ -0,0 +1,55 @@
+package random
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	s := NewSet(4)
+	for _, value := range []int{2, 0, 7, 2} {
+		s.Add(value)
+	}
+	if s.Len() != 3 || !s.Contains(7) || s.Contains(1) || s.Contains(100) {
+		t.Fatalf("set %v after adding 2, 0, 7, 2", s.Members())
+	}
+
+	s.Remove(2)
+	s.Remove(5)
+	if got := slices.Sorted(slices.Values(s.Members())); !slices.Equal(got, []int32{0, 7}) {
+		t.Fatalf("members %v, want [0 7]", got)
+	}
+
+	// The removed value goes back in without growing the set
+	members, index := cap(s.members), cap(s.index)
+	s.Add(2)
+	if !s.Contains(2) || cap(s.members) != members || cap(s.index) != index {
+		t.Errorf("adding a removed value grew the set from %d/%d to %d/%d", members, index, cap(s.members), cap(s.index))
+	}
+}
+
+func TestSetRandom(t *testing.T) {
+	s := NewSet(8)
+	if _, ok := s.Random(New(1)); ok {
+		t.Fatal("an empty set returned a member")
+	}
+
+	for value := range 4 {
+		s.Add(value)
+	}
+	s.Remove(1)
+	r := New(1)
+	seen := make(map[int]int)
+	for range 3000 {
+		value, ok := s.Random(r)
+		if !ok || !s.Contains(value) {
+			t.Fatalf("Random returned %d, %t", value, ok)
+		}
+		seen[value]++
+	}
+	for _, value := range []int{0, 2, 3} {
+		if seen[value] < 900 || seen[value] > 1100 {
+			t.Errorf("member %d drawn %d times of 3000", value, seen[value])
+		}
+	}
+}
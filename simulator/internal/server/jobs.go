@@ -0,0 +1,446 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/zipliZ/surface-atoms/simulator/configs"
+	"github.com/zipliZ/surface-atoms/simulator/internal/progress"
+	"github.com/zipliZ/surface-atoms/simulator/internal/simulation"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job states.
+const (
+	StateQueued    = "queued"
+	StateRunning   = "running"
+	StateCompleted = "completed"
+	StateFailed    = "failed"
+	StateCancelled = "cancelled"
+)
+
+const (
+	jobFileName      = "job.json"
+	jobConfigName    = "config.json"
+	progressFileName = "progress.jsonl"
+)
+
+var (
+	errJobNotFound = errors.New("job not found")
+	errJobFinished = errors.New("job already finished")
+)
+
+// Job is a simulation submitted to the server. Its state is persisted as job.json in the job directory.
+type Job struct {
+	ID             string     `json:"id"`
+	State          string     `json:"state"`
+	Temperature    int        `json:"temperature"`
+	SimulationTime float64    `json:"simulationTime"`
+	SubmittedAt    time.Time  `json:"submittedAt"`
+	StartedAt      *time.Time `json:"startedAt,omitempty"`
+	FinishedAt     *time.Time `json:"finishedAt,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	// Result directory relative to the job directory
+	ResultDir string `json:"resultDir,omitempty"`
+}
+
+func (j Job) finished() bool {
+	return j.State == StateCompleted || j.State == StateFailed || j.State == StateCancelled
+}
+
+// Queue runs jobs on a bounded pool of workers and keeps their state on disk, so that queued jobs
+// survive a restart of the server.
+type Queue struct {
+	dir     string
+	sources configs.Sources
+
+	mu        sync.Mutex
+	jobs      map[string]*Job
+	running   map[string]context.CancelFunc
+	cancelled map[string]bool
+	pending   chan string
+	closing   bool
+	wg        sync.WaitGroup
+}
+
+// NewQueue loads the jobs persisted in dir and starts the workers. Jobs that were queued or running
+// when the server stopped are queued again. Every job config is applied over the layers of sources.
+func NewQueue(dir string, sources configs.Sources, workers int) (*Queue, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	q := &Queue{
+		dir:       dir,
+		sources:   sources,
+		jobs:      make(map[string]*Job),
+		running:   make(map[string]context.CancelFunc),
+		cancelled: make(map[string]bool),
+	}
+
+	requeued, err := q.load()
+	if err != nil {
+		return nil, err
+	}
+
+	q.pending = make(chan string, len(requeued)+1024)
+	for _, id := range requeued {
+		q.pending <- id
+	}
+
+	for range workers {
+		q.wg.Add(1)
+		go q.work()
+	}
+
+	return q, nil
+}
+
+func (q *Queue) load() ([]string, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var requeued []*Job
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(q.dir, entry.Name(), jobFileName))
+		if err != nil {
+			continue
+		}
+
+		var job Job
+		if err = json.Unmarshal(content, &job); err != nil {
+			slog.Warn("skip unreadable job", "dir", entry.Name(), "error", err)
+			continue
+		}
+		q.jobs[job.ID] = &job
+
+		if job.State == StateRunning {
+			slog.Info("requeue job interrupted by restart", "job", job.ID)
+			job.State = StateQueued
+			job.StartedAt = nil
+			if err = q.save(&job); err != nil {
+				return nil, err
+			}
+		}
+		if job.State == StateQueued {
+			requeued = append(requeued, &job)
+		}
+	}
+
+	sort.Slice(requeued, func(i, j int) bool {
+		return requeued[i].SubmittedAt.Before(requeued[j].SubmittedAt)
+	})
+	ids := make([]string, len(requeued))
+	for i, job := range requeued {
+		ids[i] = job.ID
+	}
+
+	return ids, nil
+}
+
+// Submit stores the job config and queues the job. config is a config file in JSON applied over
+// the server config; it may be empty.
+func (q *Queue) Submit(config json.RawMessage, temperature int, simulationTime float64) (Job, error) {
+	if temperature <= 0 || simulationTime <= 0 {
+		return Job{}, errors.New("temperature and simulationTime must be positive")
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return Job{}, err
+	}
+	jobDir := filepath.Join(q.dir, id)
+	if err = os.Mkdir(jobDir, 0755); err != nil {
+		return Job{}, err
+	}
+
+	if len(config) == 0 {
+		config = json.RawMessage("{}")
+	}
+	if err = os.WriteFile(filepath.Join(jobDir, jobConfigName), config, 0644); err != nil {
+		return Job{}, err
+	}
+
+	// Reject configs that do not load now rather than when the job starts
+	if _, err = q.loadConfig(id); err != nil {
+		_ = os.RemoveAll(jobDir)
+		return Job{}, err
+	}
+
+	job := &Job{
+		ID:             id,
+		State:          StateQueued,
+		Temperature:    temperature,
+		SimulationTime: simulationTime,
+		SubmittedAt:    time.Now(),
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closing {
+		_ = os.RemoveAll(jobDir)
+		return Job{}, errors.New("server is shutting down")
+	}
+	if err = q.save(job); err != nil {
+		return Job{}, err
+	}
+	q.jobs[id] = job
+
+	select {
+	case q.pending <- id:
+	default:
+		job.State = StateFailed
+		job.Error = "queue is full"
+		_ = q.save(job)
+		return *job, errors.New("queue is full")
+	}
+
+	return *job, nil
+}
+
+// Jobs returns all jobs in the order they were submitted.
+func (q *Queue) Jobs() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := make([]Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].SubmittedAt.Before(jobs[j].SubmittedAt)
+	})
+
+	return jobs
+}
+
+// Job returns the job with the given id.
+func (q *Queue) Job(id string) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return Job{}, errJobNotFound
+	}
+	return *job, nil
+}
+
+// Cancel removes a queued job from the queue or stops a running one, keeping its partial results.
+func (q *Queue) Cancel(id string) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return Job{}, errJobNotFound
+	}
+	if job.finished() {
+		return *job, errJobFinished
+	}
+
+	q.cancelled[id] = true
+	if cancel, running := q.running[id]; running {
+		cancel()
+		return *job, nil
+	}
+
+	now := time.Now()
+	job.State = StateCancelled
+	job.FinishedAt = &now
+	return *job, q.save(job)
+}
+
+// Dir returns the directory of the job files.
+func (q *Queue) Dir(id string) string {
+	return filepath.Join(q.dir, id)
+}
+
+// Close waits for the workers after the running jobs are stopped. Stopped jobs are queued again on the next start.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	q.closing = true
+	for _, cancel := range q.running {
+		cancel()
+	}
+	close(q.pending)
+	q.mu.Unlock()
+
+	q.wg.Wait()
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+
+	for id := range q.pending {
+		q.run(id)
+	}
+}
+
+func (q *Queue) run(id string) {
+	q.mu.Lock()
+	job, ok := q.jobs[id]
+	if !ok || job.State != StateQueued || q.cancelled[id] || q.closing {
+		q.mu.Unlock()
+		return
+	}
+	q.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	simulator, err := q.start(job, cancel)
+	if err != nil {
+		q.finish(job, err, false)
+		return
+	}
+
+	err = simulator.Simulate(ctx)
+
+	q.mu.Lock()
+	delete(q.running, id)
+	cancelled := q.cancelled[id]
+	q.mu.Unlock()
+
+	q.finish(job, err, cancelled)
+}
+
+// start prepares the simulator of the job and registers cancel to stop it.
+func (q *Queue) start(job *Job, cancel context.CancelFunc) (*simulation.Simulator, error) {
+	cfg, err := q.loadConfig(job.ID)
+	if err != nil {
+		return nil, err
+	}
+	jobDir := q.Dir(job.ID)
+	cfg.Simulating.OutputDir = jobDir
+	cfg.Simulating.Progress.Output = filepath.Join(jobDir, progressFileName)
+	cfg.Simulating.Progress.Bar = progress.BarOff
+
+	simulator, err := simulation.NewSimulator(cfg, job.Temperature, job.SimulationTime)
+	if err != nil {
+		return nil, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	job.State = StateRunning
+	job.StartedAt = &now
+	job.ResultDir, _ = filepath.Rel(jobDir, simulator.ResultDir())
+	q.running[job.ID] = cancel
+	if q.cancelled[job.ID] || q.closing {
+		cancel()
+	}
+	if err = q.save(job); err != nil {
+		slog.Error("save job", "job", job.ID, "error", err)
+	}
+
+	return simulator, nil
+}
+
+func (q *Queue) finish(job *Job, err error, cancelled bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// A job stopped by Close stays running on disk and is queued again on the next start
+	if q.closing && !cancelled {
+		slog.Info("job stopped by shutdown", "job", job.ID)
+		return
+	}
+
+	now := time.Now()
+	job.FinishedAt = &now
+	switch {
+	case err != nil:
+		job.State = StateFailed
+		job.Error = err.Error()
+	case cancelled:
+		job.State = StateCancelled
+	default:
+		job.State = StateCompleted
+	}
+
+	if saveErr := q.save(job); saveErr != nil {
+		slog.Error("save job", "job", job.ID, "error", saveErr)
+	}
+	slog.Info("job finished", "job", job.ID, "state", job.State, "error", job.Error)
+}
+
+func (q *Queue) loadConfig(id string) (configs.Config, error) {
+	path := filepath.Join(q.Dir(id), jobConfigName)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return configs.Config{}, err
+	}
+	if err = checkJobConfig(content); err != nil {
+		return configs.Config{}, err
+	}
+
+	sources := q.sources
+	sources.Files = append(append([]string{}, sources.Files...), path)
+
+	cfg, _, err := configs.Load(sources)
+	if err != nil {
+		return configs.Config{}, fmt.Errorf("config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Keys of a job config naming files of the server host, which a client must not make the server read.
+var hostPathKeys = []string{"include", "presetsDir"}
+
+// checkJobConfig rejects a job config that is not a JSON object or that sets a key of hostPathKeys.
+func checkJobConfig(content []byte) error {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(content, &keys); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	for key := range keys {
+		for _, hostPathKey := range hostPathKeys {
+			if strings.EqualFold(key, hostPathKey) {
+				return fmt.Errorf("config: %s is not allowed in a job config", hostPathKey)
+			}
+		}
+	}
+	return nil
+}
+
+// save writes job.json; the caller holds q.mu or owns the job exclusively.
+func (q *Queue) save(job *Job) error {
+	content, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(q.Dir(job.ID), jobFileName)
+	if err = os.WriteFile(path+".tmp", content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func newJobID() (string, error) {
+	var buf [6]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(buf[:]), nil
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/zipliZ/surface-atoms/simulator/configs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSubmitRejectsHostPaths(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "secret.yaml")
+	if err := os.WriteFile(secret, []byte("simulating: {matrixLenX: 10}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	q, err := NewQueue(filepath.Join(dir, "jobs"), configs.Sources{}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, config := range []string{
+		`{"include": [` + quote(secret) + `]}`,
+		`{"Include": ` + quote(secret) + `}`,
+		`{"presetsDir": ` + quote(dir) + `}`,
+		`[]`,
+	} {
+		if _, err = q.Submit(json.RawMessage(config), 600, 1e-6); err == nil {
+			t.Errorf("job config %s was accepted", config)
+		}
+	}
+	if jobs := q.Jobs(); len(jobs) != 0 {
+		t.Errorf("%d jobs were queued", len(jobs))
+	}
+}
+
+func quote(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
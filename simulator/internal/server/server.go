@@ -0,0 +1,216 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Interval at which a progress stream checks the progress file for new lines.
+const progressPollInterval = 500 * time.Millisecond
+
+// SubmitRequest is the body of POST /jobs.
+type SubmitRequest struct {
+	// Config in JSON applied over the server config, e.g. {"simulating": {"matrixLenX": 500}}; include and
+	// presetsDir are rejected, as they would make the server read its own files
+	Config         json.RawMessage `json:"config,omitempty"`
+	Temperature    int             `json:"temperature"`
+	SimulationTime float64         `json:"simulationTime"`
+}
+
+// Handler returns the HTTP API of the queue:
+//
+//	POST   /jobs                    submit a job
+//	GET    /jobs                    list jobs, optionally ?state=queued|running|...
+//	GET    /jobs/{id}               job state
+//	DELETE /jobs/{id}               cancel a queued or running job
+//	GET    /jobs/{id}/progress      progress reports as JSON lines, streamed until the job ends
+//	GET    /jobs/{id}/files         result files
+//	GET    /jobs/{id}/files/{path}  download a result file
+func Handler(q *Queue) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /jobs", func(w http.ResponseWriter, r *http.Request) {
+		var request SubmitRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		job, err := q.Submit(request.Config, request.Temperature, request.SimulationTime)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, job)
+	})
+
+	mux.HandleFunc("GET /jobs", func(w http.ResponseWriter, r *http.Request) {
+		state := r.URL.Query().Get("state")
+		jobs := make([]Job, 0)
+		for _, job := range q.Jobs() {
+			if state == "" || job.State == state {
+				jobs = append(jobs, job)
+			}
+		}
+		writeJSON(w, http.StatusOK, jobs)
+	})
+
+	mux.HandleFunc("GET /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		job, err := q.Job(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	})
+
+	mux.HandleFunc("DELETE /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		job, err := q.Cancel(r.PathValue("id"))
+		switch {
+		case errors.Is(err, errJobNotFound):
+			writeError(w, http.StatusNotFound, err)
+		case errors.Is(err, errJobFinished):
+			writeError(w, http.StatusConflict, err)
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err)
+		default:
+			writeJSON(w, http.StatusAccepted, job)
+		}
+	})
+
+	mux.HandleFunc("GET /jobs/{id}/progress", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if _, err := q.Job(id); err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		streamProgress(w, r, q, id)
+	})
+
+	mux.HandleFunc("GET /jobs/{id}/files", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if _, err := q.Job(id); err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+
+		files := make([]string, 0)
+		err := filepath.WalkDir(q.Dir(id), func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			relative, err := filepath.Rel(q.Dir(id), path)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(relative))
+			return nil
+		})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, files)
+	})
+
+	mux.HandleFunc("GET /jobs/{id}/files/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if _, err := q.Job(id); err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+
+		root, err := os.OpenRoot(q.Dir(id))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		defer root.Close()
+		http.ServeFileFS(w, r, root.FS(), r.PathValue("path"))
+	})
+
+	return mux
+}
+
+// streamProgress copies the progress file of the job to the response as it grows, until the job ends
+// or the client goes away.
+func streamProgress(w http.ResponseWriter, r *http.Request, q *Queue, id string) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+
+	var offset int64
+	for {
+		job, err := q.Job(id)
+		if err != nil {
+			return
+		}
+
+		offset, err = copyLines(w, filepath.Join(q.Dir(id), progressFileName), offset)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Error("stream progress", "job", id, "error", err)
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if job.finished() {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(progressPollInterval):
+		}
+	}
+}
+
+// copyLines writes the complete lines of the file after offset and returns the offset after them.
+func copyLines(w io.Writer, path string, offset int64) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return offset, err
+	}
+	defer file.Close()
+
+	if _, err = file.Seek(offset, io.SeekStart); err != nil {
+		return offset, err
+	}
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if !strings.HasSuffix(line, "\n") {
+			// A partial line is sent once it is complete
+			return offset, nil
+		}
+		if _, writeErr := io.WriteString(w, line); writeErr != nil {
+			return offset, writeErr
+		}
+		offset += int64(len(line))
+		if err != nil {
+			return offset, nil
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		slog.Error("write response", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,207 @@
+package simulation
+
+import (
+	"cmp"
+	"fmt"
+	"github.com/zipliZ/surface-atoms/simulator/configs"
+	"log/slog"
+)
+
+// Defaults of the diffusion acceleration.
+const (
+	defaultAccelerationWindow     = 10000
+	defaultAccelerationSeparation = 100
+	defaultAccelerationStep       = 2
+)
+
+// accelerator scales the hop rate of every element down while its hops are quasi-equilibrated, i.e. much more
+// frequent than all other events, in the spirit of accelerated superbasin kMC (Chatterjee and Voter, 2010).
+// Only the hops onto free cells are scaled: they merely shuffle the atoms. The hops onto occupied cells lead
+// to recombination or to the blocked hop policy, so they are kept apart as the encounter process at the full rate.
+// After every window of events, the scale of an element is divided by step while its hops outnumber the other
+// events by more than separation*step, and multiplied back by step, up to 1, once they no longer outnumber
+// them by separation. The error is controlled by separation: the atoms still hop that many times between
+// the other events. Encounters that recombine or desorb an atom count as other events; blocked hops that
+// are rejected leave the surface as it was and count as neither.
+type accelerator struct {
+	window     int
+	separation float64
+	step       float64
+	// Per element: the scale of the hop rate, the smallest scale applied and the number of changes of the scale
+	scales     []float64
+	minScales  []float64
+	rescalings []int
+	// Counts of the current window: all events, hops onto free cells by element, encounters recombining or
+	// desorbing an atom and other events doing something
+	events     int
+	hops       []int
+	encounters int
+	others     int
+	// Per element over the run: encounters recombining or desorbing an atom and rejected blocked hops
+	encounterEvents []int64
+	blockedHops     []int64
+}
+
+func newAccelerator(cfg configs.Acceleration, elements int) (*accelerator, error) {
+	if cfg.Window < 0 || cfg.Separation < 0 || cfg.Step < 0 || (cfg.Step > 0 && cfg.Step <= 1) {
+		return nil, fmt.Errorf("acceleration window %d and separation %g must not be negative and step %g must be above 1",
+			cfg.Window, cfg.Separation, cfg.Step)
+	}
+
+	a := &accelerator{
+		window:     cmp.Or(cfg.Window, defaultAccelerationWindow),
+		separation: cmp.Or(cfg.Separation, defaultAccelerationSeparation),
+		step:       cmp.Or(cfg.Step, defaultAccelerationStep),
+		scales:     make([]float64, elements),
+		minScales:  make([]float64, elements),
+		rescalings: make([]int, elements),
+		hops:       make([]int, elements),
+
+		encounterEvents: make([]int64, elements),
+		blockedHops:     make([]int64, elements),
+	}
+	for i := range a.scales {
+		a.scales[i], a.minScales[i] = 1, 1
+	}
+	return a, nil
+}
+
+// record counts the executed event and adjusts the scales at the end of a window.
+func (a *accelerator) record(process string, element int, outcome string, elems []string) {
+	switch {
+	case outcome == OutcomeNone:
+	case process == diffusionProcess:
+		a.hops[element]++
+	case outcome == OutcomeBlockedRejected:
+		a.blockedHops[element]++
+	case process == encounterProcess:
+		a.encounters++
+		a.encounterEvents[element]++
+	default:
+		a.others++
+	}
+
+	a.events++
+	if a.events < a.window {
+		return
+	}
+
+	others := a.encounters + a.others
+	for i, hops := range a.hops {
+		scale := a.scales[i]
+		switch {
+		case float64(hops) > a.separation*a.step*float64(others):
+			scale /= a.step
+		case float64(hops) < a.separation*float64(others) && scale < 1:
+			scale = min(1, scale*a.step)
+		}
+		if scale != a.scales[i] {
+			slog.Debug("hop rate scaled", "element", elems[i], "acceleration", 1/scale, "hops", hops,
+				"encounters", a.encounters, "other_events", a.others)
+			a.scales[i] = scale
+			a.minScales[i] = min(a.minScales[i], scale)
+			a.rescalings[i]++
+		}
+		a.hops[i] = 0
+	}
+	a.events, a.encounters, a.others = 0, 0, 0
+}
+
+// Acceleration is the scaling of the hop rate of an element by the diffusion acceleration.
+type Acceleration struct {
+	// Factor the hop rate onto free cells is divided by at the end of the run
+	Factor float64 `json:"factor"`
+	// Largest factor applied during the run
+	MaxFactor float64 `json:"maxFactor"`
+	// Number of changes of the factor
+	Rescalings int `json:"rescalings"`
+	// Hops onto occupied cells that recombined or desorbed an atom, and those rejected by the blocked hop policy
+	Encounters  int64 `json:"encounters"`
+	BlockedHops int64 `json:"blockedHops"`
+}
+
+// accelerations returns the scaling applied to every element, nil when the acceleration is off.
+func (s *Simulator) accelerations() map[string]Acceleration {
+	if s.acceleration == nil {
+		return nil
+	}
+
+	accelerations := make(map[string]Acceleration, len(s.elems))
+	for i, elementName := range s.elems {
+		accelerations[elementName] = Acceleration{
+			Factor:      1 / s.acceleration.scales[i],
+			MaxFactor:   1 / s.acceleration.minScales[i],
+			Rescalings:  s.acceleration.rescalings[i],
+			Encounters:  s.acceleration.encounterEvents[i],
+			BlockedHops: s.acceleration.blockedHops[i],
+		}
+	}
+	return accelerations
+}
+
+// restoreAccelerations continues the scaling of the hop rates saved in a checkpoint.
+func (s *Simulator) restoreAccelerations(accelerations map[string]Acceleration) {
+	if s.acceleration == nil {
+		return
+	}
+	for i, elementName := range s.elems {
+		acceleration, ok := accelerations[elementName]
+		if !ok || acceleration.Factor < 1 {
+			continue
+		}
+		s.acceleration.scales[i] = 1 / acceleration.Factor
+		s.acceleration.minScales[i] = 1 / max(acceleration.MaxFactor, acceleration.Factor)
+		s.acceleration.rescalings[i] = acceleration.Rescalings
+		s.acceleration.encounterEvents[i] = acceleration.Encounters
+		s.acceleration.blockedHops[i] = acceleration.BlockedHops
+	}
+}
+
+// accelerationColumns returns the headers of the results table columns with the acceleration factor of every element.
+func accelerationColumns(elements []configs.Element) []string {
+	columns := make([]string, len(elements))
+	for i, element := range elements {
+		columns[i] = fmt.Sprintf("%s - Hop acceleration", element.Name)
+	}
+	return columns
+}
+
+// hopToFreeCell hops a random F-center atom of the element to a random neighbouring cell if it is free.
+// Hops onto occupied cells are left to the encounter process, so the event does nothing then.
+func (s *Simulator) hopToFreeCell(element int) {
+	atom, exist := s.atomsController.RandomAtom('F', element, s.rand)
+	if !exist {
+		s.setEvent(OutcomeNone, Coordinates{}, Coordinates{}, "")
+		return
+	}
+
+	from := Coordinates{X: atom.X, Y: atom.Y}
+	neighbours, count := s.atomsController.GetNeighbourCoordinates(atom, s.rand)
+	if count == 0 || s.matrix.AtomAt(neighbours[0].X, neighbours[0].Y) != 0 {
+		s.setEvent(OutcomeNone, from, from, "")
+		return
+	}
+
+	s.setEvent(OutcomeHopped, from, neighbours[0], "")
+	s.hopAtom(atom, neighbours[0], true)
+}
+
+// hopToOccupiedCell hops a random F-center atom of the element with an occupied neighbouring cell to a random
+// neighbouring cell if it is occupied, i.e. recombines it or applies the blocked hop policy.
+// Hops onto free cells are left to the diffusion process, so the event does nothing then.
+func (s *Simulator) hopToOccupiedCell(element int) {
+	atom, exist := s.atomsController.RandomContactAtom(element, s.rand)
+	if !exist {
+		s.setEvent(OutcomeNone, Coordinates{}, Coordinates{}, "")
+		return
+	}
+
+	from := Coordinates{X: atom.X, Y: atom.Y}
+	neighbours, count := s.atomsController.GetNeighbourCoordinates(atom, s.rand)
+	if count == 0 || s.matrix.AtomAt(neighbours[0].X, neighbours[0].Y) == 0 {
+		s.setEvent(OutcomeNone, from, from, "")
+		return
+	}
+
+	s.moveAtom(atom, neighbours[:count])
+}
@@ -0,0 +1,172 @@
+package simulation
+
+import (
+	"fmt"
+	"time"
+)
+
+// One event in budgetSampleEvents has its wall time measured, which keeps the clock off the other events.
+const budgetSampleEvents = 64
+
+// Names of the processes in the headers of the results.
+var processHeaders = map[string]string{
+	adsorptionFProcess: "Adsorption F",
+	adsorptionSProcess: "Adsorption S",
+	recombErProcess:    "Recomb Er",
+	desorptionFProcess: "Desorption F",
+	diffusionProcess:   "Diffusion",
+	encounterProcess:   "Encounter",
+}
+
+// processBudget counts the events of every process of every element, in the order of the rates.
+type processBudget struct {
+	// First extra column of the rates
+	column int
+	// Physical time the counts start at: the start of the run or the checkpoint it was resumed from
+	start float64
+	// Times every process was chosen since the last row of the results and since the start of the run
+	selected []int
+	events   []int64
+	// Integral of the rate of every process over the physical time
+	lambdaTime []float64
+	// Wall time of the events of every process, estimated from the sampled events, and the events
+	// left until the next sampled one
+	wallTime  []time.Duration
+	unsampled int
+}
+
+// ProcessBudget is the share of a process of an element in the events and the time of the run.
+type ProcessBudget struct {
+	// Number of times the process was chosen
+	Events int64 `json:"events"`
+	// Share of the process in the events of the run
+	EventShare float64 `json:"eventShare"`
+	// Rate of the process averaged over the physical time, in s^-1
+	MeanLambda float64 `json:"meanLambda"`
+	// Wall time spent carrying out the process in seconds, estimated from one event in 64
+	WallTime float64 `json:"wallTime"`
+	// Share of the process in the wall time spent carrying out events
+	WallTimeShare float64 `json:"wallTimeShare"`
+}
+
+func newProcessBudget(rates int) *processBudget {
+	return &processBudget{
+		selected:   make([]int, rates),
+		events:     make([]int64, rates),
+		lambdaTime: make([]float64, rates),
+		wallTime:   make([]time.Duration, rates),
+	}
+}
+
+// collect adds the counts of other and resets them.
+func (b *processBudget) collect(other *processBudget) {
+	for i := range b.selected {
+		b.selected[i] += other.selected[i]
+		b.events[i] += other.events[i]
+		b.lambdaTime[i] += other.lambdaTime[i]
+		b.wallTime[i] += other.wallTime[i]
+	}
+	clear(other.selected)
+	clear(other.events)
+	clear(other.lambdaTime)
+	clear(other.wallTime)
+}
+
+// budgetColumns returns the headers of the results table columns with the rate of every process of every
+// element and the number of times it was chosen since the previous row.
+func budgetColumns(rates []processRate, elems []string) []string {
+	columns := make([]string, 0, 2*len(rates))
+	for _, rate := range rates {
+		columns = append(columns,
+			fmt.Sprintf("%s - Lambda %s", elems[rate.element], processHeaders[rate.process]),
+			fmt.Sprintf("%s - Selected %s", elems[rate.element], processHeaders[rate.process]),
+		)
+	}
+	return columns
+}
+
+// executeChosen carries out the process getProcess chose last, which takes spendTime, and counts it in
+// the process budget.
+func (s *Simulator) executeChosen(process string, element int, spendTime float64) {
+	b := s.budget
+	if b == nil || element < 0 {
+		s.execute(process, element)
+		return
+	}
+
+	b.selected[s.chosen]++
+	b.events[s.chosen]++
+	for i, rate := range s.rates {
+		b.lambdaTime[i] += rate.lambda * spendTime
+	}
+
+	if b.unsampled > 0 {
+		b.unsampled--
+		s.execute(process, element)
+		return
+	}
+	b.unsampled = budgetSampleEvents - 1
+	started := time.Now()
+	s.execute(process, element)
+	b.wallTime[s.chosen] += time.Since(started) * budgetSampleEvents
+}
+
+// setBudgetColumns sets the rate of every process at the current state of the surface and the number of
+// times it was chosen since the previous row in the extra columns, and starts counting the next interval.
+func (s *Simulator) setBudgetColumns() {
+	extra := s.infoCollector.Extra[s.budget.column:]
+	if s.strips == nil {
+		s.updateRates()
+	}
+	for _, strip := range s.strips {
+		strip.updateRates()
+	}
+	for i := range s.rates {
+		lambda := s.rates[i].lambda
+		if s.strips != nil {
+			// The rates are extensive: the rate of the lattice is the sum of those of its strips
+			lambda = 0
+			for _, strip := range s.strips {
+				lambda += strip.rates[i].lambda
+			}
+		}
+		extra[2*i] = lambda
+		extra[2*i+1] = float64(s.budget.selected[i])
+	}
+	clear(s.budget.selected)
+}
+
+// budgets returns the budget of every process of every element, nil when it is not kept.
+func (s *Simulator) budgets() map[string]map[string]ProcessBudget {
+	if s.budget == nil {
+		return nil
+	}
+
+	var events int64
+	var wallTime time.Duration
+	for i := range s.rates {
+		events += s.budget.events[i]
+		wallTime += s.budget.wallTime[i]
+	}
+	elapsed := s.currentSimulationTime - s.budget.start
+
+	budgets := make(map[string]map[string]ProcessBudget, len(s.elems))
+	for i, rate := range s.rates {
+		elementName := s.elems[rate.element]
+		if budgets[elementName] == nil {
+			budgets[elementName] = make(map[string]ProcessBudget)
+		}
+		budget := ProcessBudget{Events: s.budget.events[i], WallTime: s.budget.wallTime[i].Seconds()}
+		if events > 0 {
+			budget.EventShare = float64(budget.Events) / float64(events)
+		}
+		if elapsed > 0 {
+			budget.MeanLambda = s.budget.lambdaTime[i] / elapsed
+		}
+		if wallTime > 0 {
+			budget.WallTimeShare = float64(s.budget.wallTime[i]) / float64(wallTime)
+		}
+		budgets[elementName][rate.process] = budget
+	}
+	return budgets
+}
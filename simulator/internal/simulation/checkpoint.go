@@ -0,0 +1,274 @@
+package simulation
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"github.com/zipliZ/surface-atoms/simulator/configs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+)
+
+// checkpointFileName is the checkpoint written next to the results of an interrupted run.
+const checkpointFileName = "checkpoint.json.gz"
+
+const checkpointVersion = 1
+
+// Checkpoint is the state of the surface, of the counters and of the random number generator at some
+// physical time. A run resumed from it continues from the same state and draws the random numbers that
+// follow those drawn before the interruption, rather than the sequence of its seed once more. A source
+// supplied by the caller is not saved: the resumed run draws from its own.
+type Checkpoint struct {
+	Version        int     `json:"version"`
+	Temperature    int     `json:"temperature"`
+	SimulationTime float64 `json:"simulationTime"`
+	PhysicalTime   float64 `json:"physicalTime"`
+	Events         int64   `json:"events"`
+	Seed           uint64  `json:"seed"`
+	MatrixLenX     int     `json:"matrixLenX"`
+	MatrixLenY     int     `json:"matrixLenY"`
+	// State of the random number generator, empty when it cannot be saved
+	RandState []byte `json:"randState,omitempty"`
+	// Config of the run as resolved at its start; a run is only resumed with the same physics
+	Config *configs.Config `json:"config,omitempty"`
+	// Names of the elements in the order of the config
+	Elements []string `json:"elements,omitempty"`
+	// Cells of the S-centers; all other cells are F-centers
+	SCenters    []Coordinates                 `json:"sCenters"`
+	Atoms       []CheckpointAtom              `json:"atoms"`
+	Info        map[string]Info               `json:"info"`
+	FormedAtoms map[string]int                `json:"formedAtoms"`
+	Rates       map[string]map[string]float64 `json:"rates"`
+	// Scaling of the hop rate of every element, when the diffusion acceleration is on
+	Acceleration map[string]Acceleration `json:"acceleration,omitempty"`
+	// Residence times of the atoms that left the surface by element and exit channel, when they are collected
+	Residence map[string]map[string]ResidenceHistogram `json:"residence,omitempty"`
+}
+
+// CheckpointAtom is an atom on the surface.
+type CheckpointAtom struct {
+	X       uint32 `json:"x"`
+	Y       uint32 `json:"y"`
+	Element string `json:"element"`
+	// Physical time the atom was adsorbed at and the type of the center it was adsorbed on
+	AdsorbedAt float64 `json:"adsorbedAt,omitempty"`
+	AdsorbedOn string  `json:"adsorbedOn,omitempty"`
+	// Displacement of the atom from the cell it was adsorbed on
+	DX int32 `json:"dx,omitempty"`
+	DY int32 `json:"dy,omitempty"`
+}
+
+func (s *Simulator) checkpoint() Checkpoint {
+	s.collectStrips()
+
+	// A source supplied by the caller may not be saved: the resumed run uses its own then
+	randState, _ := s.rand.MarshalBinary()
+	cfg := s.cfg
+
+	return Checkpoint{
+		Version:        checkpointVersion,
+		Temperature:    s.temperature,
+		SimulationTime: s.simulationTime,
+		PhysicalTime:   s.currentSimulationTime,
+		Events:         s.events,
+		Seed:           s.cfg.Simulating.Seed,
+		MatrixLenX:     s.cfg.Simulating.MatrixLenX,
+		MatrixLenY:     s.cfg.Simulating.MatrixLenY,
+		RandState:      randState,
+		Config:         &cfg,
+		Elements:       s.elems,
+		SCenters:       s.sCenters(),
+		Atoms:          checkpointAtoms(s.surfaceAtoms(), s.elems),
+		Info:           maps.Clone(s.infoCollector.Info),
+		FormedAtoms:    maps.Clone(s.infoCollector.TotalInfo.FormedAtoms),
+		Rates:          s.rateConstants(),
+		Acceleration:   s.accelerations(),
+		Residence:      s.residenceHistograms(),
+	}
+}
+
+// checkpointAtoms returns the atoms ordered by row and column.
+func checkpointAtoms(surfaceAtoms []Atom, elems []string) []CheckpointAtom {
+	atoms := make([]CheckpointAtom, 0, len(surfaceAtoms))
+	for _, atom := range surfaceAtoms {
+		checkpointAtom := CheckpointAtom{
+			X:          atom.X,
+			Y:          atom.Y,
+			Element:    elems[atom.Element],
+			AdsorbedAt: atom.AdsorbedAt,
+			DX:         atom.DX,
+			DY:         atom.DY,
+		}
+		if atom.AdsorbedOn != 0 {
+			checkpointAtom.AdsorbedOn = string(atom.AdsorbedOn)
+		}
+		atoms = append(atoms, checkpointAtom)
+	}
+	sort.Slice(atoms, func(i, j int) bool {
+		if atoms[i].Y != atoms[j].Y {
+			return atoms[i].Y < atoms[j].Y
+		}
+		return atoms[i].X < atoms[j].X
+	})
+	return atoms
+}
+
+// track returns the track of the atom, adsorbed on the given type of center when the checkpoint does not tell.
+func (a CheckpointAtom) track(center rune) Track {
+	for _, adsorbedOn := range a.AdsorbedOn {
+		center = adsorbedOn
+		break
+	}
+	return Track{AdsorbedAt: a.AdsorbedAt, AdsorbedOn: center, DX: a.DX, DY: a.DY}
+}
+
+// writeCheckpoint saves the current state in the result directory, if the run has one.
+func (s *Simulator) writeCheckpoint() error {
+	if s.dirName == "" {
+		return nil
+	}
+	return s.checkpoint().Write(filepath.Join(s.dirName, checkpointFileName))
+}
+
+// restore puts the simulator in the state of the checkpoint. The matrix must not have been initialised yet.
+func (s *Simulator) restore(checkpoint Checkpoint) error {
+	if checkpoint.Temperature != s.temperature {
+		return fmt.Errorf("checkpoint temperature %dK does not match the run temperature %dK", checkpoint.Temperature, s.temperature)
+	}
+	if checkpoint.MatrixLenX != s.cfg.Simulating.MatrixLenX || checkpoint.MatrixLenY != s.cfg.Simulating.MatrixLenY {
+		return fmt.Errorf("checkpoint matrix %dx%d does not match the config %dx%d",
+			checkpoint.MatrixLenX, checkpoint.MatrixLenY, s.cfg.Simulating.MatrixLenX, s.cfg.Simulating.MatrixLenY)
+	}
+	if checkpoint.Config != nil {
+		if err := checkResumedConfig(*checkpoint.Config, s.cfg); err != nil {
+			return err
+		}
+	}
+	if err := s.matrix.InitWithSCenters(checkpoint.MatrixLenX, checkpoint.MatrixLenY, checkpoint.SCenters); err != nil {
+		return err
+	}
+
+	for _, atom := range checkpoint.Atoms {
+		element := slices.Index(s.elems, atom.Element)
+		if element < 0 {
+			return fmt.Errorf("checkpoint atom of unknown element %q", atom.Element)
+		}
+		cell := s.matrix.GetCellInfo(atom.X, atom.Y)
+		if !cell.IsFree {
+			return fmt.Errorf("checkpoint has two atoms on cell (%d, %d)", atom.X, atom.Y)
+		}
+		s.atomsController.AddAtomOnSurface(Atom{
+			X:              atom.X,
+			Y:              atom.Y,
+			OccupiedCentre: cell.Center,
+			Element:        element,
+			Track:          atom.track(cell.Center),
+		})
+	}
+	s.restoreAccelerations(checkpoint.Acceleration)
+	s.restoreResidenceHistograms(checkpoint.Residence)
+
+	for elementName, info := range checkpoint.Info {
+		if _, known := s.infoCollector.Info[elementName]; known {
+			s.infoCollector.Info[elementName] = info
+		}
+	}
+	for formedAtomName, count := range checkpoint.FormedAtoms {
+		s.infoCollector.TotalInfo.FormedAtoms[formedAtomName] = count
+	}
+
+	s.currentSimulationTime = checkpoint.PhysicalTime
+	s.infoCollector.ElapsedTime = checkpoint.PhysicalTime
+	s.events = checkpoint.Events
+
+	// The generator continues the sequence of the interrupted run, unless the caller supplied the source
+	if len(checkpoint.RandState) > 0 && s.rand.Seed() != 0 {
+		if err := s.rand.UnmarshalBinary(checkpoint.RandState); err != nil {
+			return fmt.Errorf("checkpoint random state: %w", err)
+		}
+		s.cfg.Simulating.Seed = checkpoint.Seed
+	}
+
+	return nil
+}
+
+// checkResumedConfig returns an error naming the first setting of the physics of the run that differs
+// between the config of the checkpoint and the config it is resumed with. The outputs may differ.
+// The settings are compared as JSON, as the checkpoint stores them.
+func checkResumedConfig(saved, resumed configs.Config) error {
+	settings := []struct {
+		name           string
+		saved, resumed any
+	}{
+		{"consts", saved.Constants, resumed.Constants},
+		{"gas", saved.Gas, resumed.Gas},
+		{"elements", saved.Elements, resumed.Elements},
+		{"simulating.matrixLenX", saved.Simulating.MatrixLenX, resumed.Simulating.MatrixLenX},
+		{"simulating.matrixLenY", saved.Simulating.MatrixLenY, resumed.Simulating.MatrixLenY},
+		{"simulating.blockedHopPolicy", saved.Simulating.BlockedHopPolicy, resumed.Simulating.BlockedHopPolicy},
+		{"simulating.acceleration", saved.Simulating.Acceleration, resumed.Simulating.Acceleration},
+	}
+	for _, setting := range settings {
+		savedJSON, err := json.Marshal(setting.saved)
+		if err != nil {
+			return err
+		}
+		resumedJSON, err := json.Marshal(setting.resumed)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(savedJSON, resumedJSON) {
+			return fmt.Errorf("the config differs from the checkpoint in %s", setting.name)
+		}
+	}
+	return nil
+}
+
+// Write saves the checkpoint as gzip-compressed JSON.
+func (c Checkpoint) Write(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	writer := gzip.NewWriter(file)
+	if err = json.NewEncoder(writer).Encode(c); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// ReadCheckpoint loads a checkpoint written by Checkpoint.Write.
+func ReadCheckpoint(path string) (Checkpoint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("%s: %w", path, err)
+	}
+	defer reader.Close()
+
+	var checkpoint Checkpoint
+	if err = json.NewDecoder(reader).Decode(&checkpoint); err != nil {
+		return Checkpoint{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if checkpoint.Version != checkpointVersion {
+		return Checkpoint{}, fmt.Errorf("%s: unsupported checkpoint version %d", path, checkpoint.Version)
+	}
+
+	return checkpoint, nil
+}
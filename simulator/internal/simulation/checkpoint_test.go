@@ -0,0 +1,76 @@
+package simulation
+
+import (
+	"github.com/zipliZ/surface-atoms/simulator/configs"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	cfg := testConfig(t, 30)
+	s := newTestSimulator(t, cfg, 1)
+	for range 5000 {
+		s.step()
+	}
+
+	path := filepath.Join(t.TempDir(), checkpointFileName)
+	if err := s.checkpoint().Write(path); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint, err := ReadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed := newTestSimulator(t, cfg, 1, WithCheckpoint(checkpoint))
+	if got, want := resumed.checkpoint(), s.checkpoint(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("resumed state differs from the checkpoint:\n%+v\n%+v", got, want)
+	}
+	if got, want := resumed.rand.Uint64(), s.rand.Uint64(); got != want {
+		t.Errorf("resumed random sequence draws %d, the interrupted run %d", got, want)
+	}
+}
+
+func TestCheckpointConfigMismatch(t *testing.T) {
+	cfg := testConfig(t, 30)
+	s := newTestSimulator(t, cfg, 1)
+	for range 1000 {
+		s.step()
+	}
+	checkpoint := s.checkpoint()
+
+	changed := cfg
+	changed.Elements = append([]configs.Element(nil), cfg.Elements...)
+	changed.Elements[0].Edes++
+	if _, err := NewSimulator(changed, 600, 1, WithoutFiles(), WithCheckpoint(checkpoint)); err == nil {
+		t.Error("a run was resumed with another desorption energy")
+	}
+
+	// The outputs may change
+	changed = cfg
+	changed.Simulating.Outputs = []string{"csv"}
+	changed.Simulating.ProcessBudget = true
+	if _, err := NewSimulator(changed, 600, 1, WithoutFiles(), WithCheckpoint(checkpoint)); err != nil {
+		t.Errorf("resuming with other outputs: %v", err)
+	}
+}
+
+func TestCheckpointKeepsAcceleration(t *testing.T) {
+	cfg := testConfig(t, 30)
+	cfg.Simulating.Acceleration = configs.Acceleration{Enabled: true, Window: 100, Separation: 1}
+	cfg.Simulating.BlockedHopPolicy = BlockedHopReject
+	s := newTestSimulator(t, cfg, 1)
+	for range 20000 {
+		s.step()
+		s.acceleration.record(s.rates[s.chosen].process, s.rates[s.chosen].element, s.event.Outcome, s.elems)
+	}
+	accelerations := s.accelerations()
+	if accelerations[s.elems[0]].Factor == 1 {
+		t.Fatal("the hops were not accelerated")
+	}
+
+	resumed := newTestSimulator(t, cfg, 1, WithCheckpoint(s.checkpoint()))
+	if got := resumed.accelerations(); !reflect.DeepEqual(got, accelerations) {
+		t.Errorf("resumed acceleration %+v, want %+v", got, accelerations)
+	}
+}
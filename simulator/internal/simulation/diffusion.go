@@ -0,0 +1,198 @@
+package simulation
+
+import (
+	"fmt"
+	"github.com/zipliZ/surface-atoms/simulator/configs"
+	"github.com/zipliZ/surface-atoms/simulator/internal/output"
+	"math"
+	"slices"
+)
+
+// Bins of the mean squared displacement table per decade of the time on the surface.
+const displacementBinsPerDecade = 5
+
+// displacementTable is the name of the further table of the mean squared displacement.
+const displacementTable = "Mean squared displacement"
+
+// tracerDiffusion estimates the tracer diffusion coefficient of every element from the displacements of its
+// atoms on the surface at every row of the results: the squared displacement r² of every atom from the cell
+// it was adsorbed on grows as 4·D·t with the time t it has spent on the surface, so D is fitted by least
+// squares through the origin. Blocking, S-centers, where atoms do not hop, and the edges of the lattice
+// all slow it down below the diffusion coefficient of a lone atom hopping on F-centers.
+type tracerDiffusion struct {
+	// First extra column of the diffusion coefficients
+	column int
+	// Area of a site in cm², the square of the hop length
+	siteArea float64
+	// Further tables of the mean squared displacement by time on the surface
+	tables []output.Writer
+}
+
+// displacements sums the displacements of the atoms of an element on the surface.
+type displacements struct {
+	atoms int
+	// Sums of the times on the surface t, of the squared displacements r² in cells, of r²·t and of t²
+	time        float64
+	squared     float64
+	squaredTime float64
+	timeSquared float64
+	// Number of atoms and sum of their squared displacements per bin of the time on the surface
+	binAtoms   map[int]int
+	binSquared map[int]float64
+}
+
+// Diffusion is the tracer diffusion of the atoms of an element on the surface at the end of the run.
+type Diffusion struct {
+	Atoms int `json:"atoms"`
+	// Mean time the atoms have spent on the surface in seconds
+	MeanTime float64 `json:"meanTime"`
+	// Mean squared displacement of the atoms from the cell they were adsorbed on in cm²
+	MSD float64 `json:"msd"`
+	// Tracer diffusion coefficient fitted to the squared displacements in cm²/s, 0 without atoms
+	Coefficient float64 `json:"coefficient"`
+	// Diffusion coefficient of a lone atom hopping on F-centers at the diffusion rate r5: r5·a²/4 in cm²/s
+	FreeCoefficient float64 `json:"freeCoefficient"`
+}
+
+func newTracerDiffusion(constants configs.Constants, column int) *tracerDiffusion {
+	return &tracerDiffusion{column: column, siteArea: 1 / (constants.FDensity + constants.SDensity)}
+}
+
+// diffusionColumns returns the headers of the results table columns with the tracer diffusion of every element.
+func diffusionColumns(elements []configs.Element) []string {
+	var columns []string
+	for _, element := range elements {
+		columns = append(columns,
+			fmt.Sprintf("%s - Mean time on surface", element.Name),
+			fmt.Sprintf("%s - Mean squared displacement", element.Name),
+			fmt.Sprintf("%s - Diffusion coefficient", element.Name),
+			fmt.Sprintf("%s - Diffusion coefficient ratio", element.Name),
+		)
+	}
+	return columns
+}
+
+// openTables creates the further table of the mean squared displacement in the writers able to store it.
+func (d *tracerDiffusion) openTables(writers []output.Writer, elems []string) error {
+	headers := []string{"Simulation time", "Time on surface from", "Time on surface to"}
+	for _, element := range elems {
+		headers = append(headers,
+			fmt.Sprintf("%s - Atoms", element),
+			fmt.Sprintf("%s - Mean squared displacement", element),
+		)
+	}
+
+	var err error
+	d.tables, err = openTables(writers, displacementTable, headers)
+	return err
+}
+
+// measureDisplacements sums the displacements of the atoms on the surface by element.
+func (s *Simulator) measureDisplacements() []displacements {
+	measured := make([]displacements, len(s.elems))
+	for element := range measured {
+		measured[element].binAtoms = make(map[int]int)
+		measured[element].binSquared = make(map[int]float64)
+	}
+
+	for _, atom := range s.surfaceAtoms() {
+		m := &measured[atom.Element]
+		t := s.currentSimulationTime - atom.AdsorbedAt
+		squared := float64(atom.DX)*float64(atom.DX) + float64(atom.DY)*float64(atom.DY)
+		m.atoms++
+		m.time += t
+		m.squared += squared
+		m.squaredTime += squared * t
+		m.timeSquared += t * t
+		if t > 0 {
+			bin := int(math.Floor(math.Log10(t) * displacementBinsPerDecade))
+			m.binAtoms[bin]++
+			m.binSquared[bin] += squared
+		}
+	}
+	return measured
+}
+
+// coefficient returns the diffusion coefficient fitted to the displacements in cm²/s, NaN when the atoms
+// have not spent any time on the surface.
+func (m displacements) coefficient(siteArea float64) float64 {
+	if m.timeSquared == 0 {
+		return math.NaN()
+	}
+	return m.squaredTime * siteArea / (4 * m.timeSquared)
+}
+
+// freeDiffusionCoefficient returns the diffusion coefficient of a lone atom of the element in cm²/s.
+func (s *Simulator) freeDiffusionCoefficient(element int) float64 {
+	return s.meta[element].r5 * s.diffusion.siteArea / 4
+}
+
+// writeTracerDiffusion sets the tracer diffusion of every element in the extra columns and writes the mean
+// squared displacement by time on the surface to the further tables.
+func (s *Simulator) writeTracerDiffusion() error {
+	d := s.diffusion
+	measured := s.measureDisplacements()
+
+	extra := s.infoCollector.Extra[d.column:]
+	var bins []int
+	for element, m := range measured {
+		extra[0], extra[1], extra[2], extra[3] = math.NaN(), math.NaN(), math.NaN(), math.NaN()
+		if m.atoms > 0 {
+			extra[0] = m.time / float64(m.atoms)
+			extra[1] = m.squared * d.siteArea / float64(m.atoms)
+			extra[2] = m.coefficient(d.siteArea)
+			extra[3] = extra[2] / s.freeDiffusionCoefficient(element)
+		}
+		extra = extra[4:]
+
+		for bin := range m.binAtoms {
+			bins = append(bins, bin)
+		}
+	}
+	if len(bins) == 0 {
+		return nil
+	}
+
+	time := roundToDecimals(s.currentSimulationTime, s.cfg.Simulating.FloatPrecision)
+	for bin := slices.Min(bins); bin <= slices.Max(bins); bin++ {
+		row := []float64{
+			time,
+			math.Pow(10, float64(bin)/displacementBinsPerDecade),
+			math.Pow(10, float64(bin+1)/displacementBinsPerDecade),
+		}
+		for _, m := range measured {
+			msd := math.NaN()
+			if atoms := m.binAtoms[bin]; atoms > 0 {
+				msd = m.binSquared[bin] * d.siteArea / float64(atoms)
+			}
+			row = append(row, float64(m.binAtoms[bin]), msd)
+		}
+		if err := writeTableRow(d.tables, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffusions returns the tracer diffusion of every element, nil when it is not estimated.
+func (s *Simulator) diffusions() map[string]Diffusion {
+	if s.diffusion == nil {
+		return nil
+	}
+
+	measured := s.measureDisplacements()
+	diffusions := make(map[string]Diffusion, len(s.elems))
+	for element, elementName := range s.elems {
+		m := measured[element]
+		diffusion := Diffusion{Atoms: m.atoms, FreeCoefficient: s.freeDiffusionCoefficient(element)}
+		if m.atoms > 0 {
+			diffusion.MeanTime = m.time / float64(m.atoms)
+			diffusion.MSD = m.squared * s.diffusion.siteArea / float64(m.atoms)
+		}
+		if m.timeSquared > 0 {
+			diffusion.Coefficient = m.coefficient(s.diffusion.siteArea)
+		}
+		diffusions[elementName] = diffusion
+	}
+	return diffusions
+}
@@ -0,0 +1,49 @@
+package simulation
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMeasureDisplacements(t *testing.T) {
+	cfg := testConfig(t, 10)
+	cfg.Simulating.TracerDiffusion = true
+	s := newTestSimulator(t, cfg, 1)
+	s.currentSimulationTime = 10
+
+	// Squared displacements of 8 cells² per second on the surface: D is 2 cells²/s
+	for i, atom := range []struct {
+		time   float64
+		dx, dy int32
+	}{{1, 2, 2}, {2, 4, 0}, {4, -4, 4}} {
+		s.atomsController.AddAtomOnSurface(Atom{
+			X:              uint32(i),
+			OccupiedCentre: s.matrix.GetCellInfo(uint32(i), 0).Center,
+			Track:          Track{AdsorbedAt: s.currentSimulationTime - atom.time, DX: atom.dx, DY: atom.dy},
+		})
+	}
+
+	m := s.measureDisplacements()[0]
+	if m.atoms != 3 || m.time != 7 || m.squared != 56 {
+		t.Fatalf("measured %d atoms, time %g, r² %g, want 3, 7, 56", m.atoms, m.time, m.squared)
+	}
+	// Five bins per decade: t = 1 s, 2 s and 4 s fall in bins 0, 1 and 3
+	for bin, want := range map[int]float64{0: 8, 1: 16, 3: 32} {
+		if m.binAtoms[bin] != 1 || m.binSquared[bin] != want {
+			t.Errorf("bin %d holds %d atoms with r² %g, want 1 with %g", bin, m.binAtoms[bin], m.binSquared[bin], want)
+		}
+	}
+
+	siteArea := s.diffusion.siteArea
+	if got, want := m.coefficient(siteArea), 2*siteArea; math.Abs(got-want) > 1e-12*want {
+		t.Errorf("coefficient %g, want %g", got, want)
+	}
+	diffusion := s.diffusions()[s.elems[0]]
+	if diffusion.Coefficient != m.coefficient(siteArea) || diffusion.MSD != 56*siteArea/3 {
+		t.Errorf("diffusion %+v does not match the measured displacements", diffusion)
+	}
+
+	if c := (displacements{}).coefficient(siteArea); !math.IsNaN(c) {
+		t.Errorf("coefficient without time on the surface %g, want NaN", c)
+	}
+}
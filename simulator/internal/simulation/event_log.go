@@ -0,0 +1,390 @@
+package simulation
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/zipliZ/surface-atoms/simulator/configs"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Files of the event log, written next to the results when it is enabled.
+const (
+	eventLogFileName   = "events.bin"
+	eventStartFileName = "events_start.json.gz"
+)
+
+// The event log starts with eventLogMagic, the version as a little-endian uint32 and the element names,
+// a byte with their number then every name as a byte with its length and the bytes of the name.
+// Every event follows as an eventRecordSize-byte record, all numbers little-endian:
+//
+//	physical time    float64
+//	process          uint8, index in eventLogProcesses
+//	outcome          uint8, index in eventLogOutcomes
+//	element          uint8, index in the element names, noElement if none
+//	partner element  uint8, index in the element names, noElement if none
+//	from X, from Y   uint32, uint32
+//	to X, to Y       uint32, uint32
+const (
+	eventLogMagic   = "SAEVENTS"
+	eventLogVersion = 1
+	eventRecordSize = 28
+	noElement       = math.MaxUint8
+)
+
+var (
+	eventLogProcesses = []string{adsorptionFProcess, adsorptionSProcess, recombErProcess, desorptionFProcess,
+		diffusionProcess, encounterProcess, nothingProcess}
+	eventLogOutcomes = []string{OutcomeNone, OutcomeAdsorbed, OutcomeDesorbed, OutcomeRecombEr, OutcomeHopped,
+		OutcomeRecombLhF, OutcomeRecombLhS, OutcomeBlockedRejected, OutcomeBlockedDesorbed}
+)
+
+// eventLog writes every executed event of a run.
+type eventLog struct {
+	file   *os.File
+	writer *bufio.Writer
+	elems  []string
+	record [eventRecordSize]byte
+}
+
+// openEventLog creates the event log in the directory, with the checkpoint the run starts from next to it.
+func openEventLog(dir string, start Checkpoint) (*eventLog, error) {
+	if err := start.Write(filepath.Join(dir, eventStartFileName)); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(filepath.Join(dir, eventLogFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	log := &eventLog{file: file, writer: bufio.NewWriterSize(file, 1<<16), elems: start.Elements}
+
+	header := []byte(eventLogMagic)
+	header = binary.LittleEndian.AppendUint32(header, eventLogVersion)
+	header = append(header, byte(len(log.elems)))
+	for _, element := range log.elems {
+		header = append(header, byte(len(element)))
+		header = append(header, element...)
+	}
+	if _, err = log.writer.Write(header); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return log, nil
+}
+
+// write appends the event. Write errors are kept by the buffered writer and returned by close.
+func (l *eventLog) write(physicalTime float64, process string, element int, event *Event) {
+	partner := noElement
+	if event.Partner != "" {
+		partner = slices.Index(l.elems, event.Partner)
+	}
+	if element < 0 {
+		element = noElement
+	}
+
+	record := l.record[:]
+	binary.LittleEndian.PutUint64(record[0:], math.Float64bits(physicalTime))
+	record[8] = byte(slices.Index(eventLogProcesses, process))
+	record[9] = byte(slices.Index(eventLogOutcomes, event.Outcome))
+	record[10] = byte(element)
+	record[11] = byte(partner)
+	binary.LittleEndian.PutUint32(record[12:], event.From.X)
+	binary.LittleEndian.PutUint32(record[16:], event.From.Y)
+	binary.LittleEndian.PutUint32(record[20:], event.To.X)
+	binary.LittleEndian.PutUint32(record[24:], event.To.Y)
+	_, _ = l.writer.Write(record)
+}
+
+func (l *eventLog) close() error {
+	if err := l.writer.Flush(); err != nil {
+		_ = l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
+
+// LoggedEvent is an event read from an event log.
+type LoggedEvent struct {
+	// Number of the event in the run
+	Number       int64
+	PhysicalTime float64
+	Process      string
+	Outcome      string
+	// Element of the process and of the recombination partner, empty if none
+	Element string
+	Partner string
+	From    Coordinates
+	To      Coordinates
+}
+
+// EventLogReader reads the events of an event log in order.
+type EventLogReader struct {
+	file   *os.File
+	reader *bufio.Reader
+	elems  []string
+	number int64
+	record [eventRecordSize]byte
+}
+
+// OpenEventLog opens the event log of the result directory. The numbers of its events continue after firstEvent.
+func OpenEventLog(dir string, firstEvent int64) (*EventLogReader, error) {
+	path := filepath.Join(dir, eventLogFileName)
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &EventLogReader{file: file, reader: bufio.NewReaderSize(file, 1<<16), number: firstEvent}
+	if err = r.readHeader(); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+func (r *EventLogReader) readHeader() error {
+	header := make([]byte, len(eventLogMagic)+4+1)
+	if _, err := io.ReadFull(r.reader, header); err != nil {
+		return err
+	}
+	if string(header[:len(eventLogMagic)]) != eventLogMagic {
+		return errors.New("not an event log")
+	}
+	if version := binary.LittleEndian.Uint32(header[len(eventLogMagic):]); version != eventLogVersion {
+		return fmt.Errorf("unsupported event log version %d", version)
+	}
+
+	r.elems = make([]string, header[len(header)-1])
+	for i := range r.elems {
+		length, err := r.reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		name := make([]byte, length)
+		if _, err = io.ReadFull(r.reader, name); err != nil {
+			return err
+		}
+		r.elems[i] = string(name)
+	}
+	return nil
+}
+
+// Next returns the next event, or io.EOF after the last one.
+func (r *EventLogReader) Next() (LoggedEvent, error) {
+	record := r.record[:]
+	if _, err := io.ReadFull(r.reader, record); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return LoggedEvent{}, fmt.Errorf("event %d is truncated", r.number+1)
+		}
+		return LoggedEvent{}, err
+	}
+	if int(record[8]) >= len(eventLogProcesses) || int(record[9]) >= len(eventLogOutcomes) {
+		return LoggedEvent{}, fmt.Errorf("event %d has an unknown process or outcome", r.number+1)
+	}
+	r.number++
+
+	return LoggedEvent{
+		Number:       r.number,
+		PhysicalTime: math.Float64frombits(binary.LittleEndian.Uint64(record[0:])),
+		Process:      eventLogProcesses[record[8]],
+		Outcome:      eventLogOutcomes[record[9]],
+		Element:      r.elementName(record[10]),
+		Partner:      r.elementName(record[11]),
+		From:         Coordinates{X: binary.LittleEndian.Uint32(record[12:]), Y: binary.LittleEndian.Uint32(record[16:])},
+		To:           Coordinates{X: binary.LittleEndian.Uint32(record[20:]), Y: binary.LittleEndian.Uint32(record[24:])},
+	}, nil
+}
+
+func (r *EventLogReader) elementName(index byte) string {
+	if int(index) >= len(r.elems) {
+		return ""
+	}
+	return r.elems[index]
+}
+
+// Close closes the event log.
+func (r *EventLogReader) Close() error {
+	return r.file.Close()
+}
+
+// Replay rebuilds the surface of a run at any physical time from the checkpoint it started from
+// and its event log. The counters of the info are not replayed.
+type Replay struct {
+	start           Checkpoint
+	matrix          *Matrix
+	atomsController *SurfaceAtomsController
+	events          *EventLogReader
+	elems           []string
+	// The event read but not yet applied, if any
+	pending      *LoggedEvent
+	physicalTime float64
+	applied      int64
+}
+
+// OpenReplay prepares the replay of the result directory of a run written with the event log enabled.
+// The surface is at the start of the run until Advance is called.
+func OpenReplay(dir string) (*Replay, error) {
+	start, err := ReadCheckpoint(filepath.Join(dir, eventStartFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	matrix := &Matrix{}
+	if err = matrix.InitWithSCenters(start.MatrixLenX, start.MatrixLenY, start.SCenters); err != nil {
+		return nil, err
+	}
+	atomsController := NewSurfaceAtomsController(start.MatrixLenX, start.MatrixLenY, matrix, make([]configs.Element, len(start.Elements)))
+
+	replay := &Replay{
+		start:           start,
+		matrix:          matrix,
+		atomsController: atomsController,
+		elems:           start.Elements,
+		physicalTime:    start.PhysicalTime,
+	}
+	for _, atom := range start.Atoms {
+		element := slices.Index(replay.elems, atom.Element)
+		if element < 0 {
+			return nil, fmt.Errorf("checkpoint atom of unknown element %q", atom.Element)
+		}
+		replay.addAtom(atom.X, atom.Y, element, atom.track(replay.matrix.GetCellInfo(atom.X, atom.Y).Center))
+	}
+
+	if replay.events, err = OpenEventLog(dir, start.Events); err != nil {
+		return nil, err
+	}
+	if !slices.Equal(replay.events.elems, replay.elems) {
+		_ = replay.events.Close()
+		return nil, fmt.Errorf("the elements of the event log %v do not match the checkpoint %v", replay.events.elems, replay.elems)
+	}
+
+	return replay, nil
+}
+
+// Advance applies the events up to the physical time, or all of them if the log ends before it, and passes
+// every applied event to visit unless it is nil. It returns false once the log has ended.
+func (r *Replay) Advance(physicalTime float64, visit func(LoggedEvent)) (bool, error) {
+	for {
+		if r.pending == nil {
+			event, err := r.events.Next()
+			if errors.Is(err, io.EOF) {
+				return false, nil
+			}
+			if err != nil {
+				return false, err
+			}
+			r.pending = &event
+		}
+		if r.pending.PhysicalTime > physicalTime {
+			r.physicalTime = physicalTime
+			return true, nil
+		}
+
+		if err := r.apply(*r.pending); err != nil {
+			return false, err
+		}
+		if visit != nil {
+			visit(*r.pending)
+		}
+		r.physicalTime = r.pending.PhysicalTime
+		r.applied = r.pending.Number
+		r.pending = nil
+	}
+}
+
+func (r *Replay) apply(event LoggedEvent) error {
+	switch event.Outcome {
+	case OutcomeAdsorbed:
+		element := slices.Index(r.elems, event.Element)
+		if element < 0 {
+			return fmt.Errorf("event %d: adsorption of unknown element %q", event.Number, event.Element)
+		}
+		if r.matrix.AtomAt(event.From.X, event.From.Y) != 0 {
+			return fmt.Errorf("event %d: adsorption on occupied cell (%d, %d)", event.Number, event.From.X, event.From.Y)
+		}
+		r.addAtom(event.From.X, event.From.Y, element, Track{
+			AdsorbedAt: event.PhysicalTime,
+			AdsorbedOn: r.matrix.GetCellInfo(event.From.X, event.From.Y).Center,
+		})
+	case OutcomeDesorbed, OutcomeRecombEr, OutcomeBlockedDesorbed:
+		return r.removeAtom(event, event.From)
+	case OutcomeHopped:
+		atomId := r.matrix.AtomAt(event.From.X, event.From.Y)
+		if atomId == 0 || r.matrix.AtomAt(event.To.X, event.To.Y) != 0 {
+			return fmt.Errorf("event %d: hop from (%d, %d) to (%d, %d) does not match the surface",
+				event.Number, event.From.X, event.From.Y, event.To.X, event.To.Y)
+		}
+		r.atomsController.MoveAtom(r.atomsController.Atom(atomId), r.matrix.GetCellInfo(event.To.X, event.To.Y))
+	case OutcomeRecombLhF, OutcomeRecombLhS:
+		if err := r.removeAtom(event, event.From); err != nil {
+			return err
+		}
+		return r.removeAtom(event, event.To)
+	}
+	return nil
+}
+
+// addAtom places an atom with the track on its cell.
+func (r *Replay) addAtom(x, y uint32, element int, track Track) {
+	r.atomsController.AddAtomOnSurface(Atom{
+		X:              x,
+		Y:              y,
+		OccupiedCentre: r.matrix.GetCellInfo(x, y).Center,
+		Element:        element,
+		Track:          track,
+	})
+}
+
+func (r *Replay) removeAtom(event LoggedEvent, cell Coordinates) error {
+	atomId := r.matrix.AtomAt(cell.X, cell.Y)
+	if atomId == 0 {
+		return fmt.Errorf("event %d: %s on free cell (%d, %d)", event.Number, event.Outcome, cell.X, cell.Y)
+	}
+	r.atomsController.RemoveAtomFromSurface(atomId)
+	return nil
+}
+
+// PhysicalTime returns the physical time the surface has been replayed to.
+func (r *Replay) PhysicalTime() float64 {
+	return r.physicalTime
+}
+
+// Events returns the number of the last event applied.
+func (r *Replay) Events() int64 {
+	return max(r.applied, r.start.Events)
+}
+
+// Atoms returns the atoms on the surface.
+func (r *Replay) Atoms() []Atom {
+	return r.atomsController.Atoms()
+}
+
+// ElementName returns the name of the element of an atom.
+func (r *Replay) ElementName(element int) string {
+	return r.elems[element]
+}
+
+// Checkpoint returns the replayed surface as a checkpoint, with the counters of the start of the run.
+// The log does not record the state of the random number generator, so a run resumed from it draws
+// the random numbers of its own generator.
+func (r *Replay) Checkpoint() Checkpoint {
+	checkpoint := r.start
+	checkpoint.RandState = nil
+	checkpoint.PhysicalTime = r.physicalTime
+	checkpoint.Events = r.Events()
+	checkpoint.Atoms = checkpointAtoms(r.atomsController.Atoms(), r.elems)
+	return checkpoint
+}
+
+// Close closes the event log.
+func (r *Replay) Close() error {
+	return r.events.Close()
+}
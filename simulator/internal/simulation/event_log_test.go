@@ -0,0 +1,69 @@
+package simulation
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"slices"
+	"testing"
+)
+
+// atomPlaces returns the cells, centers and elements of the atoms, sorted.
+func atomPlaces(atoms []Atom) []Atom {
+	places := make([]Atom, len(atoms))
+	for i, atom := range atoms {
+		places[i] = Atom{X: atom.X, Y: atom.Y, OccupiedCentre: atom.OccupiedCentre, Element: atom.Element}
+	}
+	slices.SortFunc(places, func(a, b Atom) int {
+		return cmp.Or(cmp.Compare(a.Y, b.Y), cmp.Compare(a.X, b.X))
+	})
+	return places
+}
+
+func TestReplayEventLog(t *testing.T) {
+	cfg := testConfig(t, 30)
+	cfg.Simulating.EventLog = true
+	cfg.Simulating.Outputs = []string{"csv"}
+	cfg.Simulating.GraphicsToPlot = nil
+	s, err := NewSimulator(cfg, 600, 1e-4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Simulate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	result := s.Result()
+
+	replay, err := OpenReplay(s.ResultDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replay.Close()
+
+	// Half way the replay stops before the first later event
+	half := result.PhysicalTime / 2
+	more, err := replay.Advance(half, nil)
+	if err != nil || !more {
+		t.Fatalf("Advance to %g: %t, %v", half, more, err)
+	}
+	if replay.PhysicalTime() != half || replay.Events() == 0 || replay.Events() >= result.Events {
+		t.Fatalf("replayed %d of %d events to %g s", replay.Events(), result.Events, replay.PhysicalTime())
+	}
+
+	last := half
+	more, err = replay.Advance(math.Inf(1), func(event LoggedEvent) {
+		if event.PhysicalTime < last {
+			t.Fatalf("event %d at %g s after one at %g s", event.Number, event.PhysicalTime, last)
+		}
+		last = event.PhysicalTime
+	})
+	if err != nil || more {
+		t.Fatalf("Advance to the end: %t, %v", more, err)
+	}
+	if replay.Events() != result.Events {
+		t.Errorf("replayed %d events, the run executed %d", replay.Events(), result.Events)
+	}
+	if got, want := atomPlaces(replay.Atoms()), atomPlaces(s.surfaceAtoms()); !slices.Equal(got, want) {
+		t.Errorf("the replayed surface has %d atoms, the run ended with %d", len(got), len(want))
+	}
+}
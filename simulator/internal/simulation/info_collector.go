@@ -2,26 +2,25 @@ package simulation
 
 import (
 	"fmt"
-	"main/configs"
+	"github.com/zipliZ/surface-atoms/simulator/configs"
+	"github.com/zipliZ/surface-atoms/simulator/internal/output"
 	"math"
-	"os"
-
-	"github.com/tealeg/xlsx"
 )
 
 // InfoCollector - structure that collects information about the simulation progress.
+// Every row is written to all configured output backends.
 type InfoCollector struct {
-	fileName        string
+	writers         []output.Writer
 	floatPrecision  int
-	workbook        *xlsx.File
-	sheet           *xlsx.Sheet
-	output          *os.File
+	columns         int
 	closed          bool
 	Info            map[string]Info
 	elementOrder    []string
 	formedAtomOrder []string
 	TotalInfo       InfoWithCombinedAtoms
 	ElapsedTime     float64
+	// Values of the extra columns, written after the element info as they are, without rounding
+	Extra []float64
 }
 
 // Info - structure containing details about the simulation progress.
@@ -35,6 +34,22 @@ type Info struct {
 	RecombEr       float64
 	RecombLhF      float64
 	RecombLhS      float64
+
+	BlockedHopsRejected int
+	BlockedHopsRetried  int
+	BlockedHopsDesorbed int
+}
+
+// add adds the counters of other to the info; the coverages are left as they are.
+func (i *Info) add(other Info) {
+	i.AdsorbedAtoms += other.AdsorbedAtoms
+	i.DesorbedAtoms += other.DesorbedAtoms
+	i.RecombEr += other.RecombEr
+	i.RecombLhF += other.RecombLhF
+	i.RecombLhS += other.RecombLhS
+	i.BlockedHopsRejected += other.BlockedHopsRejected
+	i.BlockedHopsRetried += other.BlockedHopsRetried
+	i.BlockedHopsDesorbed += other.BlockedHopsDesorbed
 }
 
 type InfoWithCombinedAtoms struct {
@@ -42,14 +57,9 @@ type InfoWithCombinedAtoms struct {
 	FormedAtoms map[string]int
 }
 
-// NewInfoCollector creates a new InfoCollector. It also generates an Excel file with a pre-filled header.
-func NewInfoCollector(fileName string, floatPrecision int, elements []configs.Element, formedAtomNames []string) (*InfoCollector, error) {
-	file := xlsx.NewFile()
-	sh, err := file.AddSheet("Sheet1")
-	if err != nil {
-		return nil, err
-	}
-
+// NewInfoCollector creates a new InfoCollector. It also writes the header and a row of zeros to every writer.
+// The extra columns follow the element info, with the values set in Extra.
+func NewInfoCollector(writers []output.Writer, floatPrecision int, elements []configs.Element, formedAtomNames []string, extraColumns []string) (*InfoCollector, error) {
 	headers := []string{
 		"Simulation time",
 	}
@@ -65,6 +75,9 @@ func NewInfoCollector(fileName string, floatPrecision int, elements []configs.El
 		"Recomb Er",
 		"Recomb Lh F",
 		"Recomb Lh S",
+		"Blocked hops rejected",
+		"Blocked hops retried",
+		"Blocked hops desorbed",
 	)
 
 	for _, formedAtomName := range formedAtomNames {
@@ -84,24 +97,15 @@ func NewInfoCollector(fileName string, floatPrecision int, elements []configs.El
 				fmt.Sprintf("%s - Recomb Er", element.Name),
 				fmt.Sprintf("%s - Recomb Lh F", element.Name),
 				fmt.Sprintf("%s - Recomb Lh S", element.Name),
+				fmt.Sprintf("%s - Blocked hops rejected", element.Name),
+				fmt.Sprintf("%s - Blocked hops retried", element.Name),
+				fmt.Sprintf("%s - Blocked hops desorbed", element.Name),
 			)
 			elementOrder = append(elementOrder, element.Name)
 		}
 	}
 
-	row := sh.AddRow()
-	for _, header := range headers {
-		row.AddCell().SetString(header)
-	}
-	row = sh.AddRow()
-	for range len(headers) {
-		row.AddCell().SetFloat(0)
-	}
-
-	output, err := os.Create(fileName)
-	if err != nil {
-		return nil, err
-	}
+	headers = append(headers, extraColumns...)
 
 	info := make(map[string]Info)
 	for _, element := range elements {
@@ -109,19 +113,24 @@ func NewInfoCollector(fileName string, floatPrecision int, elements []configs.El
 	}
 
 	collector := &InfoCollector{
-		fileName:        fileName,
+		writers:         writers,
 		floatPrecision:  floatPrecision,
-		workbook:        file,
-		sheet:           sh,
-		output:          output,
+		columns:         len(headers),
 		Info:            info,
 		TotalInfo:       InfoWithCombinedAtoms{FormedAtoms: make(map[string]int)},
 		elementOrder:    elementOrder,
 		formedAtomOrder: formedAtomNames,
+		Extra:           make([]float64, len(extraColumns)),
 	}
 
-	if err = collector.Flush(); err != nil {
-		_ = output.Close()
+	for _, writer := range writers {
+		if err := writer.WriteHeader(headers); err != nil {
+			_ = collector.Close()
+			return nil, err
+		}
+	}
+	if err := collector.writeRow(make([]float64, len(headers))); err != nil {
+		_ = collector.Close()
 		return nil, err
 	}
 
@@ -130,76 +139,84 @@ func NewInfoCollector(fileName string, floatPrecision int, elements []configs.El
 
 // WriteInfo collects information about the simulation progress.
 func (i *InfoCollector) WriteInfo() error {
-	row := i.sheet.AddRow()
+	row := make([]float64, 0, i.columns)
 
 	// Write common info (step and time)
-	row.AddCell().SetFloat(roundToDecimals(i.ElapsedTime, i.floatPrecision))
+	row = append(row, i.ElapsedTime)
 
 	// Write total info
-	row.AddCell().SetInt(i.TotalInfo.AtomsOnSurface)
-	row.AddCell().SetInt(i.TotalInfo.AdsorbedAtoms)
-	row.AddCell().SetInt(i.TotalInfo.DesorbedAtoms)
-	row.AddCell().SetFloat(roundToDecimals(i.TotalInfo.Density, i.floatPrecision))
-	row.AddCell().SetFloat(roundToDecimals(i.TotalInfo.DensityF, i.floatPrecision))
-	row.AddCell().SetFloat(roundToDecimals(i.TotalInfo.DensityS, i.floatPrecision))
-	row.AddCell().SetFloat(roundToDecimals(i.TotalInfo.RecombEr, i.floatPrecision))
-	row.AddCell().SetFloat(roundToDecimals(i.TotalInfo.RecombLhF, i.floatPrecision))
-	row.AddCell().SetFloat(roundToDecimals(i.TotalInfo.RecombLhS, i.floatPrecision))
+	row = appendInfo(row, i.TotalInfo.Info)
 	for _, formedAtomName := range i.formedAtomOrder {
-		row.AddCell().SetInt(i.TotalInfo.FormedAtoms[formedAtomName])
-	}
-
-	if len(i.elementOrder) < 2 {
-		return i.Flush()
+		row = append(row, float64(i.TotalInfo.FormedAtoms[formedAtomName]))
 	}
 
 	// Write element-specific info
 	for _, element := range i.elementOrder {
-		info := i.Info[element]
-		row.AddCell().SetInt(info.AtomsOnSurface)
-		row.AddCell().SetInt(info.AdsorbedAtoms)
-		row.AddCell().SetInt(info.DesorbedAtoms)
-		row.AddCell().SetFloat(roundToDecimals(info.Density, i.floatPrecision))
-		row.AddCell().SetFloat(roundToDecimals(info.DensityF, i.floatPrecision))
-		row.AddCell().SetFloat(roundToDecimals(info.DensityS, i.floatPrecision))
-		row.AddCell().SetFloat(roundToDecimals(info.RecombEr, i.floatPrecision))
-		row.AddCell().SetFloat(roundToDecimals(info.RecombLhF, i.floatPrecision))
-		row.AddCell().SetFloat(roundToDecimals(info.RecombLhS, i.floatPrecision))
+		row = appendInfo(row, i.Info[element])
 	}
+	for j, value := range row {
+		row[j] = roundToDecimals(value, i.floatPrecision)
+	}
+	row = append(row, i.Extra...)
+
+	return i.writeRow(row)
+}
 
-	return i.Flush()
+func appendInfo(row []float64, info Info) []float64 {
+	return append(row,
+		float64(info.AtomsOnSurface),
+		float64(info.AdsorbedAtoms),
+		float64(info.DesorbedAtoms),
+		info.Density,
+		info.DensityF,
+		info.DensityS,
+		info.RecombEr,
+		info.RecombLhF,
+		info.RecombLhS,
+		float64(info.BlockedHopsRejected),
+		float64(info.BlockedHopsRetried),
+		float64(info.BlockedHopsDesorbed),
+	)
 }
 
-func (i *InfoCollector) Flush() error {
+func (i *InfoCollector) writeRow(row []float64) error {
 	if i.closed {
 		return nil
 	}
 
-	if _, err := i.output.Seek(0, 0); err != nil {
-		return err
-	}
-	if err := i.output.Truncate(0); err != nil {
-		return err
-	}
-	if err := i.workbook.Write(i.output); err != nil {
-		return err
+	for _, writer := range i.writers {
+		if err := writer.WriteRow(row); err != nil {
+			return fmt.Errorf("%s: %w", writer.Path(), err)
+		}
 	}
 
-	return i.output.Sync()
+	return nil
 }
 
+// SetMetadata passes run metadata to the writers able to store it.
+func (i *InfoCollector) SetMetadata(keys []string, values map[string]string) {
+	for _, writer := range i.writers {
+		if metadataWriter, ok := writer.(output.MetadataWriter); ok {
+			metadataWriter.SetMetadata(keys, values)
+		}
+	}
+}
+
+// Close closes every writer, returning the first error.
 func (i *InfoCollector) Close() error {
 	if i.closed {
 		return nil
 	}
-
-	err := i.Flush()
-	closeErr := i.output.Close()
 	i.closed = true
-	if err != nil {
-		return err
+
+	var err error
+	for _, writer := range i.writers {
+		if closeErr := writer.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("%s: %w", writer.Path(), closeErr)
+		}
 	}
-	return closeErr
+
+	return err
 }
 
 func roundToDecimals(value float64, precision int) float64 {
@@ -0,0 +1,166 @@
+package simulation
+
+import (
+	"encoding/json"
+	"github.com/zipliZ/surface-atoms/simulator/configs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+const manifestFileName = "run.json"
+
+// Reasons a run stopped, recorded in the manifest.
+const (
+	StopRunning          = "running"
+	StopCompleted        = "completed"
+	StopQuasiSteadyState = "quasiSteadyState"
+	StopInterrupted      = "interrupted"
+	StopError            = "error"
+)
+
+// RunManifest describes what a run was made with and how it ended. It is written as run.json next to the results
+// when the run starts and rewritten when it stops.
+type RunManifest struct {
+	Temperature    int                           `json:"temperature"`
+	SimulationTime float64                       `json:"simulationTime"`
+	Seed           uint64                        `json:"seed"`
+	StartedAt      time.Time                     `json:"startedAt"`
+	FinishedAt     *time.Time                    `json:"finishedAt,omitempty"`
+	WallTime       float64                       `json:"wallTimeSeconds"`
+	StopReason     string                        `json:"stopReason"`
+	Error          string                        `json:"error,omitempty"`
+	PhysicalTime   float64                       `json:"physicalTime"`
+	Events         int64                         `json:"events"`
+	Memory         MemoryUsage                   `json:"memory"`
+	Host           string                        `json:"host"`
+	Build          BuildInfo                     `json:"build"`
+	Config         configs.Config                `json:"config"`
+	Rates          map[string]map[string]float64 `json:"rates"`
+	// Scaling of the hop rate of every element when the diffusion acceleration is enabled
+	Acceleration map[string]Acceleration `json:"acceleration,omitempty"`
+	// Residence times of the atoms of every element by exit channel when they are collected
+	Residence map[string]map[string]Residence `json:"residence,omitempty"`
+	// Tracer diffusion of every element at the end of the run when it is estimated
+	Diffusion map[string]Diffusion `json:"diffusion,omitempty"`
+	// Events and wall time of every process of every element when the process budget is kept
+	Budget map[string]map[string]ProcessBudget `json:"budget,omitempty"`
+}
+
+// MemoryUsage is the memory taken by a run: the lattice and the heap at start-up, once the lattice is set
+// up, and the atoms with the per-element indexes of their ids at their largest so far.
+type MemoryUsage struct {
+	Sites        int    `json:"sites"`
+	LatticeBytes int    `json:"latticeBytes"`
+	AtomsBytes   int    `json:"atomsBytes"`
+	HeapBytes    uint64 `json:"heapBytes"`
+}
+
+func (s *Simulator) measureMemory() MemoryUsage {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	return MemoryUsage{
+		Sites:        s.cfg.Simulating.MatrixLenX * s.cfg.Simulating.MatrixLenY,
+		LatticeBytes: s.latticeBytes(),
+		AtomsBytes:   s.atomsBytes(),
+		HeapBytes:    stats.HeapAlloc,
+	}
+}
+
+// BuildInfo identifies the simulator binary.
+type BuildInfo struct {
+	GoVersion   string `json:"goVersion"`
+	Version     string `json:"version,omitempty"`
+	GitRevision string `json:"gitRevision,omitempty"`
+	GitModified bool   `json:"gitModified,omitempty"`
+}
+
+func readBuildInfo() BuildInfo {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return BuildInfo{}
+	}
+
+	build := BuildInfo{GoVersion: info.GoVersion, Version: info.Main.Version}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			build.GitRevision = setting.Value
+		case "vcs.modified":
+			build.GitModified = setting.Value == "true"
+		}
+	}
+
+	return build
+}
+
+func (s *Simulator) manifest() RunManifest {
+	host, _ := os.Hostname()
+	manifest := RunManifest{
+		Temperature:    s.temperature,
+		SimulationTime: s.simulationTime,
+		Seed:           s.cfg.Simulating.Seed,
+		StartedAt:      s.startedAt,
+		StopReason:     s.stopReason,
+		PhysicalTime:   s.currentSimulationTime,
+		Events:         s.events,
+		Memory:         s.memory,
+		Host:           host,
+		Build:          readBuildInfo(),
+		Config:         s.cfg,
+		Rates:          s.rateConstants(),
+		Acceleration:   s.accelerations(),
+		Residence:      s.residences(),
+		Diffusion:      s.diffusions(),
+		Budget:         s.budgets(),
+	}
+	if s.runErr != nil {
+		manifest.Error = s.runErr.Error()
+	}
+	if !s.finishedAt.IsZero() {
+		finishedAt := s.finishedAt
+		manifest.FinishedAt = &finishedAt
+		manifest.WallTime = finishedAt.Sub(s.startedAt).Seconds()
+	}
+
+	return manifest
+}
+
+// writeManifest saves the manifest in the result directory, if the run has one.
+func (s *Simulator) writeManifest(manifest RunManifest) error {
+	if s.dirName == "" {
+		return nil
+	}
+	return manifest.Write(filepath.Join(s.dirName, manifestFileName))
+}
+
+// Write saves the manifest as indented JSON.
+func (m RunManifest) Write(path string) error {
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, content, 0644)
+}
+
+// Metadata returns the manifest as flattened key-value pairs for the Meta sheet of the results.
+// String values are unquoted.
+func (m RunManifest) Metadata() ([]string, map[string]string, error) {
+	keys, values, err := configs.Flatten(m)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for key, value := range values {
+		var text string
+		if json.Unmarshal([]byte(value), &text) == nil {
+			values[key] = text
+		}
+	}
+
+	return keys, values, nil
+}
@@ -1,12 +1,11 @@
 package simulation
 
 import (
-	"main/internal/random"
 	"math"
 )
 
 // calcProbabilityEr calculates the probability of recombination at the S-center.
-func (s *Simulator) calcProbabilityEr(meta SimulationMeta) float64 {
+func (s *Simulator) calcProbabilityEr(meta *SimulationMeta) float64 {
 	sigmaS := float64(s.atomsController.AtomsOnSCenters.Len()) / float64(s.matrix.NumOfSSites)
 	probabilityEr := 2.0 * sigmaS * meta.r4 / meta.atomFlux * s.cfg.Constants.SDensity
 
@@ -14,7 +13,7 @@ func (s *Simulator) calcProbabilityEr(meta SimulationMeta) float64 {
 }
 
 // calcProbabilitySLh calculates the probability of recombination at both the S-center and F-center.
-func (s *Simulator) calcProbabilitySLh(meta SimulationMeta) float64 {
+func (s *Simulator) calcProbabilitySLh(meta *SimulationMeta) float64 {
 	sigmaS := float64(s.atomsController.AtomsOnSCenters.Len()) / float64(s.matrix.NumOfSSites)
 	sigmaF := float64(s.atomsController.AtomsOnFCenters.Len()) / float64(s.matrix.NumOfFSites)
 
@@ -24,7 +23,7 @@ func (s *Simulator) calcProbabilitySLh(meta SimulationMeta) float64 {
 }
 
 // calcProbabilityFLh calculates the probability of recombination at the F-center.
-func (s *Simulator) calcProbabilityFLh(meta SimulationMeta) float64 {
+func (s *Simulator) calcProbabilityFLh(meta *SimulationMeta) float64 {
 	sigmaF := float64(s.atomsController.AtomsOnFCenters.Len()) / float64(s.matrix.NumOfFSites)
 
 	probabilityFLh := 2.0 * sigmaF * meta.r7 * s.cfg.Constants.FDensity / meta.atomFlux
@@ -33,40 +32,50 @@ func (s *Simulator) calcProbabilityFLh(meta SimulationMeta) float64 {
 }
 
 // calcLambdaAdsorptionF calculates the adsorption rate at the F-center.
-func (s *Simulator) calcLambdaAdsorptionF(meta SimulationMeta) float64 {
+func (s *Simulator) calcLambdaAdsorptionF(meta *SimulationMeta) float64 {
 	lambdaAdsorptions := float64(s.matrix.CountFreeCellsOfFCenters()) * meta.atomFlux / (s.cfg.Constants.FDensity + s.cfg.Constants.SDensity)
 
 	return lambdaAdsorptions
 }
 
 // calcLambdaAdsorptionS calculates the adsorption rate at the S-center.
-func (s *Simulator) calcLambdaAdsorptionS(meta SimulationMeta) float64 {
+func (s *Simulator) calcLambdaAdsorptionS(meta *SimulationMeta) float64 {
 	lambdaAdsorptions := float64(s.matrix.CountFreeCellsOfSCenters()) * meta.atomFlux / (s.cfg.Constants.FDensity + s.cfg.Constants.SDensity)
 
 	return lambdaAdsorptions
 }
 
 // calcLambdaDesorptionF calculates the desorption rate at the F-center.
-func (s *Simulator) calcLambdaDesorptionF(element string, meta SimulationMeta) float64 {
+func (s *Simulator) calcLambdaDesorptionF(element int, meta *SimulationMeta) float64 {
 	lambdaDesorption := float64(s.atomsController.AtomsOnFCenters[element].Len()) * meta.r2
 
 	return lambdaDesorption
 }
 
-// calcLambdaDiffusion calculates the diffusion rate at the F-center.
-func (s *Simulator) calcLambdaDiffusion(element string, meta SimulationMeta) float64 {
+// calcLambdaDiffusion calculates the diffusion rate at the F-center, scaled down when it is accelerated.
+func (s *Simulator) calcLambdaDiffusion(element int, meta *SimulationMeta) float64 {
 	lambdaDiffusion := float64(s.atomsController.AtomsOnFCenters[element].Len()) * meta.r5
+	if s.acceleration != nil {
+		lambdaDiffusion *= s.acceleration.scales[element]
+	}
 	return lambdaDiffusion
 }
 
+// calcLambdaEncounter calculates the rate of hops onto occupied cells when diffusion is accelerated.
+func (s *Simulator) calcLambdaEncounter(element int, meta *SimulationMeta) float64 {
+	lambdaEncounter := float64(s.atomsController.AtomsInContact[element].Len()) * meta.r5
+	return lambdaEncounter
+}
+
 // calcLambdaRecombEr calculates the recombination rate at the S-center.
-func (s *Simulator) calcLambdaRecombEr(element string, meta SimulationMeta) float64 {
+func (s *Simulator) calcLambdaRecombEr(element int, meta *SimulationMeta) float64 {
 	lambdaRecombEr := float64(s.atomsController.AtomsOnSCenters[element].Len()) * meta.r4
 
 	return lambdaRecombEr
 }
 
-// CalcTime calculates the physical time.
-func CalcTime(lambda float64) float64 {
-	return 1.0 / lambda * math.Log(1.0/random.Float64())
+// calcTime draws the physical time an event takes when the total rate is lambda.
+func (s *Simulator) calcTime(lambda float64) float64 {
+	// 1 - u is in (0, 1], so the logarithm is finite
+	return 1.0 / lambda * math.Log(1.0/(1.0-s.rand.Float64()))
 }
@@ -1,19 +1,31 @@
 package simulation
 
 import (
-	"crypto/rand"
-	"main/configs"
-	"main/internal/random"
-	"math/big"
+	"fmt"
+	"github.com/zipliZ/surface-atoms/simulator/configs"
+	"github.com/zipliZ/surface-atoms/simulator/internal/random"
+	"math/bits"
 )
 
+// Matrix is the lattice of adsorption sites, packed so that lattices of 10^7-10^8 sites fit in memory.
+// The coordinates of a cell are implied by its index y*lenX + x, the site type is one bit per cell,
+// and the occupancy shares one int32 per cell with the position of the cell in the free list of its type.
+// A matrix may hold only the rows [offsetY, offsetY+lenY) of the lattice; cells keep their lattice coordinates.
 type Matrix struct {
-	NumOfSSites         int
-	NumOfFSites         int
-	FreeCellsOfFCenters *random.Map[uint32, CellData]
-	FreeCellsOfSCenters *random.Map[uint32, CellData]
-	cells               [][]CellData
-	consts              configs.Constants
+	NumOfSSites int
+	NumOfFSites int
+	lenX        int
+	lenY        int
+	offsetY     int
+	// Bit i is set when cell i is an S-center
+	sCenters []uint64
+	// Per cell: the position in the free list of its type when the cell is free,
+	// the negated id of the atom on it otherwise
+	slots []int32
+	// Indexes of the free cells of every type
+	freeFCells []int32
+	freeSCells []int32
+	consts     configs.Constants
 }
 
 // CellData represents the data of an individual cell in the matrix.
@@ -34,91 +46,197 @@ type CellData struct {
 
 func NewMatrix(consts configs.Constants) *Matrix {
 	return &Matrix{
-		cells:               [][]CellData{},
-		FreeCellsOfSCenters: random.NewRandMap[uint32, CellData](),
-		FreeCellsOfFCenters: random.NewRandMap[uint32, CellData](),
-		consts:              consts,
+		consts: consts,
 	}
 }
 
 // Init initializes the matrix with the given size.
 // It fills the matrix with data and calculates the number of S- and F-centers.
-func (m *Matrix) Init(x, y int) {
-	m.cells = make([][]CellData, x)
+// The S-centers are placed on uniformly chosen cells with the random numbers of r; any cell can be one,
+// the cells of the last row and column included.
+func (m *Matrix) Init(x, y int, r *random.Rand) {
+	m.initCells(x, 0, y)
 
 	m.NumOfSSites = int(float64(x) * float64(y) * m.consts.Fi)
 	m.NumOfFSites = x*y - m.NumOfSSites
 
-	for i := range m.cells {
-		m.cells[i] = make([]CellData, y)
-		for j := range m.cells[i] {
-			cell := CellData{
-				Id:     uint32(i*x + (j) + 1),
-				X:      uint32(j),
-				Y:      uint32(i),
-				Center: 'F',
-				IsFree: true,
-			}
-			m.cells[i][j] = cell
-			m.FreeCellsOfFCenters.Add(cell.Id, cell)
-		}
-	}
-
 	for range m.NumOfSSites {
 		for {
-			randomI, _ := rand.Int(rand.Reader, big.NewInt(int64(x-1)))
-			randomJ, _ := rand.Int(rand.Reader, big.NewInt(int64(y-1)))
-
-			i, j := int(randomI.Int64()), int(randomJ.Int64())
-			if m.cells[j][i].Center != 'S' {
-				m.cells[j][i].Center = 'S'
-				m.FreeCellsOfSCenters.Add(m.cells[j][i].Id, m.cells[j][i])
-				m.FreeCellsOfFCenters.Remove(m.cells[j][i].Id)
+			index := r.Int(x * y)
+			if !m.isSCenter(index) {
+				m.setSCenter(index)
 				break
 			}
 		}
 	}
+
+	m.buildFreeLists()
+}
+
+// InitWithSCenters initializes the matrix with the given size and S-centers on the given cells,
+// e.g. those of a checkpoint.
+func (m *Matrix) InitWithSCenters(x, y int, sCenters []Coordinates) error {
+	for _, center := range sCenters {
+		if int(center.X) >= x || int(center.Y) >= y {
+			return fmt.Errorf("S-center (%d, %d) is outside the %dx%d matrix", center.X, center.Y, x, y)
+		}
+	}
+
+	m.InitRows(x, 0, y, sCenters)
+	return nil
+}
+
+// InitRows initializes the rows [fromY, toY) of a lattice x cells wide, with S-centers on those of the given
+// cells that lie in them. A lattice split into bands is made of such matrices.
+func (m *Matrix) InitRows(x, fromY, toY int, sCenters []Coordinates) {
+	m.initCells(x, fromY, toY)
+
+	for _, center := range sCenters {
+		if !m.Contains(center) {
+			continue
+		}
+		if index := m.index(center.X, center.Y); !m.isSCenter(index) {
+			m.setSCenter(index)
+			m.NumOfSSites++
+		}
+	}
+	m.NumOfFSites = len(m.slots) - m.NumOfSSites
+
+	m.buildFreeLists()
+}
+
+func (m *Matrix) initCells(x, fromY, toY int) {
+	m.lenX, m.lenY, m.offsetY = x, toY-fromY, fromY
+	m.NumOfSSites = 0
+	m.sCenters = make([]uint64, (x*m.lenY+63)/64)
+	m.slots = make([]int32, x*m.lenY)
+}
+
+// Contains tells whether the cell is in the rows of the matrix.
+func (m *Matrix) Contains(cell Coordinates) bool {
+	return int(cell.Y) >= m.offsetY && int(cell.Y) < m.offsetY+m.lenY && int(cell.X) < m.lenX
+}
+
+// buildFreeLists puts every cell in the free list of its type.
+func (m *Matrix) buildFreeLists() {
+	m.freeFCells = make([]int32, 0, m.NumOfFSites)
+	m.freeSCells = make([]int32, 0, m.NumOfSSites)
+
+	for index := range m.slots {
+		freeCells := m.freeCells(m.isSCenter(index))
+		m.slots[index] = int32(len(*freeCells))
+		*freeCells = append(*freeCells, int32(index))
+	}
+}
+
+func (m *Matrix) index(x, y uint32) int {
+	return (int(y)-m.offsetY)*m.lenX + int(x)
+}
+
+func (m *Matrix) isSCenter(index int) bool {
+	return m.sCenters[index/64]&(1<<(index%64)) != 0
+}
+
+func (m *Matrix) setSCenter(index int) {
+	m.sCenters[index/64] |= 1 << (index % 64)
+}
+
+func (m *Matrix) freeCells(sCenter bool) *[]int32 {
+	if sCenter {
+		return &m.freeSCells
+	}
+	return &m.freeFCells
+}
+
+// SCenters returns the coordinates of all S-centers.
+func (m *Matrix) SCenters() []Coordinates {
+	centers := make([]Coordinates, 0, m.NumOfSSites)
+	for word, set := range m.sCenters {
+		for set != 0 {
+			index := word*64 + bits.TrailingZeros64(set)
+			centers = append(centers, Coordinates{X: uint32(index % m.lenX), Y: uint32(index/m.lenX + m.offsetY)})
+			set &= set - 1
+		}
+	}
+	return centers
 }
 
 // SetAtomOnCell places an atom on the cell (x, y) with the given atomId.
 // If the cell was free, it is no longer considered free.
 func (m *Matrix) SetAtomOnCell(x, y uint32, atomId int) {
-	m.cells[y][x].IsFree = false
-	m.cells[y][x].AtomId = atomId
-
-	switch m.cells[y][x].Center {
-	case 'S':
-		m.FreeCellsOfSCenters.Remove(m.cells[y][x].Id)
-	case 'F':
-		m.FreeCellsOfFCenters.Remove(m.cells[y][x].Id)
+	index := m.index(x, y)
+	if position := m.slots[index]; position >= 0 {
+		// The last free cell takes the place of this one
+		freeCells := m.freeCells(m.isSCenter(index))
+		last := (*freeCells)[len(*freeCells)-1]
+		(*freeCells)[position] = last
+		m.slots[last] = position
+		*freeCells = (*freeCells)[:len(*freeCells)-1]
 	}
+
+	m.slots[index] = -int32(atomId)
 }
 
 // ClearCell clears the cell (x, y), removing the atom if present.
 // If the cell was already free, it remains free.
 func (m *Matrix) ClearCell(x, y uint32) {
-	m.cells[y][x].IsFree = true
-	m.cells[y][x].AtomId = 0
-
-	switch m.cells[y][x].Center {
-	case 'S':
-		m.FreeCellsOfSCenters.Add(m.cells[y][x].Id, m.cells[y][x])
-	case 'F':
-		m.FreeCellsOfFCenters.Add(m.cells[y][x].Id, m.cells[y][x])
+	index := m.index(x, y)
+	if m.slots[index] >= 0 {
+		return
 	}
+
+	freeCells := m.freeCells(m.isSCenter(index))
+	m.slots[index] = int32(len(*freeCells))
+	*freeCells = append(*freeCells, int32(index))
 }
 
 // GetCellInfo returns information about the cell at (x, y).
 func (m *Matrix) GetCellInfo(x, y uint32) CellData {
-	return m.cells[y][x]
+	return m.cellAt(m.index(x, y))
+}
+
+// AtomAt returns the id of the atom on the cell (x, y), 0 if the cell is free.
+func (m *Matrix) AtomAt(x, y uint32) int {
+	return -int(min(m.slots[m.index(x, y)], 0))
+}
+
+func (m *Matrix) cellAt(index int) CellData {
+	cell := CellData{
+		Id:     uint32(m.offsetY*m.lenX + index + 1),
+		X:      uint32(index % m.lenX),
+		Y:      uint32(index/m.lenX + m.offsetY),
+		Center: 'F',
+		IsFree: m.slots[index] >= 0,
+	}
+	if m.isSCenter(index) {
+		cell.Center = 'S'
+	}
+	if !cell.IsFree {
+		cell.AtomId = -int(m.slots[index])
+	}
+	return cell
+}
+
+// RandomFreeCell returns a uniformly chosen free cell of the center, or false if there is none.
+func (m *Matrix) RandomFreeCell(center rune, r *random.Rand) (CellData, bool) {
+	freeCells := *m.freeCells(center == 'S')
+	if len(freeCells) == 0 {
+		return CellData{}, false
+	}
+	return m.cellAt(int(freeCells[r.Int(len(freeCells))])), true
 }
 
 // CountFreeCellsOfFCenters returns the number of free F-centers.
 func (m *Matrix) CountFreeCellsOfFCenters() int {
-	return m.FreeCellsOfFCenters.Len()
+	return len(m.freeFCells)
 }
 
 // CountFreeCellsOfSCenters returns the number of free S-centers.
 func (m *Matrix) CountFreeCellsOfSCenters() int {
-	return m.FreeCellsOfSCenters.Len()
+	return len(m.freeSCells)
+}
+
+// MemoryUsage returns the number of bytes allocated for the lattice.
+func (m *Matrix) MemoryUsage() int {
+	return 8*cap(m.sCenters) + 4*(cap(m.slots)+cap(m.freeFCells)+cap(m.freeSCells))
 }
@@ -0,0 +1,88 @@
+package simulation
+
+import (
+	"testing"
+
+	"github.com/zipliZ/surface-atoms/simulator/internal/random"
+)
+
+// checkFreeLists checks that the free lists hold exactly the free cells of their type,
+// each at the position its slot records.
+func checkFreeLists(t *testing.T, m *Matrix) {
+	t.Helper()
+	free := map[bool]int{}
+	for index := range m.slots {
+		if m.slots[index] < 0 {
+			continue
+		}
+		sCenter := m.isSCenter(index)
+		free[sCenter]++
+		list := *m.freeCells(sCenter)
+		if position := int(m.slots[index]); position >= len(list) || int(list[position]) != index {
+			t.Fatalf("free cell %d is not at position %d of its list", index, position)
+		}
+	}
+	if free[false] != m.CountFreeCellsOfFCenters() || free[true] != m.CountFreeCellsOfSCenters() {
+		t.Fatalf("%d free F- and %d free S-centers, the lists hold %d and %d",
+			free[false], free[true], m.CountFreeCellsOfFCenters(), m.CountFreeCellsOfSCenters())
+	}
+}
+
+func TestMatrixFreeLists(t *testing.T) {
+	m := &Matrix{}
+	sCenters := []Coordinates{{X: 1, Y: 1}, {X: 3, Y: 2}}
+	if err := m.InitWithSCenters(4, 3, sCenters); err != nil {
+		t.Fatal(err)
+	}
+	if m.NumOfSSites != 2 || m.NumOfFSites != 10 {
+		t.Fatalf("%d S- and %d F-centers, want 2 and 10", m.NumOfSSites, m.NumOfFSites)
+	}
+	checkFreeLists(t, m)
+
+	m.SetAtomOnCell(1, 1, 5)
+	m.SetAtomOnCell(0, 0, 6)
+	m.SetAtomOnCell(3, 2, 7)
+	checkFreeLists(t, m)
+	if cell := m.GetCellInfo(1, 1); cell.IsFree || cell.AtomId != 5 || cell.Center != 'S' {
+		t.Errorf("cell (1, 1) is %+v", cell)
+	}
+	if _, ok := m.RandomFreeCell('S', random.New(1)); ok {
+		t.Error("a free S-center was found with both occupied")
+	}
+
+	// Clearing a cell twice frees it once
+	m.ClearCell(1, 1)
+	m.ClearCell(1, 1)
+	checkFreeLists(t, m)
+	if m.AtomAt(0, 0) != 6 || m.AtomAt(1, 1) != 0 || m.CountFreeCellsOfSCenters() != 1 {
+		t.Errorf("atoms %d and %d, %d free S-centers", m.AtomAt(0, 0), m.AtomAt(1, 1), m.CountFreeCellsOfSCenters())
+	}
+
+	r := random.New(1)
+	for range 100 {
+		cell, ok := m.RandomFreeCell('F', r)
+		if !ok || !cell.IsFree || cell.Center != 'F' {
+			t.Fatalf("RandomFreeCell returned %+v, %t", cell, ok)
+		}
+	}
+}
+
+func TestMatrixRows(t *testing.T) {
+	m := &Matrix{}
+	m.InitRows(4, 2, 4, []Coordinates{{X: 1, Y: 1}, {X: 2, Y: 3}})
+	if m.NumOfSSites != 1 || m.NumOfFSites != 7 {
+		t.Fatalf("%d S- and %d F-centers in rows 2-3, want 1 and 7", m.NumOfSSites, m.NumOfFSites)
+	}
+	if !m.Contains(Coordinates{X: 3, Y: 3}) || m.Contains(Coordinates{X: 0, Y: 1}) {
+		t.Error("Contains does not match rows 2-3")
+	}
+
+	m.SetAtomOnCell(2, 3, 1)
+	checkFreeLists(t, m)
+	if cell := m.GetCellInfo(2, 3); cell.X != 2 || cell.Y != 3 || cell.Center != 'S' || cell.AtomId != 1 {
+		t.Errorf("cell (2, 3) is %+v", cell)
+	}
+	if centers := m.SCenters(); len(centers) != 1 || centers[0] != (Coordinates{X: 2, Y: 3}) {
+		t.Errorf("S-centers %v, want [{2 3}]", centers)
+	}
+}
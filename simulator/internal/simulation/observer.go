@@ -0,0 +1,61 @@
+package simulation
+
+// Observer watches a run from inside the simulation loop. The callbacks run synchronously on the
+// simulation goroutine, so slow callbacks slow the run down.
+type Observer interface {
+	// OnEvent is called after every executed event.
+	OnEvent(event Event)
+	// OnSnapshot is called after every row written to the results table.
+	OnSnapshot(snapshot Snapshot)
+	// OnSteadyState is called when the quasi-steady state is detected, before the run stops.
+	OnSteadyState(steadyState SteadyState)
+	// OnEnd is called once the run has finished and its results are closed.
+	OnEnd(result Result)
+}
+
+// BaseObserver implements Observer with callbacks that do nothing; embed it to implement only some of them.
+type BaseObserver struct{}
+
+func (BaseObserver) OnEvent(Event)             {}
+func (BaseObserver) OnSnapshot(Snapshot)       {}
+func (BaseObserver) OnSteadyState(SteadyState) {}
+func (BaseObserver) OnEnd(Result)              {}
+
+// Outcomes of an event.
+const (
+	OutcomeAdsorbed        = "adsorbed"
+	OutcomeDesorbed        = "desorbed"
+	OutcomeRecombEr        = "recombEr"
+	OutcomeHopped          = "hopped"
+	OutcomeRecombLhF       = "recombLhF"
+	OutcomeRecombLhS       = "recombLhS"
+	OutcomeBlockedRejected = "blockedRejected"
+	OutcomeBlockedDesorbed = "blockedDesorbed"
+	// OutcomeNone is an event that found no atom or cell to act on
+	OutcomeNone = "none"
+)
+
+// Event is an executed kMC event.
+type Event struct {
+	// Number of the event in the run, starting at 1
+	Number int64
+	// Process selected: adsorptionF, adsorptionS, recombEr, desorptionF, diffusion,
+	// or encounter for the hops onto occupied cells when diffusion is accelerated
+	Process string
+	Element string
+	// Cell of the adsorbed, desorbed or hopping atom
+	From Coordinates
+	// Target cell of a hop; equals From for other events
+	To Coordinates
+	// Element of the recombination partner, if any
+	Partner      string
+	Outcome      string
+	PhysicalTime float64
+}
+
+// Snapshot is the state written as a row of the results table.
+type Snapshot struct {
+	PhysicalTime float64
+	Total        InfoWithCombinedAtoms
+	Elements     map[string]Info
+}
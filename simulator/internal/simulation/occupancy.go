@@ -0,0 +1,250 @@
+package simulation
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"github.com/zipliZ/surface-atoms/simulator/configs"
+	"github.com/zipliZ/surface-atoms/simulator/internal/occupancy"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Defaults of the occupancy maps.
+const (
+	defaultSnapshotCellPixels = 4
+	defaultSnapshotFrameDelay = 20
+)
+
+// snapshotsDirName is the directory of the occupancy maps next to the results.
+const snapshotsDirName = "snapshots"
+
+// snapshotter writes the occupancy maps of the lattice at the configured physical times and adds them
+// to the animations as they are written.
+type snapshotter struct {
+	dir           string
+	animationBase string
+	formats       []string
+	animations    []string
+	cellPixels    int
+	frameDelay    int
+	legend        []string
+	palette       color.Palette
+	// Configured times not reached yet, in order, the time between the periodic maps and the number
+	// of the next periodic one
+	times    []float64
+	interval float64
+	period   float64
+	// Physical time of the next map and of the next periodic one
+	next         float64
+	nextInterval float64
+	// Maps written so far
+	index []SnapshotFile
+	// Animations, the GIF is created with the first map
+	gif  *occupancy.GIFWriter
+	html *occupancy.HTMLAnimation
+}
+
+// SnapshotFile is an occupancy map listed in snapshots/index.json.
+type SnapshotFile struct {
+	PhysicalTime float64 `json:"physicalTime"`
+	// Base name of the files of the map in the snapshots directory, without the extension
+	Name string `json:"name"`
+}
+
+// snapshotIndex is snapshots/index.json: what the states of the cells in the maps mean and the maps written.
+type snapshotIndex struct {
+	Legend    []string       `json:"legend"`
+	Snapshots []SnapshotFile `json:"snapshots"`
+}
+
+func newSnapshotter(cfg configs.Snapshots, dirName, baseName string, elems []string, simulationTime, startTime float64) (*snapshotter, error) {
+	if len(cfg.Times) == 0 && cfg.Percent <= 0 {
+		return nil, nil
+	}
+
+	if len(elems) > occupancy.MaxElements {
+		return nil, fmt.Errorf("occupancy maps tell at most %d elements apart, got %d", occupancy.MaxElements, len(elems))
+	}
+	if cfg.CellPixels < 0 || cfg.FrameDelay < 0 {
+		return nil, fmt.Errorf("snapshot cell pixels %d and frame delay %d must not be negative", cfg.CellPixels, cfg.FrameDelay)
+	}
+	formats := cfg.Formats
+	if len(formats) == 0 {
+		formats = []string{occupancy.FormatPNG}
+	}
+	for _, format := range formats {
+		switch format {
+		case occupancy.FormatPNG, occupancy.FormatCSV, occupancy.FormatNPY:
+		default:
+			return nil, fmt.Errorf("unknown snapshot format %q", format)
+		}
+	}
+	for _, animation := range cfg.Animations {
+		switch animation {
+		case occupancy.AnimationGIF, occupancy.AnimationHTML:
+		default:
+			return nil, fmt.Errorf("unknown snapshot animation %q", animation)
+		}
+	}
+
+	dir := filepath.Join(dirName, snapshotsDirName)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	sn := &snapshotter{
+		dir:           dir,
+		animationBase: baseName + "_occupancy",
+		formats:       formats,
+		animations:    cfg.Animations,
+		cellPixels:    cmp.Or(cfg.CellPixels, defaultSnapshotCellPixels),
+		frameDelay:    cmp.Or(cfg.FrameDelay, defaultSnapshotFrameDelay),
+		legend:        occupancy.Legend(elems),
+		palette:       occupancy.Palette(len(elems)),
+		nextInterval:  math.Inf(1),
+	}
+	if slices.Contains(cfg.Animations, occupancy.AnimationHTML) {
+		sn.html = occupancy.NewHTMLAnimation(sn.legend, sn.palette, sn.cellPixels, sn.frameDelay)
+	}
+	// A run resumed from a checkpoint writes the maps after its physical time
+	for _, t := range slices.Sorted(slices.Values(cfg.Times)) {
+		if t >= startTime {
+			sn.times = append(sn.times, t)
+		}
+	}
+	if cfg.Percent > 0 {
+		sn.interval = simulationTime * cfg.Percent / 100
+		sn.period = math.Ceil(startTime / sn.interval)
+		sn.nextInterval = sn.interval * sn.period
+	}
+	sn.schedule()
+	return sn, nil
+}
+
+// advance moves past the map just written at the time of the next map.
+func (sn *snapshotter) advance() {
+	for len(sn.times) > 0 && sn.times[0] <= sn.next {
+		sn.times = sn.times[1:]
+	}
+	for sn.nextInterval <= sn.next {
+		sn.period++
+		sn.nextInterval = sn.interval * sn.period
+	}
+	sn.schedule()
+}
+
+// schedule sets the time of the next map: the earliest of the next configured and the next periodic one.
+func (sn *snapshotter) schedule() {
+	sn.next = sn.nextInterval
+	if len(sn.times) > 0 {
+		sn.next = min(sn.next, sn.times[0])
+	}
+}
+
+// write writes the map in every format and adds it to the animations.
+func (sn *snapshotter) write(m occupancy.Map) error {
+	name := fmt.Sprintf("occupancy_%04d", len(sn.index))
+	base := filepath.Join(sn.dir, name)
+	for _, format := range sn.formats {
+		var err error
+		switch format {
+		case occupancy.FormatPNG:
+			err = occupancy.WritePNG(base+".png", m, sn.palette, sn.cellPixels)
+		case occupancy.FormatCSV:
+			err = occupancy.WriteCSV(base+".csv", m)
+		case occupancy.FormatNPY:
+			err = occupancy.WriteNPY(base+".npy", m)
+		}
+		if err != nil {
+			return fmt.Errorf("occupancy map: %w", err)
+		}
+	}
+
+	sn.index = append(sn.index, SnapshotFile{PhysicalTime: m.PhysicalTime, Name: name})
+	if err := sn.animate(m); err != nil {
+		return fmt.Errorf("occupancy animation: %w", err)
+	}
+	return nil
+}
+
+// animate adds the map to the animations.
+func (sn *snapshotter) animate(m occupancy.Map) error {
+	if slices.Contains(sn.animations, occupancy.AnimationGIF) {
+		if sn.gif == nil {
+			var err error
+			path := sn.animationBase + "." + occupancy.AnimationGIF
+			if sn.gif, err = occupancy.NewGIFWriter(path, m.LenX, m.LenY, sn.palette, sn.cellPixels, sn.frameDelay); err != nil {
+				return err
+			}
+		}
+		if err := sn.gif.WriteFrame(m); err != nil {
+			return err
+		}
+	}
+	if sn.html != nil {
+		return sn.html.Add(m)
+	}
+	return nil
+}
+
+// close writes the index of the maps and finishes the animations.
+func (sn *snapshotter) close() error {
+	index, err := json.MarshalIndent(snapshotIndex{Legend: sn.legend, Snapshots: sn.index}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(filepath.Join(sn.dir, "index.json"), index, 0644); err != nil {
+		return err
+	}
+
+	if sn.gif != nil {
+		if err = sn.gif.Close(); err != nil {
+			return fmt.Errorf("occupancy animation: %w", err)
+		}
+	}
+	if sn.html != nil {
+		if err = sn.html.Write(sn.animationBase + "." + occupancy.AnimationHTML); err != nil {
+			return fmt.Errorf("occupancy animation: %w", err)
+		}
+	}
+	return nil
+}
+
+// takeSnapshots writes the occupancy maps due before the physical time: the surface stays as it is until then.
+func (s *Simulator) takeSnapshots(until float64) error {
+	for s.snapshots.next < until {
+		if err := s.snapshots.write(s.occupancyMap(s.snapshots.next)); err != nil {
+			return err
+		}
+		s.snapshots.advance()
+	}
+	return nil
+}
+
+// closeSnapshots writes the map of the surface the run stopped with when it stopped early, then the index
+// of the maps and the animations.
+func (s *Simulator) closeSnapshots(stopReason string) error {
+	index := s.snapshots.index
+	if stopReason != StopCompleted && (len(index) == 0 || index[len(index)-1].PhysicalTime < s.currentSimulationTime) {
+		if err := s.snapshots.write(s.occupancyMap(s.currentSimulationTime)); err != nil {
+			return err
+		}
+	}
+	return s.snapshots.close()
+}
+
+// occupancyMap returns the state of every cell of the lattice.
+func (s *Simulator) occupancyMap(physicalTime float64) occupancy.Map {
+	m := occupancy.NewMap(physicalTime, s.cfg.Simulating.MatrixLenX, s.cfg.Simulating.MatrixLenY)
+	for _, center := range s.sCenters() {
+		m.Set(int(center.X), int(center.Y), occupancy.FreeS)
+	}
+	for _, atom := range s.surfaceAtoms() {
+		m.Set(int(atom.X), int(atom.Y), occupancy.AtomState(atom.Element, atom.OccupiedCentre))
+	}
+	return m
+}
@@ -0,0 +1,328 @@
+package simulation
+
+import (
+	"fmt"
+	randomx "github.com/zipliZ/surface-atoms/simulator/internal/random"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// In parallel mode the lattice is split into 2*domains strips of rows, every one simulated by its own
+// Simulator with its own random numbers (the synchronous sublattice algorithm). A cycle runs the even strips
+// concurrently for the cycle time while the odd ones are frozen, then the odd strips while the even ones
+// are frozen. A strip running next to frozen ones is their only writer, so a hop across the border is
+// decided on the state of the frozen neighbour and applied to it once the strips of the phase are done.
+
+// stripState links a strip to its neighbours and keeps the hops across its borders during a phase.
+type stripState struct {
+	// Strips of the rows before and after this one, nil at the edges of the lattice
+	lower *Simulator
+	upper *Simulator
+	// Border cells of the neighbours as changed by this strip during the phase
+	ghosts map[Coordinates]ghostCell
+	// Changes of the neighbours, applied in order after the phase
+	outbox []borderEvent
+}
+
+type ghostCell struct {
+	free    bool
+	center  rune
+	element int
+	// Track of the atom on the cell
+	track Track
+}
+
+// borderEvent is an atom arriving on a cell of a neighbouring strip, or the atom on it recombining.
+type borderEvent struct {
+	cell    Coordinates
+	arrive  bool
+	element int
+	track   Track
+}
+
+// splitStrips moves the surface into the strips of parallel mode. The simulator itself keeps only the
+// number of sites of every type and collects the counters of the strips.
+func (s *Simulator) splitStrips() error {
+	parallel := s.cfg.Simulating.Parallel
+	lenX, lenY := s.cfg.Simulating.MatrixLenX, s.cfg.Simulating.MatrixLenY
+	count := 2 * parallel.Domains
+	if lenY < 2*count {
+		return fmt.Errorf("a matrix of %d rows cannot be split in %d strips of at least 2 rows", lenY, count)
+	}
+	if parallel.CycleTime < 0 {
+		return fmt.Errorf("parallel cycle time %g must not be negative", parallel.CycleTime)
+	}
+	s.cycleTime = parallel.CycleTime
+	if s.cycleTime == 0 {
+		s.cycleTime = s.siteEventTime()
+	}
+
+	sCenters := s.matrix.SCenters()
+	s.strips = make([]*Simulator, count)
+	for i := range s.strips {
+		matrix := NewMatrix(s.cfg.Constants)
+		matrix.InitRows(lenX, lenY*i/count, lenY*(i+1)/count, sCenters)
+
+		infoCollector, err := NewInfoCollector(nil, s.cfg.Simulating.FloatPrecision, s.cfg.Elements, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		s.strips[i] = &Simulator{
+			cfg:             s.cfg,
+			matrix:          matrix,
+			atomsController: NewSurfaceAtomsController(lenX, lenY, matrix, s.cfg.Elements),
+			infoCollector:   infoCollector,
+			temperature:     s.temperature,
+			rand:            randomx.New(s.rand.Uint64()),
+			meta:            s.meta,
+			elems:           s.elems,
+			elementsByName:  s.elementsByName,
+			formedAtomNames: s.formedAtomNames,
+			rates:           slices.Clone(s.rates),
+			strip:           &stripState{ghosts: make(map[Coordinates]ghostCell)},
+		}
+		if s.residence != nil {
+			s.strips[i].residence = newResidenceTimes(len(s.elems))
+		}
+		if s.budget != nil {
+			s.strips[i].budget = newProcessBudget(len(s.rates))
+		}
+	}
+	for i, strip := range s.strips {
+		if i > 0 {
+			strip.strip.lower = s.strips[i-1]
+		}
+		if i < count-1 {
+			strip.strip.upper = s.strips[i+1]
+		}
+	}
+
+	for _, atom := range s.atomsController.Atoms() {
+		s.stripOf(Coordinates{X: atom.X, Y: atom.Y}).atomsController.AddAtomOnSurface(Atom{
+			X:              atom.X,
+			Y:              atom.Y,
+			OccupiedCentre: atom.OccupiedCentre,
+			Element:        atom.Element,
+			Track:          atom.Track,
+		})
+	}
+	s.matrix = &Matrix{NumOfSSites: s.matrix.NumOfSSites, NumOfFSites: s.matrix.NumOfFSites, consts: s.cfg.Constants}
+	s.atomsController = NewSurfaceAtomsController(lenX, lenY, s.matrix, s.cfg.Elements)
+
+	slog.Info("parallel mode", "domains", parallel.Domains, "strips", count, "cycle_time", s.cycleTime)
+	return nil
+}
+
+// siteEventTime is the mean time between events on a single site when all processes run at their fastest:
+// adsorption of every element on a free site, or desorption, Eley-Rideal recombination and a hop of an atom.
+func (s *Simulator) siteEventTime() float64 {
+	adsorption, atom := 0.0, 0.0
+	for _, meta := range s.meta {
+		adsorption += meta.atomFlux / (s.cfg.Constants.FDensity + s.cfg.Constants.SDensity)
+		atom = max(atom, meta.r2+meta.r4+meta.r5)
+	}
+	return 1 / max(adsorption, atom)
+}
+
+func (s *Simulator) stripOf(cell Coordinates) *Simulator {
+	for _, strip := range s.strips {
+		if strip.matrix.Contains(cell) {
+			return strip
+		}
+	}
+	return nil
+}
+
+// simulateCycle advances every strip by the cycle time: the even strips first, then the odd ones.
+func (s *Simulator) simulateCycle() {
+	for parity := range 2 {
+		var wg sync.WaitGroup
+		for i := parity; i < len(s.strips); i += 2 {
+			strip := s.strips[i]
+			wg.Go(func() {
+				strip.runPhase(s.currentSimulationTime, s.cycleTime)
+			})
+		}
+		wg.Wait()
+
+		for i := parity; i < len(s.strips); i += 2 {
+			strip := s.strips[i]
+			strip.applyBorderEvents()
+			s.events += strip.events
+			strip.events = 0
+		}
+	}
+	s.currentSimulationTime += s.cycleTime
+}
+
+// runPhase executes the events of the strip for the given physical time from the start time. The event
+// that would end after it is dropped: as the waiting times are exponential, the next phase draws it anew.
+func (s *Simulator) runPhase(start, duration float64) {
+	clear(s.strip.ghosts)
+
+	elapsed := 0.0
+	for {
+		process, element, spendTime := s.getProcess()
+		if element < 0 || elapsed+spendTime > duration {
+			if s.budget != nil {
+				for i, rate := range s.rates {
+					s.budget.lambdaTime[i] += rate.lambda * (duration - elapsed)
+				}
+			}
+			return
+		}
+		elapsed += spendTime
+		s.currentSimulationTime = start + elapsed
+		s.events++
+		s.executeChosen(process, element, spendTime)
+	}
+}
+
+// hopAcross moves the atom onto a border cell of a neighbouring strip if it is free, or recombines it
+// with the atom occupying it, like hopAtom.
+func (s *Simulator) hopAcross(atom Atom, next Coordinates, recombine bool) bool {
+	ghost, changed := s.strip.ghosts[next]
+	if !changed {
+		neighbour := s.strip.neighbour(next)
+		cell := neighbour.matrix.GetCellInfo(next.X, next.Y)
+		ghost = ghostCell{free: cell.IsFree, center: cell.Center}
+		if !cell.IsFree {
+			nextAtom := neighbour.atomsController.Atom(cell.AtomId)
+			ghost.element, ghost.track = nextAtom.Element, nextAtom.Track
+		}
+	}
+
+	if ghost.free {
+		s.atomsController.RemoveAtomFromSurface(atom.Id)
+		track := atom.Track
+		track.DX += int32(next.X) - int32(atom.X)
+		track.DY += int32(next.Y) - int32(atom.Y)
+		s.strip.ghosts[next] = ghostCell{center: ghost.center, element: atom.Element, track: track}
+		s.strip.outbox = append(s.strip.outbox, borderEvent{cell: next, arrive: true, element: atom.Element, track: track})
+		s.event.Outcome = OutcomeHopped
+		return true
+	}
+
+	if !recombine || !s.recombineLh(atom, ghost.element, ghost.center) {
+		return false
+	}
+	channel := lhExitChannel(ghost.center)
+	s.recordExit(atom, channel)
+	s.recordExit(Atom{Element: ghost.element, Track: ghost.track}, channel)
+	s.atomsController.RemoveAtomFromSurface(atom.Id)
+	s.strip.ghosts[next] = ghostCell{free: true, center: ghost.center}
+	s.strip.outbox = append(s.strip.outbox, borderEvent{cell: next})
+	return true
+}
+
+func (st *stripState) neighbour(cell Coordinates) *Simulator {
+	if st.lower != nil && st.lower.matrix.Contains(cell) {
+		return st.lower
+	}
+	return st.upper
+}
+
+// applyBorderEvents applies the hops across the borders of the strip to its neighbours.
+func (s *Simulator) applyBorderEvents() {
+	for _, event := range s.strip.outbox {
+		neighbour := s.strip.neighbour(event.cell)
+		cell := neighbour.matrix.GetCellInfo(event.cell.X, event.cell.Y)
+		if event.arrive {
+			neighbour.atomsController.AddAtomOnSurface(Atom{
+				X:              cell.X,
+				Y:              cell.Y,
+				OccupiedCentre: cell.Center,
+				Element:        event.element,
+				Track:          event.track,
+			})
+		} else if !cell.IsFree {
+			neighbour.atomsController.RemoveAtomFromSurface(cell.AtomId)
+		}
+	}
+	s.strip.outbox = s.strip.outbox[:0]
+}
+
+// collectStrips adds the counters of the strips to those of the simulator and resets them.
+func (s *Simulator) collectStrips() {
+	for _, strip := range s.strips {
+		for _, elementName := range s.elems {
+			info := s.infoCollector.Info[elementName]
+			info.add(strip.infoCollector.Info[elementName])
+			s.infoCollector.Info[elementName] = info
+			strip.infoCollector.Info[elementName] = Info{}
+		}
+		for formedAtomName, count := range strip.infoCollector.TotalInfo.FormedAtoms {
+			s.infoCollector.TotalInfo.FormedAtoms[formedAtomName] += count
+		}
+		clear(strip.infoCollector.TotalInfo.FormedAtoms)
+		if strip.residence != nil {
+			s.residence.collect(strip.residence)
+		}
+		if strip.budget != nil {
+			s.budget.collect(strip.budget)
+		}
+	}
+}
+
+// atomsOn returns the number of atoms of the element on F- and S-centers.
+func (s *Simulator) atomsOn(element int) (onF, onS int) {
+	if s.strips == nil {
+		return s.atomsController.AtomsOnFCenters[element].Len(), s.atomsController.AtomsOnSCenters[element].Len()
+	}
+	for _, strip := range s.strips {
+		stripOnF, stripOnS := strip.atomsOn(element)
+		onF += stripOnF
+		onS += stripOnS
+	}
+	return onF, onS
+}
+
+// atomCount returns the number of atoms on the surface.
+func (s *Simulator) atomCount() int {
+	count := s.atomsController.Count()
+	for _, strip := range s.strips {
+		count += strip.atomsController.Count()
+	}
+	return count
+}
+
+// surfaceAtoms returns the atoms on the surface, with the ids of the strips they are on in parallel mode.
+func (s *Simulator) surfaceAtoms() []Atom {
+	atoms := s.atomsController.Atoms()
+	for _, strip := range s.strips {
+		atoms = append(atoms, strip.atomsController.Atoms()...)
+	}
+	return atoms
+}
+
+// sCenters returns the coordinates of all S-centers.
+func (s *Simulator) sCenters() []Coordinates {
+	if s.strips == nil {
+		return s.matrix.SCenters()
+	}
+	var centers []Coordinates
+	for _, strip := range s.strips {
+		centers = append(centers, strip.matrix.SCenters()...)
+	}
+	return centers
+}
+
+// atomsBytes returns the number of bytes allocated for the atoms on the lattice and their indexes.
+func (s *Simulator) atomsBytes() int {
+	bytes := s.atomsController.MemoryUsage()
+	for _, strip := range s.strips {
+		bytes += strip.atomsController.MemoryUsage()
+	}
+	return bytes
+}
+
+// latticeBytes returns the number of bytes allocated for the lattice.
+func (s *Simulator) latticeBytes() int {
+	bytes := s.matrix.MemoryUsage()
+	for _, strip := range s.strips {
+		bytes += strip.matrix.MemoryUsage()
+	}
+	return bytes
+}
@@ -0,0 +1,248 @@
+package simulation
+
+import (
+	"fmt"
+	"github.com/zipliZ/surface-atoms/simulator/configs"
+	"github.com/zipliZ/surface-atoms/simulator/internal/output"
+	"math"
+	"slices"
+)
+
+// Exit channels of an atom leaving the surface.
+const (
+	exitDesorption = iota
+	exitRecombEr
+	exitRecombLhF
+	exitRecombLhS
+	exitBlockedHop
+)
+
+// Names of the exit channels in run.json and in the checkpoint, and in the headers of the results.
+var (
+	exitChannels       = []string{"desorption", "recombEr", "recombLhF", "recombLhS", "blockedHop"}
+	exitChannelHeaders = []string{"Desorption", "Recomb Er", "Recomb Lh F", "Recomb Lh S", "Blocked hop"}
+)
+
+// Bins of the residence time histogram per decade of the residence time.
+const residenceBinsPerDecade = 10
+
+// residenceTable is the name of the further table of the residence time histogram.
+const residenceTable = "Residence times"
+
+// ResidenceHistogram counts the residence times of the atoms of an element that left the surface through
+// an exit channel: from their adsorption to the event removing them.
+type ResidenceHistogram struct {
+	Count int     `json:"count"`
+	Sum   float64 `json:"sum"`
+	// Number of the atoms adsorbed on S-centers
+	AdsorbedOnS int `json:"adsorbedOnS"`
+	// Number of atoms per bin of the residence time; bin i holds the times from 10^(i/10) to 10^((i+1)/10) seconds
+	Bins map[int]int `json:"bins"`
+}
+
+func (h *ResidenceHistogram) add(other ResidenceHistogram) {
+	h.Count += other.Count
+	h.Sum += other.Sum
+	h.AdsorbedOnS += other.AdsorbedOnS
+	for bin, count := range other.Bins {
+		h.Bins[bin] += count
+	}
+}
+
+// Residence is the residence time of the atoms of an element that left the surface through an exit channel.
+type Residence struct {
+	Count int `json:"count"`
+	// Mean residence time in seconds, 0 when no atom left through the channel
+	MeanTime float64 `json:"meanTime"`
+	// Number of the atoms adsorbed on S-centers
+	AdsorbedOnS int `json:"adsorbedOnS"`
+}
+
+// residenceTimes accumulates the residence times of the atoms by element and exit channel.
+type residenceTimes struct {
+	histograms [][]ResidenceHistogram
+	// First extra column of the mean residence times
+	column int
+	// Further tables of the histogram
+	tables []output.Writer
+}
+
+func newResidenceTimes(elements int) *residenceTimes {
+	r := &residenceTimes{histograms: make([][]ResidenceHistogram, elements)}
+	for element := range r.histograms {
+		r.histograms[element] = make([]ResidenceHistogram, len(exitChannels))
+		for channel := range r.histograms[element] {
+			r.histograms[element][channel].Bins = make(map[int]int)
+		}
+	}
+	return r
+}
+
+// record counts the atom leaving the surface through the channel at the physical time.
+func (r *residenceTimes) record(atom Atom, channel int, physicalTime float64) {
+	h := &r.histograms[atom.Element][channel]
+	residenceTime := physicalTime - atom.AdsorbedAt
+	h.Count++
+	h.Sum += residenceTime
+	if atom.AdsorbedOn == 'S' {
+		h.AdsorbedOnS++
+	}
+	if residenceTime > 0 {
+		h.Bins[int(math.Floor(math.Log10(residenceTime)*residenceBinsPerDecade))]++
+	}
+}
+
+// collect adds the residence times of other and resets them.
+func (r *residenceTimes) collect(other *residenceTimes) {
+	for element, histograms := range other.histograms {
+		for channel := range histograms {
+			r.histograms[element][channel].add(histograms[channel])
+			histograms[channel] = ResidenceHistogram{Bins: make(map[int]int)}
+		}
+	}
+}
+
+// recordExit counts the atom leaving the surface through the channel, if the residence times are collected.
+func (s *Simulator) recordExit(atom Atom, channel int) {
+	if s.residence != nil {
+		s.residence.record(atom, channel, s.currentSimulationTime)
+	}
+}
+
+// residenceColumns returns the headers of the results table columns with the mean residence time of every
+// element, over all exit channels and through every one of them.
+func residenceColumns(elements []configs.Element) []string {
+	var columns []string
+	for _, element := range elements {
+		columns = append(columns, fmt.Sprintf("%s - Mean residence time", element.Name))
+		for _, channel := range exitChannelHeaders {
+			columns = append(columns, fmt.Sprintf("%s - Mean residence time %s", element.Name, channel))
+		}
+	}
+	return columns
+}
+
+// openTables creates the further table of the residence time histogram in the writers able to store it.
+func (r *residenceTimes) openTables(writers []output.Writer, elems []string) error {
+	headers := []string{"Residence time from", "Residence time to"}
+	for _, element := range elems {
+		for _, channel := range exitChannelHeaders {
+			headers = append(headers, fmt.Sprintf("%s - %s", element, channel))
+		}
+	}
+
+	var err error
+	r.tables, err = openTables(writers, residenceTable, headers)
+	return err
+}
+
+// setResidenceColumns sets the mean residence times in the extra columns.
+func (s *Simulator) setResidenceColumns() {
+	extra := s.infoCollector.Extra[s.residence.column:]
+	for _, histograms := range s.residence.histograms {
+		count, sum := 0, 0.0
+		for _, h := range histograms {
+			count += h.Count
+			sum += h.Sum
+		}
+		extra[0] = meanResidenceTime(count, sum)
+		for channel, h := range histograms {
+			extra[1+channel] = meanResidenceTime(h.Count, h.Sum)
+		}
+		extra = extra[1+len(histograms):]
+	}
+}
+
+func meanResidenceTime(count int, sum float64) float64 {
+	if count == 0 {
+		return math.NaN()
+	}
+	return sum / float64(count)
+}
+
+// writeResidenceTables writes the residence time histogram of the run, a row per bin from the shortest
+// to the longest residence time.
+func (s *Simulator) writeResidenceTables() error {
+	var bins []int
+	for _, histograms := range s.residence.histograms {
+		for _, h := range histograms {
+			for bin := range h.Bins {
+				bins = append(bins, bin)
+			}
+		}
+	}
+	if len(bins) == 0 {
+		return nil
+	}
+
+	for bin := slices.Min(bins); bin <= slices.Max(bins); bin++ {
+		row := []float64{
+			math.Pow(10, float64(bin)/residenceBinsPerDecade),
+			math.Pow(10, float64(bin+1)/residenceBinsPerDecade),
+		}
+		for _, histograms := range s.residence.histograms {
+			for _, h := range histograms {
+				row = append(row, float64(h.Bins[bin]))
+			}
+		}
+		if err := writeTableRow(s.residence.tables, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// residences returns the residence times of every element by exit channel, nil when they are not collected.
+func (s *Simulator) residences() map[string]map[string]Residence {
+	if s.residence == nil {
+		return nil
+	}
+
+	residences := make(map[string]map[string]Residence, len(s.elems))
+	for element, elementName := range s.elems {
+		residences[elementName] = make(map[string]Residence, len(exitChannels))
+		for channel, h := range s.residence.histograms[element] {
+			residence := Residence{Count: h.Count, AdsorbedOnS: h.AdsorbedOnS}
+			if h.Count > 0 {
+				residence.MeanTime = h.Sum / float64(h.Count)
+			}
+			residences[elementName][exitChannels[channel]] = residence
+		}
+	}
+	return residences
+}
+
+// residenceHistograms returns the histograms of every element by exit channel for the checkpoint.
+func (s *Simulator) residenceHistograms() map[string]map[string]ResidenceHistogram {
+	if s.residence == nil {
+		return nil
+	}
+
+	histograms := make(map[string]map[string]ResidenceHistogram, len(s.elems))
+	for element, elementName := range s.elems {
+		histograms[elementName] = make(map[string]ResidenceHistogram, len(exitChannels))
+		for channel, h := range s.residence.histograms[element] {
+			histograms[elementName][exitChannels[channel]] = h
+		}
+	}
+	return histograms
+}
+
+// restoreResidenceHistograms continues the histograms of a checkpoint.
+func (s *Simulator) restoreResidenceHistograms(histograms map[string]map[string]ResidenceHistogram) {
+	if s.residence == nil {
+		return
+	}
+
+	for elementName, channels := range histograms {
+		element := slices.Index(s.elems, elementName)
+		if element < 0 {
+			continue
+		}
+		for channelName, h := range channels {
+			if channel := slices.Index(exitChannels, channelName); channel >= 0 {
+				s.residence.histograms[element][channel].add(h)
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package simulation
+
+import (
+	"maps"
+	"time"
+)
+
+// Result summarises a finished run.
+type Result struct {
+	Temperature    int
+	SimulationTime float64
+	Seed           uint64
+	// StopCompleted, StopQuasiSteadyState, StopInterrupted or StopError
+	StopReason   string
+	PhysicalTime float64
+	Events       int64
+	StartedAt    time.Time
+	FinishedAt   time.Time
+	// Info summed over the elements, with the count of every formed molecule
+	Total InfoWithCombinedAtoms
+	// Final info per element
+	Elements    map[string]Info
+	SteadyState SteadyState
+	// Rate constants per element
+	Rates map[string]map[string]float64
+	// Memory taken by the lattice at start-up and by the atoms during the run
+	Memory MemoryUsage
+	// Scaling of the hop rate of every element, nil when the diffusion acceleration is off
+	Acceleration map[string]Acceleration
+	// Residence times of the atoms of every element by exit channel, nil when they are not collected
+	Residence map[string]map[string]Residence
+	// Tracer diffusion of every element at the end of the run, nil when it is not estimated
+	Diffusion map[string]Diffusion
+	// Events and wall time of every process of every element, nil when the process budget is not kept
+	Budget map[string]map[string]ProcessBudget
+	// Directory of the result files, empty when the run wrote none
+	ResultDir string
+}
+
+// SteadyState tells whether and when the quasi-steady state was detected.
+type SteadyState struct {
+	Reached      bool
+	PhysicalTime float64
+	StableChecks int
+}
+
+// WallTime returns how long the run took.
+func (r Result) WallTime() time.Duration {
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+// Result returns the summary of the run. It is complete once Simulate has returned.
+func (s *Simulator) Result() Result {
+	s.updateInfo()
+
+	total := s.infoCollector.TotalInfo
+	total.FormedAtoms = maps.Clone(s.infoCollector.TotalInfo.FormedAtoms)
+
+	return Result{
+		Temperature:    s.temperature,
+		SimulationTime: s.simulationTime,
+		Seed:           s.cfg.Simulating.Seed,
+		StopReason:     s.stopReason,
+		PhysicalTime:   s.currentSimulationTime,
+		Events:         s.events,
+		StartedAt:      s.startedAt,
+		FinishedAt:     s.finishedAt,
+		Total:          total,
+		Elements:       maps.Clone(s.infoCollector.Info),
+		SteadyState: SteadyState{
+			Reached:      s.steadyStateAt > 0,
+			PhysicalTime: s.steadyStateAt,
+			StableChecks: s.stableIterationsCount,
+		},
+		Rates:        s.rateConstants(),
+		Memory:       s.memory,
+		Acceleration: s.accelerations(),
+		Residence:    s.residences(),
+		Diffusion:    s.diffusions(),
+		Budget:       s.budgets(),
+		ResultDir:    s.dirName,
+	}
+}
+
+// rateConstants returns the rate constants of every element.
+func (s *Simulator) rateConstants() map[string]map[string]float64 {
+	rates := make(map[string]map[string]float64, len(s.meta))
+	for i, elementName := range s.elems {
+		meta := s.meta[i]
+		rates[elementName] = meta.RateConstants()
+	}
+	return rates
+}
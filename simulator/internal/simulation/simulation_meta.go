@@ -1,12 +1,36 @@
 package simulation
 
 import (
-	"main/configs"
+	"fmt"
+	"github.com/zipliZ/surface-atoms/simulator/configs"
 	"math"
 )
 
+// CODATA 2018 values of the physical constants.
+const (
+	gasConstant       = 8.314462618       // J/(mol·K)
+	boltzmannConstant = 1.380649e-23      // J/K
+	planckConstant    = 6.62607015e-34    // J·s
+	atomicMassUnit    = 1.66053906660e-27 // kg
+)
+
+// Rate models of the processes with a prefactor.
+const (
+	RateModelArrhenius         = configs.RateModelArrhenius
+	RateModelModifiedArrhenius = configs.RateModelModifiedArrhenius
+	RateModelTST               = configs.RateModelTST
+)
+
+// Processes whose rate model can be configured.
+const (
+	desorptionRate = configs.RateProcessDesorption
+	diffusionRate  = configs.RateProcessDiffusion
+)
+
 type SimulationMeta struct {
 	atomFlux                           float64
+	desorptionPrefactor                float64
+	diffusionPrefactor                 float64
 	r1                                 float64
 	r2                                 float64
 	r3                                 float64
@@ -20,13 +44,26 @@ type SimulationMeta struct {
 	recombinationProbabilityOnFSiteHet float64
 }
 
-func Fill(element configs.Element, constants configs.Constants, temperature float64) SimulationMeta {
-	atomFlux := calculateAtomFlux(element, temperature)
+func Fill(element configs.Element, constants configs.Constants, gas *configs.Gas, temperature float64) (SimulationMeta, error) {
+	desorptionPrefactor, err := calcPrefactor(element, constants, temperature, desorptionRate)
+	if err != nil {
+		return SimulationMeta{}, err
+	}
+	diffusionPrefactor, err := calcPrefactor(element, constants, temperature, diffusionRate)
+	if err != nil {
+		return SimulationMeta{}, err
+	}
+
+	atomFlux, err := calculateAtomFlux(element, gas, temperature)
+	if err != nil {
+		return SimulationMeta{}, err
+	}
+
 	r1 := calcR1(constants, atomFlux)
-	r2 := calcR2(element, temperature)
+	r2 := calcR2(element, temperature, desorptionPrefactor)
 	r3 := calcR3(constants, atomFlux)
 	r4 := calcR4(element, temperature, r3)
-	r5 := calcR5(element, temperature)
+	r5 := calcR5(element, temperature, diffusionPrefactor)
 	r6 := calcR6(element, temperature, r5)
 	r7 := calcR7(element, temperature, r5)
 
@@ -36,28 +73,97 @@ func Fill(element configs.Element, constants configs.Constants, temperature floa
 	recombinationProbabilityOnFSiteHet := calcRecombinationProbabilityOnFSiteHet(element, temperature)
 
 	return SimulationMeta{
-		atomFlux: atomFlux,
-		r1:       r1,
-		r2:       r2,
-		r3:       r3,
-		r4:       r4,
-		r5:       r5,
-		r6:       r6,
-		r7:       r7,
+		atomFlux:            atomFlux,
+		desorptionPrefactor: desorptionPrefactor,
+		diffusionPrefactor:  diffusionPrefactor,
+		r1:                  r1,
+		r2:                  r2,
+		r3:                  r3,
+		r4:                  r4,
+		r5:                  r5,
+		r6:                  r6,
+		r7:                  r7,
 
 		recombinationProbabilityOnSSite:    recombinationProbabilityOnSSite,
 		recombinationProbabilityOnFSite:    recombinationProbabilityOnFSite,
 		recombinationProbabilityOnSSiteHet: recombinationProbabilityOnSSiteHet,
 		recombinationProbabilityOnFSiteHet: recombinationProbabilityOnFSiteHet,
+	}, nil
+}
+
+// RateConstants returns the computed per-process rate constants by name, for reporting.
+func (m SimulationMeta) RateConstants() map[string]float64 {
+	return map[string]float64{
+		"atomFlux":            m.atomFlux,
+		"desorptionPrefactor": m.desorptionPrefactor,
+		"diffusionPrefactor":  m.diffusionPrefactor,
+		"r1":                  m.r1,
+		"r2":                  m.r2,
+		"r3":                  m.r3,
+		"r4":                  m.r4,
+		"r5":                  m.r5,
+		"r6":                  m.r6,
+		"r7":                  m.r7,
+		"pRecombS":            m.recombinationProbabilityOnSSite,
+		"pRecombF":            m.recombinationProbabilityOnFSite,
+		"pRecombSHet":         m.recombinationProbabilityOnSSiteHet,
+		"pRecombFHet":         m.recombinationProbabilityOnFSiteHet,
 	}
 }
 
-func calculateAtomFlux(element configs.Element, temperature float64) float64 {
-	const atomMass = 1.66035e-27
+// calculateAtomFlux calculates the atom flux onto the surface (cm^-2·s^-1).
+// Without a gas block it uses agDensity and the thermal velocity at the surface temperature.
+func calculateAtomFlux(element configs.Element, gas *configs.Gas, temperature float64) (float64, error) {
+	if gas != nil {
+		partialPressure, err := gas.PartialPressure(element.Name)
+		if err != nil {
+			return 0, err
+		}
+		return calcHertzKnudsenFlux(element, partialPressure, gas.Temperature), nil
+	}
 
-	v := math.Sqrt((8*1.38*1e-23*temperature)/(math.Pi*element.Mass*atomMass)) * 1e+2
+	v := math.Sqrt((8*boltzmannConstant*temperature)/(math.Pi*element.Mass*atomicMassUnit)) * 1e+2
 	atomFlux := 0.25 * v * element.AgDensity
-	return atomFlux
+	return atomFlux, nil
+}
+
+// calcHertzKnudsenFlux calculates the atom flux onto the surface (cm^-2·s^-1)
+// from the partial pressure (Pa) and the gas temperature: p / sqrt(2π·m·k·Tg).
+func calcHertzKnudsenFlux(element configs.Element, partialPressure float64, gasTemperature float64) float64 {
+	flux := partialPressure / math.Sqrt(2*math.Pi*element.Mass*atomicMassUnit*boltzmannConstant*gasTemperature)
+	// m^-2 -> cm^-2
+	return flux * 1e-4
+}
+
+// calcPrefactor calculates the prefactor of the process according to its rate model.
+// The transition state theory prefactors assume a mobile transition state:
+// desorption uses the 2D translational partition function over the site area,
+// diffusion uses the 1D flux over the barrier at the site spacing.
+func calcPrefactor(element configs.Element, constants configs.Constants, temperature float64, process string) (float64, error) {
+	prefactor := element.Vdes
+	if process == diffusionRate {
+		prefactor = element.Vdif
+	}
+
+	rateModel := element.RateModels[process]
+	switch rateModel.Model {
+	case "", RateModelArrhenius:
+		return prefactor, nil
+	case RateModelModifiedArrhenius:
+		return prefactor * math.Pow(temperature, rateModel.N), nil
+	case RateModelTST:
+		mass := element.Mass * atomicMassUnit
+		// cm^-2 -> m^2 per site
+		siteArea := 1e-4 / (constants.FDensity + constants.SDensity)
+		if process == diffusionRate {
+			return math.Sqrt(boltzmannConstant*temperature/(2*math.Pi*mass)) / math.Sqrt(siteArea), nil
+		}
+		return boltzmannConstant * temperature / planckConstant *
+			2 * math.Pi * mass * boltzmannConstant * temperature / (planckConstant * planckConstant) *
+			siteArea, nil
+	default:
+		return 0, fmt.Errorf("element %s: unknown rate model %q for %s", element.Name, rateModel.Model, process)
+	}
 }
 
 func calcR1(constants configs.Constants, atomFlux float64) float64 {
@@ -65,8 +171,8 @@ func calcR1(constants configs.Constants, atomFlux float64) float64 {
 	return r1
 }
 
-func calcR2(element configs.Element, temperature float64) float64 {
-	r2 := math.Exp(-(element.Edes / (8.31 * temperature))) * element.Vdes
+func calcR2(element configs.Element, temperature float64, prefactor float64) float64 {
+	r2 := math.Exp(-(element.Edes / (gasConstant * temperature))) * prefactor
 	return r2
 }
 
@@ -76,37 +182,37 @@ func calcR3(constants configs.Constants, atomFlux float64) float64 {
 }
 
 func calcR4(element configs.Element, temperature float64, r3 float64) float64 {
-	r4 := math.Exp(-element.Er/(8.31*temperature)) * r3
+	r4 := math.Exp(-element.Er/(gasConstant*temperature)) * r3
 	return r4
 }
 
-func calcR5(element configs.Element, temperature float64) float64 {
-	r5 := math.Exp(-(element.Edif / (8.31 * temperature))) * element.Vdif
+func calcR5(element configs.Element, temperature float64, prefactor float64) float64 {
+	r5 := math.Exp(-(element.Edif / (gasConstant * temperature))) * prefactor
 	return r5
 }
 
 func calcR6(element configs.Element, temperature float64, r5 float64) float64 {
-	r6 := math.Exp(-element.Er/(8.31*temperature)) * r5
+	r6 := math.Exp(-element.Er/(gasConstant*temperature)) * r5
 	return r6
 }
 
 func calcR7(element configs.Element, temperature float64, r5 float64) float64 {
-	r7 := math.Exp(-element.ErlhF/(8.31*temperature)) * r5
+	r7 := math.Exp(-element.ErlhF/(gasConstant*temperature)) * r5
 	return r7
 }
 
 func calcRecombinationProbabilityOnSSite(element configs.Element, temperature float64) float64 {
-	return math.Exp(-element.ErlhS / (8.31 * float64(temperature)))
+	return math.Exp(-element.ErlhS / (gasConstant * float64(temperature)))
 }
 
 func calcRecombinationProbabilityOnFSite(element configs.Element, temperature float64) float64 {
-	return math.Exp(-element.ErlhF / (8.31 * float64(temperature)))
+	return math.Exp(-element.ErlhF / (gasConstant * float64(temperature)))
 }
 
 func calcRecombinationProbabilityOnSSiteHet(element configs.Element, temperature float64) float64 {
-	return math.Exp(-element.ErlhSHet / (8.31 * float64(temperature)))
+	return math.Exp(-element.ErlhSHet / (gasConstant * float64(temperature)))
 }
 
 func calcRecombinationProbabilityOnFSiteHet(element configs.Element, temperature float64) float64 {
-	return math.Exp(-element.ErlhFHet / (8.31 * float64(temperature)))
+	return math.Exp(-element.ErlhFHet / (gasConstant * float64(temperature)))
 }
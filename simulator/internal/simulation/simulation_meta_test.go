@@ -0,0 +1,38 @@
+package simulation
+
+import (
+	"math"
+	"testing"
+
+	"github.com/zipliZ/surface-atoms/simulator/configs"
+)
+
+func TestCalcPrefactor(t *testing.T) {
+	constants := configs.Constants{FDensity: 1.5e15, SDensity: 3e12}
+	element := configs.Element{Name: "N", Mass: 14.007, Vdes: 1e13, Vdif: 1e12}
+
+	// Computed by hand at 600 K from the CODATA constants; the site area is 1/(1.503e15 cm^-2)
+	for _, c := range []struct {
+		process string
+		model   configs.RateModel
+		want    float64
+	}{
+		{desorptionRate, configs.RateModel{}, 1e13},
+		{diffusionRate, configs.RateModel{Model: RateModelArrhenius}, 1e12},
+		// 1e13 s^-1 · 600^0.5
+		{desorptionRate, configs.RateModel{Model: RateModelModifiedArrhenius, N: 0.5}, 2.449489742783178e14},
+		// kT/h · 2π·m·kT/h² · A
+		{desorptionRate, configs.RateModel{Model: RateModelTST}, 2.293591612262516e15},
+		// sqrt(kT/(2π·m)) / sqrt(A)
+		{diffusionRate, configs.RateModel{Model: RateModelTST}, 9.230170206415083e11},
+	} {
+		element.RateModels = map[string]configs.RateModel{c.process: c.model}
+		got, err := calcPrefactor(element, constants, 600, c.process)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(got-c.want) > 1e-12*c.want {
+			t.Errorf("%s prefactor with %+v = %g, want %g", c.process, c.model, got, c.want)
+		}
+	}
+}
@@ -3,16 +3,21 @@ package simulation
 import (
 	"cmp"
 	"container/list"
+	"context"
+	"errors"
 	"fmt"
+	"github.com/zipliZ/surface-atoms/simulator/configs"
+	"github.com/zipliZ/surface-atoms/simulator/internal/graphic_plotter"
+	"github.com/zipliZ/surface-atoms/simulator/internal/output"
+	"github.com/zipliZ/surface-atoms/simulator/internal/progress"
+	randomx "github.com/zipliZ/surface-atoms/simulator/internal/random"
 	"log/slog"
-	"main/configs"
-	"main/internal/graphic_plotter"
-	randomx "main/internal/random"
+	"maps"
 	"math"
+	mathrand "math/rand/v2"
 	"os"
 	"path/filepath"
 	"slices"
-	"sort"
 	"strings"
 	"time"
 )
@@ -25,10 +30,54 @@ type Simulator struct {
 	temperature           int
 	simulationTime        float64
 	currentSimulationTime float64
-	meta                  map[string]SimulationMeta
-	elems                 []string
-	elementsByName        map[string]configs.Element
-	graphicPlotter        *graphic_plotter.GraphicPlotter
+	rand                  *randomx.Rand
+	// Rate parameters and names of the elements, indexed like the elements of the config
+	meta           []SimulationMeta
+	elems          []string
+	elementsByName map[string]configs.Element
+	// Names of the molecules formed by two elements, by element indexes
+	formedAtomNames [][]string
+	// Rates of every process of every element, updated before each event, and the index of the one chosen last
+	rates          []processRate
+	chosen         int
+	graphicPlotter *graphic_plotter.GraphicPlotter
+	progress       *progress.Reporter
+	dirName        string
+
+	// Provenance recorded in run.json
+	startedAt  time.Time
+	finishedAt time.Time
+	stopReason string
+	runErr     error
+	events     int64
+	// Physical time the quasi-steady state was detected at, 0 when it was not
+	steadyStateAt float64
+	memory        MemoryUsage
+
+	// Strips of the lattice in parallel mode, each simulated by its own Simulator, and the time they
+	// are simulated for between two synchronisations
+	strips    []*Simulator
+	cycleTime float64
+	// Neighbours and border hops of a Simulator that is a strip
+	strip *stripState
+	// Scaling of the hop rates, nil unless the diffusion acceleration is enabled
+	acceleration *accelerator
+	// Log of every executed event, nil unless enabled
+	eventLog *eventLog
+	// Occupancy maps written during the run, nil when none are configured
+	snapshots *snapshotter
+	// Spatial statistics written with every row of the results, nil when they are off
+	spatial *spatialStatistics
+	// Residence times of the atoms that left the surface, nil when they are not collected
+	residence *residenceTimes
+	// Tracer diffusion estimated at every row of the results, nil unless enabled
+	diffusion *tracerDiffusion
+	// Events and wall time of every process, nil unless the process budget is kept
+	budget *processBudget
+
+	// Observers of the loop and the event being executed, filled in by the process handlers
+	observers []Observer
+	event     Event
 
 	// [elementName][parameterName]Values
 	elementValues         map[string]map[string]*Values
@@ -47,50 +96,265 @@ func NewValues() *Values {
 	}
 }
 
-func NewSimulator(cfg configs.Config, temperature int, simulationTime float64) (*Simulator, error) {
+// Option adjusts how NewSimulator sets up a run.
+type Option func(*settings)
+
+type settings struct {
+	noFiles    bool
+	writers    []output.Writer
+	observers  []Observer
+	checkpoint *Checkpoint
+	source     mathrand.Source
+}
+
+// WithoutFiles keeps the run in memory: no result directory, run.json, tables or plot are written.
+func WithoutFiles() Option {
+	return func(s *settings) {
+		s.noFiles = true
+	}
+}
+
+// WithWriters adds writers receiving the same rows as the result tables.
+func WithWriters(writers ...output.Writer) Option {
+	return func(s *settings) {
+		s.writers = append(s.writers, writers...)
+	}
+}
+
+// WithObserver registers an observer of the simulation loop.
+// In parallel mode the events are executed by the strips and OnEvent is not called.
+func WithObserver(observer Observer) Option {
+	return func(s *settings) {
+		s.observers = append(s.observers, observer)
+	}
+}
+
+// WithCheckpoint starts the run from the state of a checkpoint instead of an empty surface.
+func WithCheckpoint(checkpoint Checkpoint) Option {
+	return func(s *settings) {
+		s.checkpoint = &checkpoint
+	}
+}
+
+// WithRandSource draws the random numbers of the run from src instead of a generator seeded from the
+// config. The seed of the config is ignored and recorded as 0.
+func WithRandSource(src mathrand.Source) Option {
+	return func(s *settings) {
+		s.source = src
+	}
+}
+
+// NewSimulator prepares a run at the temperature for the given physical time.
+// Unless WithoutFiles is given, it creates the result directory and writes run.json.
+func NewSimulator(cfg configs.Config, temperature int, simulationTime float64, opts ...Option) (*Simulator, error) {
+	var settings settings
+	for _, opt := range opts {
+		opt(&settings)
+	}
+
+	if temperature <= 0 || simulationTime <= 0 {
+		return nil, fmt.Errorf("temperature %d and simulation time %g must be positive", temperature, simulationTime)
+	}
+
+	switch cfg.Simulating.BlockedHopPolicy {
+	case "":
+		cfg.Simulating.BlockedHopPolicy = BlockedHopDesorb
+	case BlockedHopDesorb, BlockedHopReject, BlockedHopRetry:
+	default:
+		return nil, fmt.Errorf("unknown blocked hop policy %q", cfg.Simulating.BlockedHopPolicy)
+	}
+
+	if cfg.Gas != nil {
+		if err := cfg.Gas.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	for _, element := range cfg.Elements {
+		if err := element.ValidateRateModels(); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Simulating.EventLog && cfg.Simulating.Parallel.Domains > 1 {
+		return nil, errors.New("the event log is not available in parallel mode")
+	}
+
+	var acceleration *accelerator
+	if cfg.Simulating.Acceleration.Enabled {
+		if cfg.Simulating.Parallel.Domains > 1 {
+			return nil, errors.New("the diffusion acceleration is not available in parallel mode")
+		}
+		var err error
+		if acceleration, err = newAccelerator(cfg.Simulating.Acceleration, len(cfg.Elements)); err != nil {
+			return nil, err
+		}
+	}
+
+	var rand *randomx.Rand
+	if settings.source != nil {
+		cfg.Simulating.Seed = 0
+		rand = randomx.FromSource(settings.source)
+	} else {
+		if cfg.Simulating.Seed == 0 {
+			cfg.Simulating.Seed = randomx.NewSeed()
+		}
+		rand = randomx.New(cfg.Simulating.Seed)
+	}
+
 	matrix := NewMatrix(cfg.Constants)
-	matrix.Init(cfg.Simulating.MatrixLenX, cfg.Simulating.MatrixLenY)
+	if settings.checkpoint == nil {
+		matrix.Init(cfg.Simulating.MatrixLenX, cfg.Simulating.MatrixLenY, rand)
+	}
 
 	atomsController := NewSurfaceAtomsController(cfg.Simulating.MatrixLenX, cfg.Simulating.MatrixLenY, matrix, cfg.Elements)
+	if acceleration != nil {
+		atomsController.TrackContacts()
+	}
 
 	var (
-		meta           = make(map[string]SimulationMeta)
+		meta           = make([]SimulationMeta, 0, len(cfg.Elements))
 		elems          = make([]string, 0, len(cfg.Elements))
 		elementsByName = make(map[string]configs.Element, len(cfg.Elements))
+		rates          = make([]processRate, 0, len(processes)*len(cfg.Elements))
 	)
 
-	for _, element := range cfg.Elements {
-		meta[element.Name] = Fill(element, cfg.Constants, float64(temperature))
+	for i, element := range cfg.Elements {
+		elementMeta, err := Fill(element, cfg.Constants, cfg.Gas, float64(temperature))
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("rate constants", "element", element.Name, "rates", elementMeta.RateConstants())
+
+		meta = append(meta, elementMeta)
 		elems = append(elems, element.Name)
 		elementsByName[element.Name] = element
+		for _, process := range processes {
+			rates = append(rates, processRate{process: process, element: i})
+		}
+		if acceleration != nil {
+			rates = append(rates, processRate{process: encounterProcess, element: i})
+		}
+	}
+
+	formedAtomNames := make([][]string, len(cfg.Elements))
+	for i, first := range cfg.Elements {
+		formedAtomNames[i] = make([]string, len(cfg.Elements))
+		for j, second := range cfg.Elements {
+			formedAtomNames[i][j] = GetFormedAtomName(first, second)
+		}
+	}
+
+	startedAt := time.Now()
+	startTime := startedAt.Format("2006-01-02 15_04_05")
+
+	var (
+		dirName  string
+		baseName string
+		writers  = settings.writers
+		prepared bool
+	)
+	if !settings.noFiles {
+		dirName = filepath.Join(cfg.Simulating.OutputDir, fmt.Sprintf("result %s T%dK", startTime, temperature))
+		if err := os.Mkdir(dirName, 0755); err != nil {
+			return nil, err
+		}
+		// A run that cannot be prepared leaves no result directory behind
+		defer func() {
+			if !prepared {
+				_ = os.RemoveAll(dirName)
+			}
+		}()
+
+		baseName = filepath.Join(dirName, fmt.Sprintf("result_%s_T%dK", startTime, temperature))
+		fileWriters, err := openWriters(cfg.Simulating, baseName)
+		if err != nil {
+			return nil, err
+		}
+		writers = append(fileWriters, writers...)
 	}
 
-	startTime := time.Now().Format("2006-01-02 15_04_05")
-	dirName := fmt.Sprintf("result %s T%dK", startTime, temperature)
-	err := os.Mkdir(dirName, 0755)
+	progressReporter, err := progress.New(cfg.Simulating.Progress.Output, cfg.Simulating.Progress.Interval, cfg.Simulating.Progress.Bar)
 	if err != nil {
+		for _, w := range writers {
+			_ = w.Close()
+		}
 		return nil, err
 	}
 
-	excelFileName := fmt.Sprintf("result_%s_T%dK.xlsx", startTime, temperature)
+	var extraColumns []string
+	if acceleration != nil {
+		extraColumns = accelerationColumns(cfg.Elements)
+	}
+	spatialColumn := len(extraColumns)
+	if cfg.Simulating.Spatial.Enabled {
+		extraColumns = append(extraColumns, spatialColumns(cfg.Elements)...)
+	}
+	var residence *residenceTimes
+	if cfg.Simulating.ResidenceTimes {
+		residence = newResidenceTimes(len(cfg.Elements))
+		residence.column = len(extraColumns)
+		extraColumns = append(extraColumns, residenceColumns(cfg.Elements)...)
+	}
+	var diffusion *tracerDiffusion
+	if cfg.Simulating.TracerDiffusion {
+		diffusion = newTracerDiffusion(cfg.Constants, len(extraColumns))
+		extraColumns = append(extraColumns, diffusionColumns(cfg.Elements)...)
+	}
+	var budget *processBudget
+	if cfg.Simulating.ProcessBudget {
+		budget = newProcessBudget(len(rates))
+		budget.column = len(extraColumns)
+		extraColumns = append(extraColumns, budgetColumns(rates, elems)...)
+	}
 	infoCollector, err := NewInfoCollector(
-		filepath.Join(dirName, excelFileName),
+		writers,
 		cfg.Simulating.FloatPrecision,
 		cfg.Elements,
 		GetFormedAtomNames(cfg.Elements),
+		extraColumns,
 	)
 	if err != nil {
+		_ = progressReporter.Close()
 		return nil, err
 	}
 
-	graphicsFileName := fmt.Sprintf("result_%s_T%dK.html", startTime, temperature)
-	graphicPlotter := graphic_plotter.New(
-		filepath.Join(dirName, excelFileName),
-		filepath.Join(dirName, graphicsFileName),
-		fmt.Sprintf("T%dK", temperature),
-		cfg.Simulating.GraphicsToPlot)
+	var spatialStatistics *spatialStatistics
+	if cfg.Simulating.Spatial.Enabled {
+		spatialStatistics, err = newSpatialStatistics(cfg.Simulating.Spatial, cfg.Simulating.MatrixLenX, cfg.Simulating.MatrixLenY,
+			elems, writers, spatialColumn)
+		if err != nil {
+			_ = infoCollector.Close()
+			_ = progressReporter.Close()
+			return nil, err
+		}
+	}
 
-	return &Simulator{
+	if residence != nil {
+		if err = residence.openTables(writers, elems); err != nil {
+			_ = infoCollector.Close()
+			_ = progressReporter.Close()
+			return nil, err
+		}
+	}
+
+	if diffusion != nil {
+		if err = diffusion.openTables(writers, elems); err != nil {
+			_ = infoCollector.Close()
+			_ = progressReporter.Close()
+			return nil, err
+		}
+	}
+
+	var graphicPlotter *graphic_plotter.GraphicPlotter
+	if !settings.noFiles {
+		graphicPlotter = graphic_plotter.New(
+			writers[0].Path(),
+			baseName+".html",
+			fmt.Sprintf("T%dK", temperature),
+			cfg.Simulating.GraphicsToPlot)
+	}
+
+	simulator := &Simulator{
 		cfg:                   cfg,
 		matrix:                matrix,
 		atomsController:       atomsController,
@@ -98,12 +362,108 @@ func NewSimulator(cfg configs.Config, temperature int, simulationTime float64) (
 		simulationTime:        simulationTime,
 		infoCollector:         infoCollector,
 		graphicPlotter:        graphicPlotter,
+		progress:              progressReporter,
+		rand:                  rand,
 		meta:                  meta,
 		elems:                 elems,
 		elementsByName:        elementsByName,
+		formedAtomNames:       formedAtomNames,
+		rates:                 rates,
+		acceleration:          acceleration,
+		spatial:               spatialStatistics,
+		residence:             residence,
+		diffusion:             diffusion,
+		budget:                budget,
 		elementValues:         make(map[string]map[string]*Values),
 		stableIterationsCount: 0,
-	}, nil
+		dirName:               dirName,
+		observers:             settings.observers,
+		startedAt:             startedAt,
+		stopReason:            StopRunning,
+	}
+
+	if settings.checkpoint != nil {
+		if err = simulator.restore(*settings.checkpoint); err != nil {
+			_ = infoCollector.Close()
+			_ = progressReporter.Close()
+			return nil, err
+		}
+	}
+	if budget != nil {
+		budget.start = simulator.currentSimulationTime
+	}
+
+	if cfg.Simulating.Parallel.Domains > 1 {
+		if err = simulator.splitStrips(); err != nil {
+			_ = infoCollector.Close()
+			_ = progressReporter.Close()
+			return nil, err
+		}
+	}
+
+	if cfg.Simulating.EventLog && dirName != "" {
+		if simulator.eventLog, err = openEventLog(dirName, simulator.checkpoint()); err != nil {
+			_ = infoCollector.Close()
+			_ = progressReporter.Close()
+			return nil, err
+		}
+	}
+
+	if dirName != "" {
+		simulator.snapshots, err = newSnapshotter(cfg.Simulating.Snapshots, dirName, baseName, elems,
+			simulationTime, simulator.currentSimulationTime)
+		if err != nil {
+			_ = infoCollector.Close()
+			_ = progressReporter.Close()
+			if simulator.eventLog != nil {
+				_ = simulator.eventLog.close()
+			}
+			return nil, err
+		}
+	}
+
+	simulator.memory = simulator.measureMemory()
+	slog.Info("memory",
+		"sites", simulator.memory.Sites,
+		"lattice_mib", float64(simulator.memory.LatticeBytes)/(1<<20),
+		"heap_mib", float64(simulator.memory.HeapBytes)/(1<<20))
+
+	if err = simulator.writeManifest(simulator.manifest()); err != nil {
+		_ = infoCollector.Close()
+		_ = progressReporter.Close()
+		if simulator.eventLog != nil {
+			_ = simulator.eventLog.close()
+		}
+		return nil, err
+	}
+
+	prepared = true
+	return simulator, nil
+}
+
+// openWriters creates a writer for every configured output format, xlsx when none is configured.
+func openWriters(cfg configs.Simulating, baseName string) ([]output.Writer, error) {
+	outputs := cfg.Outputs
+	if len(outputs) == 0 {
+		outputs = []string{output.FormatXlsx}
+	}
+
+	writers := make([]output.Writer, 0, len(outputs))
+	for _, format := range outputs {
+		writer, err := output.New(format, baseName, output.Options{
+			XlsxRowLimit: cfg.XlsxRowLimit,
+			XlsxRollover: cfg.XlsxRollover,
+		})
+		if err != nil {
+			for _, w := range writers {
+				_ = w.Close()
+			}
+			return nil, err
+		}
+		writers = append(writers, writer)
+	}
+
+	return writers, nil
 }
 
 func GetCombinedAtomName(elements []configs.Element) string {
@@ -161,18 +521,38 @@ func GetFormedAtomNames(elements []configs.Element) []string {
 	return names
 }
 
+// ResultDir returns the directory the results of the run are written to, empty when the run writes no files.
+func (s *Simulator) ResultDir() string {
+	return s.dirName
+}
+
 // Simulate - function that simulates the processes of adsorption, diffusion, recombination, and desorption of atoms on a surface.
 // It uses the Monte Carlo algorithm to determine which process will occur in the next step.
 // Then, it selects a randomx atom to participate in this process.
 // If the process is adsorption, the atom will be placed in a cell if it is free.
 // If the process is desorption, the atom will be removed from the cell if it was present.
 // If the process is diffusion, the atom will move to a randomx cell if it is free.
-// Every 10% of the simulation, progress information will be displayed.
+// Every 10% of the simulation, progress information will be displayed,
+// and the progress reporter is updated at its own wall-clock cadence.
 // Additionally, every 10% of the simulation, data will be recorded in an Excel file.
-func (s *Simulator) Simulate() (err error) {
+// In parallel mode, the strips of the lattice are simulated a cycle at a time instead.
+// When ctx is cancelled, the run stops after the current event or cycle: a final snapshot is written, the results
+// are closed and plotted, a checkpoint is written if configured, and the run is recorded as interrupted.
+// Simulate returns nil in that case; the stop reason is in Result.
+func (s *Simulator) Simulate(ctx context.Context) (err error) {
+	stopReason := StopCompleted
 	defer func() {
-		if closeErr := s.infoCollector.Close(); err == nil && closeErr != nil {
-			err = closeErr
+		if err != nil {
+			stopReason = StopError
+		}
+		if finishErr := s.finish(stopReason, err); err == nil && finishErr != nil {
+			err = finishErr
+		}
+		if s.observers != nil {
+			result := s.Result()
+			for _, observer := range s.observers {
+				observer.OnEnd(result)
+			}
 		}
 	}()
 
@@ -181,39 +561,81 @@ func (s *Simulator) Simulate() (err error) {
 	progressInterval := s.simulationTime * 0.1
 	excelWriteInterval := s.simulationTime * s.cfg.Simulating.LogPercent / 100
 
-	nextProgressTime := progressInterval
-	nextExcelWriteTime := excelWriteInterval
+	// A run resumed from a checkpoint continues the intervals after its physical time
+	progressCount := int(s.currentSimulationTime/progressInterval) + 1
+	nextProgressTime := progressInterval * float64(progressCount)
+	nextExcelWriteTime := excelWriteInterval * (math.Floor(s.currentSimulationTime/excelWriteInterval) + 1)
 
-	progressCount := 1
 	for s.currentSimulationTime <= s.simulationTime {
 		if s.currentSimulationTime >= nextProgressTime && progressCount <= 10 {
-			currentPercent := progressCount * 10
-			slog.Info(fmt.Sprintf("Simulated %d%%", currentPercent), "physical time", s.currentSimulationTime, "time", time.Since(startTime))
+			if !s.progress.HasBar() {
+				currentPercent := progressCount * 10
+				slog.Info(fmt.Sprintf("Simulated %d%%", currentPercent), "physical time", s.currentSimulationTime, "time", time.Since(startTime))
+			}
 			nextProgressTime += progressInterval
 			progressCount++
 		}
 
-		process, elementName, spendTime := s.getProcess()
-		s.currentSimulationTime += spendTime
-		s.infoCollector.ElapsedTime += spendTime
+		if s.strips != nil || s.events%progressCheckEvents == 0 {
+			if ctx.Err() != nil {
+				stopReason = StopInterrupted
+				break
+			}
+			if s.progress.Due() {
+				if err = s.reportProgress(false); err != nil {
+					return err
+				}
+			}
+		}
 
-		switch process {
-		case adsorptionSProcess:
-			s.adsorbAtom('S', elementName)
-		case adsorptionFProcess:
-			s.adsorbAtom('F', elementName)
-		case recombErProcess:
-			s.recombEr(elementName)
-		case desorptionFProcess:
-			s.desorbAtom('F', elementName)
-		case diffusionProcess:
-			s.moveRandomAtom(elementName, s.meta[elementName])
+		if s.strips != nil {
+			if s.snapshots != nil && s.currentSimulationTime+s.cycleTime > s.snapshots.next {
+				if err = s.takeSnapshots(s.currentSimulationTime + s.cycleTime); err != nil {
+					return err
+				}
+			}
+			s.simulateCycle()
+		} else {
+			process, element, spendTime := s.getProcess()
+			if element < 0 {
+				// No process can happen any more: the surface stays as it is until the end of the run
+				slog.Info("No process can happen, the surface is frozen", "physical_time", s.currentSimulationTime)
+				if err = s.freeze(); err != nil {
+					return err
+				}
+				break
+			}
+			if s.snapshots != nil && s.currentSimulationTime+spendTime > s.snapshots.next {
+				if err = s.takeSnapshots(s.currentSimulationTime + spendTime); err != nil {
+					return err
+				}
+			}
+			s.currentSimulationTime += spendTime
+			s.infoCollector.ElapsedTime += spendTime
+			s.events++
+
+			s.setEvent(OutcomeNone, Coordinates{}, Coordinates{}, "")
+			s.executeChosen(process, element, spendTime)
+
+			if s.acceleration != nil {
+				s.acceleration.record(process, element, s.event.Outcome, s.elems)
+			}
+			if s.eventLog != nil {
+				s.eventLog.write(s.currentSimulationTime, process, element, &s.event)
+			}
+
+			if s.observers != nil {
+				s.notifyEvent(process, element)
+			}
 		}
 
 		if s.currentSimulationTime >= nextExcelWriteTime {
 			if err = s.writeInfoSnapshot(); err != nil {
 				return err
 			}
+			if s.observers != nil {
+				s.notifySnapshot()
+			}
 
 			if s.checkQuasiSteadyState() {
 				slog.Info("Quasi-steady state reached",
@@ -221,6 +643,15 @@ func (s *Simulator) Simulate() (err error) {
 					"elapsed_time", time.Since(startTime),
 					"stable_iterations", s.stableIterationsCount,
 					"checked_parameters", s.cfg.Simulating.CheckParameters)
+				stopReason = StopQuasiSteadyState
+				s.steadyStateAt = s.currentSimulationTime
+				for _, observer := range s.observers {
+					observer.OnSteadyState(SteadyState{
+						Reached:      true,
+						PhysicalTime: s.steadyStateAt,
+						StableChecks: s.stableIterationsCount,
+					})
+				}
 				break
 			}
 
@@ -228,10 +659,35 @@ func (s *Simulator) Simulate() (err error) {
 		}
 	}
 
-	if err = s.infoCollector.Close(); err != nil {
+	if stopReason == StopInterrupted {
+		if err = s.interrupt(); err != nil {
+			return err
+		}
+	}
+
+	if s.snapshots != nil {
+		if err = s.closeSnapshots(stopReason); err != nil {
+			return err
+		}
+	}
+	if s.residence != nil {
+		s.collectStrips()
+		if err = s.writeResidenceTables(); err != nil {
+			return err
+		}
+	}
+
+	if err = s.reportProgress(true); err != nil {
 		return err
 	}
 
+	if err = s.finish(stopReason, nil); err != nil {
+		return err
+	}
+
+	if s.graphicPlotter == nil {
+		return nil
+	}
 	if err = s.graphicPlotter.Plot(); err != nil {
 		slog.Error("plot error", "err", err)
 		return err
@@ -240,7 +696,184 @@ func (s *Simulator) Simulate() (err error) {
 	return nil
 }
 
+// execute carries out the process for the element.
+func (s *Simulator) execute(process string, element int) {
+	switch process {
+	case adsorptionSProcess:
+		s.adsorbAtom('S', element)
+	case adsorptionFProcess:
+		s.adsorbAtom('F', element)
+	case recombErProcess:
+		s.recombEr(element)
+	case desorptionFProcess:
+		s.desorbAtom('F', element, exitDesorption)
+	case diffusionProcess:
+		if s.acceleration != nil {
+			s.hopToFreeCell(element)
+		} else {
+			s.moveRandomAtom(element)
+		}
+	case encounterProcess:
+		s.hopToOccupiedCell(element)
+	}
+}
+
+// freeze ends a run in which no process can happen: the time jumps to the end of the run with the surface
+// unchanged, and its maps and last row of the results are written.
+func (s *Simulator) freeze() error {
+	if s.snapshots != nil {
+		if err := s.takeSnapshots(math.Nextafter(s.simulationTime, math.Inf(1))); err != nil {
+			return err
+		}
+	}
+	s.infoCollector.ElapsedTime += s.simulationTime - s.currentSimulationTime
+	s.currentSimulationTime = s.simulationTime
+
+	if err := s.writeInfoSnapshot(); err != nil {
+		return err
+	}
+	if s.observers != nil {
+		s.notifySnapshot()
+	}
+	return nil
+}
+
+// setEvent records what the current event did, for the observers.
+func (s *Simulator) setEvent(outcome string, from, to Coordinates, partner string) {
+	s.event.Outcome = outcome
+	s.event.From = from
+	s.event.To = to
+	s.event.Partner = partner
+}
+
+func (s *Simulator) notifyEvent(process string, element int) {
+	s.event.Number = s.events
+	s.event.Process = process
+	s.event.Element = ""
+	if element >= 0 {
+		s.event.Element = s.elems[element]
+	}
+	s.event.PhysicalTime = s.currentSimulationTime
+	if process == nothingProcess {
+		s.setEvent(OutcomeNone, Coordinates{}, Coordinates{}, "")
+	}
+
+	for _, observer := range s.observers {
+		observer.OnEvent(s.event)
+	}
+}
+
+func (s *Simulator) notifySnapshot() {
+	total := s.infoCollector.TotalInfo
+	total.FormedAtoms = maps.Clone(total.FormedAtoms)
+	snapshot := Snapshot{
+		PhysicalTime: s.currentSimulationTime,
+		Total:        total,
+		Elements:     maps.Clone(s.infoCollector.Info),
+	}
+
+	for _, observer := range s.observers {
+		observer.OnSnapshot(snapshot)
+	}
+}
+
+// interrupt saves what an interrupted run has reached: a final snapshot and, if configured, a checkpoint.
+func (s *Simulator) interrupt() error {
+	slog.Warn("simulation interrupted, writing partial results",
+		"physical_time", s.currentSimulationTime,
+		"events", s.events)
+
+	if err := s.writeInfoSnapshot(); err != nil {
+		return err
+	}
+	if s.observers != nil {
+		s.notifySnapshot()
+	}
+
+	if s.cfg.Simulating.CheckpointOnInterrupt {
+		if err := s.writeCheckpoint(); err != nil {
+			return fmt.Errorf("checkpoint: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Number of events between checks whether the run is cancelled or a progress report is due,
+// so that neither is paid for on every event.
+const progressCheckEvents = 1024
+
+func (s *Simulator) reportProgress(done bool) error {
+	report := progress.NewReport(s.startedAt, s.currentSimulationTime, s.simulationTime, s.events)
+	report.Coverage = float64(s.atomCount()) / (float64(s.atomsController.MatrixLimitX) * float64(s.atomsController.MatrixLimitY))
+	report.StableChecks = s.stableIterationsCount
+	report.Done = done
+
+	return s.progress.Report(report)
+}
+
+// finish records how the run ended in run.json and in the metadata of the results, then closes the results.
+// Only the first call has an effect.
+func (s *Simulator) finish(stopReason string, runErr error) error {
+	if !s.finishedAt.IsZero() {
+		return nil
+	}
+	s.finishedAt = time.Now()
+	s.stopReason = stopReason
+	s.runErr = runErr
+	// The atoms are counted once the run has taken the most memory for them
+	s.memory.AtomsBytes = s.atomsBytes()
+
+	manifest := s.manifest()
+	keys, values, err := manifest.Metadata()
+	if err != nil {
+		_ = s.infoCollector.Close()
+		return err
+	}
+	s.infoCollector.SetMetadata(keys, values)
+
+	if s.eventLog != nil {
+		if err = s.eventLog.close(); err != nil {
+			_ = s.progress.Close()
+			_ = s.infoCollector.Close()
+			_ = s.writeManifest(manifest)
+			return fmt.Errorf("event log: %w", err)
+		}
+	}
+	if err = s.progress.Close(); err != nil {
+		_ = s.infoCollector.Close()
+		_ = s.writeManifest(manifest)
+		return err
+	}
+	if err = s.infoCollector.Close(); err != nil {
+		_ = s.writeManifest(manifest)
+		return err
+	}
+
+	return s.writeManifest(manifest)
+}
+
 func (s *Simulator) writeInfoSnapshot() error {
+	s.updateInfo()
+	if s.spatial != nil {
+		if err := s.writeSpatialStatistics(); err != nil {
+			return err
+		}
+	}
+	if s.diffusion != nil {
+		if err := s.writeTracerDiffusion(); err != nil {
+			return err
+		}
+	}
+	if s.budget != nil {
+		s.setBudgetColumns()
+	}
+	return s.infoCollector.WriteInfo()
+}
+
+// updateInfo brings the coverage values of the info collector up to date and sums the element info into the total.
+func (s *Simulator) updateInfo() {
+	s.collectStrips()
 	s.infoCollector.ElapsedTime = s.currentSimulationTime
 	total := InfoWithCombinedAtoms{
 		FormedAtoms: make(map[string]int, len(s.infoCollector.TotalInfo.FormedAtoms)),
@@ -249,12 +882,16 @@ func (s *Simulator) writeInfoSnapshot() error {
 		total.FormedAtoms[formedAtomName] = count
 	}
 
-	for elementName := range s.meta {
+	totalOnF, totalOnS := 0, 0
+	for element, elementName := range s.elems {
 		info := s.infoCollector.Info[elementName]
-		info.AtomsOnSurface = s.atomsController.AtomsOnFCenters[elementName].Len() + s.atomsController.AtomsOnSCenters[elementName].Len()
+		onF, onS := s.atomsOn(element)
+		info.AtomsOnSurface = onF + onS
 		info.Density = float64(info.AtomsOnSurface) / (float64(s.atomsController.MatrixLimitX) * float64(s.atomsController.MatrixLimitY))
-		info.DensityF = float64(s.atomsController.AtomsOnFCenters[elementName].Len()) / (float64(s.matrix.NumOfFSites))
-		info.DensityS = float64(s.atomsController.AtomsOnSCenters[elementName].Len()) / (float64(s.matrix.NumOfSSites))
+		info.DensityF = float64(onF) / (float64(s.matrix.NumOfFSites))
+		info.DensityS = float64(onS) / (float64(s.matrix.NumOfSSites))
+		totalOnF += onF
+		totalOnS += onS
 
 		s.infoCollector.Info[elementName] = info
 
@@ -264,104 +901,133 @@ func (s *Simulator) writeInfoSnapshot() error {
 		total.RecombEr += info.RecombEr
 		total.RecombLhF += info.RecombLhF
 		total.RecombLhS += info.RecombLhS
+		total.BlockedHopsRejected += info.BlockedHopsRejected
+		total.BlockedHopsRetried += info.BlockedHopsRetried
+		total.BlockedHopsDesorbed += info.BlockedHopsDesorbed
 	}
 
-	total.Density = float64(len(s.atomsController.AtomsOnSurface)) / (float64(s.atomsController.MatrixLimitX) * float64(s.atomsController.MatrixLimitY))
-	total.DensityF = float64(s.atomsController.AtomsOnFCenters.Len()) / (float64(s.matrix.NumOfFSites))
-	total.DensityS = float64(s.atomsController.AtomsOnSCenters.Len()) / (float64(s.matrix.NumOfSSites))
+	total.Density = float64(total.AtomsOnSurface) / (float64(s.atomsController.MatrixLimitX) * float64(s.atomsController.MatrixLimitY))
+	total.DensityF = float64(totalOnF) / (float64(s.matrix.NumOfFSites))
+	total.DensityS = float64(totalOnS) / (float64(s.matrix.NumOfSSites))
 	s.infoCollector.TotalInfo = total
 
-	return s.infoCollector.WriteInfo()
+	if s.acceleration != nil {
+		for element, scale := range s.acceleration.scales {
+			s.infoCollector.Extra[element] = 1 / scale
+		}
+	}
+	if s.residence != nil {
+		s.setResidenceColumns()
+	}
 }
 
+// Policies for a hop onto an occupied cell when recombination does not happen.
+const (
+	// BlockedHopDesorb removes the hopping atom from the surface and counts it as desorbed.
+	BlockedHopDesorb = "desorb"
+	// BlockedHopReject keeps the atom on its cell, as in standard kMC.
+	BlockedHopReject = "reject"
+	// BlockedHopRetry moves the atom to the first free cell of the remaining directions and rejects the hop
+	// once all of them are blocked. The atoms on the further cells do not recombine with it, so retrying
+	// does not make a recombination more likely than rejecting the hop.
+	BlockedHopRetry = "retry"
+)
+
 const (
 	adsorptionFProcess = "adsorptionF"
 	adsorptionSProcess = "adsorptionS"
 	recombErProcess    = "recombEr"
 	desorptionFProcess = "desorptionF"
 	diffusionProcess   = "diffusion"
+	// encounterProcess is the hops onto occupied cells, kept apart from diffusion when it is accelerated
+	encounterProcess = "encounter"
+	// nothingProcess is returned when no process can happen
+	nothingProcess = "nothing"
 )
 
-func (s *Simulator) getProcess() (process string, elementName string, processTime float64) {
-	// Calculate total lambda for all elements and processes
-	totalLambda := 0.0
-	type processInfo struct {
-		probability float64
-		elementName string
-		process     string
-	}
-	processes := make([]processInfo, 0)
-
-	for name, meta := range s.meta {
-		lambdaAdsorptionF := s.calcLambdaAdsorptionF(meta)
-		lambdaAdsorptionS := s.calcLambdaAdsorptionS(meta)
-		lambdaRecombEr := s.calcLambdaRecombEr(name, meta)
-		lambdaDesorptionF := s.calcLambdaDesorptionF(name, meta)
-		lambdaDiffusions := s.calcLambdaDiffusion(name, meta)
-
-		// Add all lambdas to total
-		totalLambda += lambdaAdsorptionF +
-			lambdaAdsorptionS +
-			lambdaRecombEr +
-			lambdaDesorptionF +
-			lambdaDiffusions
-
-		// Store all processes with their lambdas
-		processes = append(processes,
-			processInfo{lambdaAdsorptionF, name, adsorptionFProcess},
-			processInfo{lambdaAdsorptionS, name, adsorptionSProcess},
-			processInfo{lambdaRecombEr, name, recombErProcess},
-			processInfo{lambdaDesorptionF, name, desorptionFProcess},
-			processInfo{lambdaDiffusions, name, diffusionProcess},
-		)
-	}
-
-	// Calculate probabilities relative to total lambda
-	for i := range processes {
-		processes[i].probability = processes[i].probability / totalLambda
-	}
-
-	// Sort processes by probability
-	sort.Slice(processes, func(i, j int) bool {
-		return processes[i].probability < processes[j].probability
-	})
+// processes of every element, in the order their rates are stored
+var processes = [...]string{adsorptionFProcess, adsorptionSProcess, recombErProcess, desorptionFProcess, diffusionProcess}
+
+// processRate is the rate of a process of an element at the current state of the surface.
+type processRate struct {
+	process string
+	element int
+	lambda  float64
+}
 
-	randomNumber := randomx.Float64()
-	spentTime := CalcTime(totalLambda)
+// getProcess chooses the next process with a probability proportional to its rate and draws the time it takes.
+// It returns nothingProcess and element -1 when no process can happen.
+func (s *Simulator) getProcess() (process string, element int, processTime float64) {
+	totalLambda := s.updateRates()
+	if totalLambda <= 0 {
+		return nothingProcess, -1, 0
+	}
+
+	threshold := s.rand.Float64() * totalLambda
+	spentTime := s.calcTime(totalLambda)
 
-	cumulativeProbability := 0.0
-	for _, proc := range processes {
-		cumulativeProbability += proc.probability
-		if randomNumber <= cumulativeProbability {
-			return proc.process, proc.elementName, spentTime
+	cumulativeLambda := 0.0
+	for i, rate := range s.rates {
+		cumulativeLambda += rate.lambda
+		if threshold < cumulativeLambda {
+			s.chosen = i
+			return rate.process, rate.element, spentTime
 		}
 	}
 
-	return "nothing", "", 0
+	// Rounding left the threshold above the sum: take the last possible process
+	for i := len(s.rates) - 1; i >= 0; i-- {
+		if s.rates[i].lambda > 0 {
+			s.chosen = i
+			return s.rates[i].process, s.rates[i].element, spentTime
+		}
+	}
+	return nothingProcess, -1, 0
 }
 
-func (s *Simulator) adsorbAtom(center rune, elementName string) {
-	var freeCells *randomx.Map[uint32, CellData]
-
-	switch center {
-	case 'S':
-		freeCells = s.matrix.FreeCellsOfSCenters
-	case 'F':
-		freeCells = s.matrix.FreeCellsOfFCenters
+// updateRates brings the rates of the processes up to date with the surface and returns their sum.
+func (s *Simulator) updateRates() float64 {
+	totalLambda := 0.0
+	for i := range s.rates {
+		rate := &s.rates[i]
+		meta := &s.meta[rate.element]
+		switch rate.process {
+		case adsorptionFProcess:
+			rate.lambda = s.calcLambdaAdsorptionF(meta)
+		case adsorptionSProcess:
+			rate.lambda = s.calcLambdaAdsorptionS(meta)
+		case recombErProcess:
+			rate.lambda = s.calcLambdaRecombEr(rate.element, meta)
+		case desorptionFProcess:
+			rate.lambda = s.calcLambdaDesorptionF(rate.element, meta)
+		case diffusionProcess:
+			rate.lambda = s.calcLambdaDiffusion(rate.element, meta)
+		case encounterProcess:
+			rate.lambda = s.calcLambdaEncounter(rate.element, meta)
+		}
+		totalLambda += rate.lambda
 	}
+	return totalLambda
+}
 
-	cellId, cellData, exist := freeCells.Random()
+func (s *Simulator) adsorbAtom(center rune, element int) {
+	cellData, exist := s.matrix.RandomFreeCell(center, s.rand)
 	if !exist {
-		slog.Error("no free cells", "cell_id", cellId)
+		slog.Error("no free cells", "center", string(center))
+		return
 	}
+	cell := Coordinates{X: cellData.X, Y: cellData.Y}
+	s.setEvent(OutcomeAdsorbed, cell, cell, "")
 
 	atom := Atom{
 		X:              cellData.X,
 		Y:              cellData.Y,
 		OccupiedCentre: cellData.Center,
-		ElementName:    elementName,
+		Element:        element,
+		Track:          Track{AdsorbedAt: s.currentSimulationTime, AdsorbedOn: cellData.Center},
 	}
 
+	elementName := s.elems[element]
 	info := s.infoCollector.Info[elementName]
 	info.AdsorbedAtoms += 1
 	s.infoCollector.Info[elementName] = info
@@ -369,135 +1035,191 @@ func (s *Simulator) adsorbAtom(center rune, elementName string) {
 	s.atomsController.AddAtomOnSurface(atom)
 }
 
-func (s *Simulator) desorbAtom(center rune, elementName string) {
-	var atoms *randomx.Map[int, Atom]
-
-	switch center {
-	case 'S':
-		atoms = s.atomsController.AtomsOnSCenters[elementName]
-	case 'F':
-		atoms = s.atomsController.AtomsOnFCenters[elementName]
-	}
-
-	cellId, atom, exist := atoms.Random()
+// desorbAtom removes a random atom of the element from the center, leaving through the exit channel.
+// It returns false if there is none.
+func (s *Simulator) desorbAtom(center rune, element int, channel int) bool {
+	atom, exist := s.atomsController.RandomAtom(center, element, s.rand)
 	if !exist {
-		slog.Error("no occupied cells", "cell_id", cellId)
+		slog.Error("no occupied cells", "center", string(center), "element", s.elems[element])
+		return false
 	}
+	cell := Coordinates{X: atom.X, Y: atom.Y}
+	s.setEvent(OutcomeDesorbed, cell, cell, "")
 
+	elementName := s.elems[element]
 	info := s.infoCollector.Info[elementName]
 	info.DesorbedAtoms += 1
 	s.infoCollector.Info[elementName] = info
 
+	s.recordExit(atom, channel)
 	s.atomsController.RemoveAtomFromSurface(atom.Id)
+	return true
 }
 
-func (s *Simulator) recombEr(elementName string) {
+func (s *Simulator) recombEr(element int) {
+	elementName := s.elems[element]
 	info := s.infoCollector.Info[elementName]
 	info.RecombEr += 1
 	info.DesorbedAtoms += 1
 	s.infoCollector.Info[elementName] = info
 
-	randomElement := s.elems[randomx.Int(len(s.elems))]
-	randomElementInfo := s.infoCollector.Info[randomElement]
+	randomElement := s.rand.Int(len(s.elems))
+	randomElementName := s.elems[randomElement]
+	randomElementInfo := s.infoCollector.Info[randomElementName]
 	randomElementInfo.RecombEr += 1
-	s.infoCollector.Info[randomElement] = randomElementInfo
+	s.infoCollector.Info[randomElementName] = randomElementInfo
 
-	s.recordFormedAtom(elementName, randomElement)
-	s.desorbAtom('S', randomElement)
+	s.recordFormedAtom(element, randomElement)
+	if !s.desorbAtom('S', randomElement, exitRecombEr) {
+		return
+	}
+	s.event.Outcome = OutcomeRecombEr
+	s.event.Partner = randomElementName
 }
 
-func (s *Simulator) moveRandomAtom(elementName string, meta SimulationMeta) {
-	_, atom, exist := s.atomsController.AtomsOnFCenters[elementName].Random()
+func (s *Simulator) moveRandomAtom(element int) {
+	atom, exist := s.atomsController.RandomAtom('F', element, s.rand)
 	if !exist {
 		slog.Info("no atoms to move",
-			"element_name", elementName,
-			"atoms_on_f_centers", s.atomsController.AtomsOnFCenters[elementName].Len(),
-			"atoms_on_s_centers", s.atomsController.AtomsOnSCenters[elementName].Len(),
-			"surface_atoms", len(s.atomsController.AtomsOnSurface))
+			"element_name", s.elems[element],
+			"atoms_on_f_centers", s.atomsController.AtomsOnFCenters[element].Len(),
+			"atoms_on_s_centers", s.atomsController.AtomsOnSCenters[element].Len(),
+			"surface_atoms", s.atomsController.Count())
+		s.setEvent(OutcomeNone, Coordinates{}, Coordinates{}, "")
 		return
 	}
 
-	nextX, nextY := s.atomsController.GetNextAtomCoordinates(atom.Id)
+	neighbours, count := s.atomsController.GetNeighbourCoordinates(atom, s.rand)
+	s.moveAtom(atom, neighbours[:count])
+}
 
-	nextCellInfo := s.matrix.GetCellInfo(nextX, nextY)
+// moveAtom hops the atom to the first of the neighbouring cells, applying the blocked hop policy if it is occupied.
+// The recombination is drawn once per hop, with the atom on the first cell.
+func (s *Simulator) moveAtom(atom Atom, neighbours []Coordinates) {
+	elementName := s.elems[atom.Element]
+	from := Coordinates{X: atom.X, Y: atom.Y}
+	for i, next := range neighbours {
+		s.setEvent(OutcomeBlockedRejected, from, next, "")
+		if s.hopAtom(atom, next, i == 0) {
+			return
+		}
 
-	info := s.infoCollector.Info[elementName]
-	switch {
-	case nextCellInfo.IsFree:
-		s.atomsController.MoveAtom(atom, nextCellInfo)
-	case nextCellInfo.Center == 'S' && recombProbOnS(elementName, s.atomsController.AtomsOnSurface[nextCellInfo.AtomId].ElementName, meta) >= randomx.Float64():
-		info.DesorbedAtoms += 1
-		info.RecombLhS += 1
+		info := s.infoCollector.Info[elementName]
+		switch s.cfg.Simulating.BlockedHopPolicy {
+		case BlockedHopRetry:
+			if i < len(neighbours)-1 {
+				info.BlockedHopsRetried += 1
+				s.infoCollector.Info[elementName] = info
+				continue
+			}
+			info.BlockedHopsRejected += 1
+		case BlockedHopReject:
+			info.BlockedHopsRejected += 1
+		default:
+			s.recordExit(atom, exitBlockedHop)
+			s.atomsController.RemoveAtomFromSurface(atom.Id)
+			info.DesorbedAtoms += 1
+			info.BlockedHopsDesorbed += 1
+			s.event.Outcome = OutcomeBlockedDesorbed
+		}
 		s.infoCollector.Info[elementName] = info
+		return
+	}
+}
 
-		nextAtom := s.atomsController.AtomsOnSurface[nextCellInfo.AtomId]
-		nextElementInfo := s.infoCollector.Info[nextAtom.ElementName]
-		nextElementInfo.DesorbedAtoms += 1
-		nextElementInfo.RecombLhS += 1
-		s.infoCollector.Info[nextAtom.ElementName] = nextElementInfo
+// hopAtom moves the atom to the next cell if it is free, or, if recombine is set, draws whether it recombines
+// with the atom occupying it. It returns false if the hop was blocked, i.e. the cell is occupied and the atoms
+// did not recombine.
+func (s *Simulator) hopAtom(atom Atom, next Coordinates, recombine bool) bool {
+	if s.strip != nil && !s.matrix.Contains(next) {
+		return s.hopAcross(atom, next, recombine)
+	}
+
+	nextCellInfo := s.matrix.GetCellInfo(next.X, next.Y)
+	if nextCellInfo.IsFree {
+		s.atomsController.MoveAtom(atom, nextCellInfo)
+		s.event.Outcome = OutcomeHopped
+		return true
+	}
 
-		s.recordFormedAtom(elementName, nextAtom.ElementName)
+	nextAtom := s.atomsController.Atom(nextCellInfo.AtomId)
+	if !recombine || !s.recombineLh(atom, nextAtom.Element, nextCellInfo.Center) {
+		return false
+	}
 
-		s.atomsController.RemoveAtomFromSurface(atom.Id)
-		s.atomsController.RemoveAtomFromSurface(nextCellInfo.AtomId)
-	case nextCellInfo.Center == 'F' && recombProbOnF(elementName, s.atomsController.AtomsOnSurface[nextCellInfo.AtomId].ElementName, meta) >= randomx.Float64():
-		info.DesorbedAtoms += 1
+	channel := lhExitChannel(nextCellInfo.Center)
+	s.recordExit(atom, channel)
+	s.recordExit(nextAtom, channel)
+	s.atomsController.RemoveAtomFromSurface(atom.Id)
+	s.atomsController.RemoveAtomFromSurface(nextAtom.Id)
+	return true
+}
+
+// recombineLh draws whether the atom recombines with an atom of nextElement on a center of the given type
+// and counts the recombination if it does. Removing both atoms is left to the caller.
+func (s *Simulator) recombineLh(atom Atom, nextElement int, center rune) bool {
+	meta := &s.meta[atom.Element]
+	elementName, nextElementName := s.elems[atom.Element], s.elems[nextElement]
+	info := s.infoCollector.Info[elementName]
+	switch {
+	case center == 'S' && recombProbOnS(atom.Element, nextElement, meta) >= s.rand.Float64():
+		info.RecombLhS += 1
+		s.event.Outcome = OutcomeRecombLhS
+	case center == 'F' && recombProbOnF(atom.Element, nextElement, meta) >= s.rand.Float64():
 		info.RecombLhF += 1
-		s.infoCollector.Info[elementName] = info
+		s.event.Outcome = OutcomeRecombLhF
+	default:
+		return false
+	}
+	s.event.Partner = nextElementName
+	info.DesorbedAtoms += 1
+	s.infoCollector.Info[elementName] = info
 
-		nextAtom := s.atomsController.AtomsOnSurface[nextCellInfo.AtomId]
-		nextElementInfo := s.infoCollector.Info[nextAtom.ElementName]
-		nextElementInfo.DesorbedAtoms += 1
+	nextElementInfo := s.infoCollector.Info[nextElementName]
+	nextElementInfo.DesorbedAtoms += 1
+	if center == 'S' {
+		nextElementInfo.RecombLhS += 1
+	} else {
 		nextElementInfo.RecombLhF += 1
-		s.infoCollector.Info[nextAtom.ElementName] = nextElementInfo
-
-		s.recordFormedAtom(elementName, nextAtom.ElementName)
+	}
+	s.infoCollector.Info[nextElementName] = nextElementInfo
 
-		s.atomsController.RemoveAtomFromSurface(atom.Id)
-		s.atomsController.RemoveAtomFromSurface(nextCellInfo.AtomId)
-	default:
-		s.atomsController.RemoveAtomFromSurface(atom.Id)
+	s.recordFormedAtom(atom.Element, nextElement)
+	return true
+}
 
-		info.DesorbedAtoms += 1
-		s.infoCollector.Info[elementName] = info
+// lhExitChannel returns the exit channel of the atoms recombining on a center of the given type.
+func lhExitChannel(center rune) int {
+	if center == 'S' {
+		return exitRecombLhS
 	}
-	return
+	return exitRecombLhF
 }
 
-func IsDifferentAtoms(a string, b string) bool {
+func IsDifferentAtoms(a int, b int) bool {
 	return a != b
 }
 
-func recombProbOnS(elementName, nextElementName string, meta SimulationMeta) float64 {
-	if IsDifferentAtoms(elementName, nextElementName) {
+func recombProbOnS(element, nextElement int, meta *SimulationMeta) float64 {
+	if IsDifferentAtoms(element, nextElement) {
 		return meta.recombinationProbabilityOnSSiteHet
 	}
 	return meta.recombinationProbabilityOnSSite
 }
 
-func recombProbOnF(elementName, nextElementName string, meta SimulationMeta) float64 {
-	if IsDifferentAtoms(elementName, nextElementName) {
+func recombProbOnF(element, nextElement int, meta *SimulationMeta) float64 {
+	if IsDifferentAtoms(element, nextElement) {
 		return meta.recombinationProbabilityOnFSiteHet
 	}
 	return meta.recombinationProbabilityOnFSite
 }
 
-func (s *Simulator) recordFormedAtom(firstElementName, secondElementName string) {
-	firstElement, firstExists := s.elementsByName[firstElementName]
-	secondElement, secondExists := s.elementsByName[secondElementName]
-	if !firstExists || !secondExists {
-		slog.Warn("formed atom contains unknown element",
-			"first_element", firstElementName,
-			"second_element", secondElementName)
-		return
-	}
-
+func (s *Simulator) recordFormedAtom(first, second int) {
 	if s.infoCollector.TotalInfo.FormedAtoms == nil {
 		s.infoCollector.TotalInfo.FormedAtoms = make(map[string]int)
 	}
 
-	formedAtomName := GetFormedAtomName(firstElement, secondElement)
-	s.infoCollector.TotalInfo.FormedAtoms[formedAtomName]++
+	s.infoCollector.TotalInfo.FormedAtoms[s.formedAtomNames[first][second]]++
 }
 
 func (s *Simulator) checkQuasiSteadyState() bool {
@@ -506,7 +1228,7 @@ func (s *Simulator) checkQuasiSteadyState() bool {
 	}
 
 	isStable := true
-	for elementName := range s.meta {
+	for _, elementName := range s.elems {
 		info := s.infoCollector.Info[elementName]
 
 		if s.elementValues[elementName] == nil {
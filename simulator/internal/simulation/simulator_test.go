@@ -0,0 +1,222 @@
+package simulation
+
+import (
+	"context"
+	"fmt"
+	"github.com/zipliZ/surface-atoms/simulator/configs"
+	"github.com/zipliZ/surface-atoms/simulator/internal/progress"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testConfig loads the example config for a small lattice, without files, progress or a quasi-steady stop.
+func testConfig(tb testing.TB, size int) configs.Config {
+	tb.Helper()
+	example, err := os.ReadFile("../../../config.yaml.example")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	path := filepath.Join(tb.TempDir(), "config.yaml")
+	if err = os.WriteFile(path, example, 0o644); err != nil {
+		tb.Fatal(err)
+	}
+
+	cfg, _, err := configs.Load(configs.Sources{Files: []string{path}})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	cfg.Simulating.MatrixLenX = size
+	cfg.Simulating.MatrixLenY = size
+	cfg.Simulating.Seed = 1
+	cfg.Simulating.StopOnQuasiSteady = false
+	cfg.Simulating.OutputDir = tb.TempDir()
+	cfg.Simulating.Progress.Bar = progress.BarOff
+	return cfg
+}
+
+// newTestSimulator prepares an in-memory run of the config at 600 K.
+func newTestSimulator(tb testing.TB, cfg configs.Config, simulationTime float64, opts ...Option) *Simulator {
+	tb.Helper()
+	s, err := NewSimulator(cfg, 600, simulationTime, append([]Option{WithoutFiles()}, opts...)...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return s
+}
+
+// step executes one event like the serial loop of Simulate.
+func (s *Simulator) step() {
+	process, element, spendTime := s.getProcess()
+	s.currentSimulationTime += spendTime
+	s.infoCollector.ElapsedTime += spendTime
+	s.events++
+	s.setEvent(OutcomeNone, Coordinates{}, Coordinates{}, "")
+	s.executeChosen(process, element, spendTime)
+}
+
+func TestSimulateFrozenSurface(t *testing.T) {
+	cfg := testConfig(t, 20)
+	for i := range cfg.Elements {
+		cfg.Elements[i].AgDensity = 0
+	}
+	s := newTestSimulator(t, cfg, 1e-3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.Simulate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	result := s.Result()
+	if result.StopReason != StopCompleted {
+		t.Fatalf("StopReason = %q, want %q", result.StopReason, StopCompleted)
+	}
+	if result.PhysicalTime != 1e-3 || result.Events != 0 {
+		t.Errorf("physical time %g after %d events, want 1e-3 after none", result.PhysicalTime, result.Events)
+	}
+}
+
+// fillLattice puts an atom of the first element on every free cell.
+func fillLattice(s *Simulator) {
+	for y := range s.cfg.Simulating.MatrixLenY {
+		for x := range s.cfg.Simulating.MatrixLenX {
+			cell := s.matrix.GetCellInfo(uint32(x), uint32(y))
+			if cell.IsFree {
+				s.atomsController.AddAtomOnSurface(Atom{X: cell.X, Y: cell.Y, OccupiedCentre: cell.Center})
+			}
+		}
+	}
+}
+
+// setRecombinationProbability sets the probability of every LH recombination of the first element.
+func setRecombinationProbability(s *Simulator, probability float64) {
+	meta := &s.meta[0]
+	meta.recombinationProbabilityOnFSite = probability
+	meta.recombinationProbabilityOnSSite = probability
+	meta.recombinationProbabilityOnFSiteHet = probability
+	meta.recombinationProbabilityOnSSiteHet = probability
+}
+
+func TestBlockedHopPolicies(t *testing.T) {
+	const hops = 50
+	for _, policy := range []string{BlockedHopReject, BlockedHopRetry, BlockedHopDesorb} {
+		t.Run(policy, func(t *testing.T) {
+			cfg := testConfig(t, 4)
+			cfg.Simulating.BlockedHopPolicy = policy
+			s := newTestSimulator(t, cfg, 1)
+			setRecombinationProbability(s, 0)
+			cells := cfg.Simulating.MatrixLenX * cfg.Simulating.MatrixLenY
+
+			want := map[string]string{
+				BlockedHopReject: OutcomeBlockedRejected,
+				BlockedHopRetry:  OutcomeBlockedRejected,
+				BlockedHopDesorb: OutcomeBlockedDesorbed,
+			}[policy]
+			for range hops {
+				fillLattice(s)
+				s.moveRandomAtom(0)
+				if s.event.Outcome != want {
+					t.Fatalf("hop on a full lattice ended %q, want %q", s.event.Outcome, want)
+				}
+			}
+
+			info := s.infoCollector.Info[s.elems[0]]
+			switch policy {
+			case BlockedHopReject:
+				if info.BlockedHopsRejected != hops || info.BlockedHopsRetried != 0 || info.BlockedHopsDesorbed != 0 {
+					t.Errorf("counts %+v, want %d rejected", info, hops)
+				}
+			case BlockedHopRetry:
+				// Every cell has at least two neighbours, each tried before the hop is rejected
+				if info.BlockedHopsRejected != hops || info.BlockedHopsRetried < hops || info.BlockedHopsDesorbed != 0 {
+					t.Errorf("counts %+v, want %d rejected after at least as many retries", info, hops)
+				}
+			case BlockedHopDesorb:
+				if info.BlockedHopsDesorbed != hops || info.DesorbedAtoms != hops || info.BlockedHopsRejected != 0 {
+					t.Errorf("counts %+v, want %d desorbed", info, hops)
+				}
+			}
+			if policy != BlockedHopDesorb && s.atomsController.Count() != cells {
+				t.Errorf("%d atoms left on %d cells", s.atomsController.Count(), cells)
+			}
+		})
+	}
+}
+
+// TestRetryRecombinesOncePerHop checks that retrying the other directions of a blocked hop does not draw
+// further recombinations: on a full lattice a hop recombines as often with retry as with reject.
+func TestRetryRecombinesOncePerHop(t *testing.T) {
+	const hops, probability = 4000, 0.3
+	for _, policy := range []string{BlockedHopReject, BlockedHopRetry} {
+		cfg := testConfig(t, 6)
+		cfg.Simulating.BlockedHopPolicy = policy
+		s := newTestSimulator(t, cfg, 1)
+		setRecombinationProbability(s, probability)
+
+		recombined := 0
+		for range hops {
+			fillLattice(s)
+			s.moveRandomAtom(0)
+			if outcome := s.event.Outcome; outcome == OutcomeRecombLhF || outcome == OutcomeRecombLhS {
+				recombined++
+			}
+		}
+		// Four standard deviations of the binomial count
+		if share := float64(recombined) / hops; math.Abs(share-probability) > 4*math.Sqrt(probability*(1-probability)/hops) {
+			t.Errorf("%s: %.3f of the hops recombined, want %.1f", policy, share, probability)
+		}
+	}
+}
+
+// BenchmarkEvents measures a kMC event on a lattice filled by as many events as it has cells.
+func BenchmarkEvents(b *testing.B) {
+	slog.SetLogLoggerLevel(slog.LevelError)
+	for _, size := range []int{50, 200, 1000} {
+		for _, elements := range []int{1, 2} {
+			b.Run(fmt.Sprintf("lattice=%d/elements=%d", size, elements), func(b *testing.B) {
+				cfg := testConfig(b, size)
+				cfg.Elements = cfg.Elements[:elements]
+				s := newTestSimulator(b, cfg, 1e3)
+				for range size * size {
+					s.step()
+				}
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for b.Loop() {
+					s.step()
+				}
+			})
+		}
+	}
+}
+
+func TestNewSimulatorRemovesDirOnError(t *testing.T) {
+	cases := map[string]func(cfg *configs.Config){
+		"output format": func(cfg *configs.Config) { cfg.Simulating.Outputs = []string{"bogus"} },
+		"snapshot format": func(cfg *configs.Config) {
+			cfg.Simulating.Snapshots = configs.Snapshots{Percent: 10, Formats: []string{"bogus"}}
+		},
+		"parallel strips": func(cfg *configs.Config) { cfg.Simulating.Parallel.Domains = 10 },
+	}
+	for name, broken := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg := testConfig(t, 10)
+			broken(&cfg)
+			if _, err := NewSimulator(cfg, 600, 1e-3); err == nil {
+				t.Fatal("NewSimulator accepted the broken config")
+			}
+			entries, err := os.ReadDir(cfg.Simulating.OutputDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 0 {
+				t.Errorf("output dir holds %d entries after the failed run, want none", len(entries))
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package simulation
+
+import (
+	"cmp"
+	"fmt"
+	"github.com/zipliZ/surface-atoms/simulator/configs"
+	"github.com/zipliZ/surface-atoms/simulator/internal/output"
+	"github.com/zipliZ/surface-atoms/simulator/internal/spatial"
+	"math"
+	"slices"
+)
+
+// Defaults of the spatial statistics.
+const (
+	defaultSpatialMaxDistance = 10
+	defaultSpatialBinWidth    = 1
+)
+
+// Names of the further tables of the spatial statistics.
+const (
+	clustersTable        = "Clusters"
+	pairCorrelationTable = "Pair correlation"
+	sDistancesTable      = "S distances"
+)
+
+// spatialGroupAll names the clusters of all atoms, whatever their element.
+const spatialGroupAll = "All"
+
+// spatialStatistics computes the spatial statistics of the atoms at every row of the results. The summary
+// goes to the extra columns from column on, the distributions to the further tables of the writers able to
+// store them.
+type spatialStatistics struct {
+	analyzer *spatial.Analyzer
+	column   int
+	// Distance bin of the nearest neighbours, 1 cell apart
+	nearestBin int
+
+	clusters        []output.Writer
+	pairCorrelation []output.Writer
+	sDistances      []output.Writer
+}
+
+func newSpatialStatistics(cfg configs.Spatial, lenX, lenY int, elems []string, writers []output.Writer, column int) (*spatialStatistics, error) {
+	maxDistance := cmp.Or(cfg.MaxDistance, defaultSpatialMaxDistance)
+	binWidth := cmp.Or(cfg.BinWidth, defaultSpatialBinWidth)
+	if maxDistance <= 1 || binWidth <= 0 || binWidth > maxDistance {
+		return nil, fmt.Errorf("spatial max distance %g must be above 1 cell and bin width %g positive and at most the max distance",
+			maxDistance, binWidth)
+	}
+
+	st := &spatialStatistics{
+		analyzer:   spatial.NewAnalyzer(lenX, lenY, len(elems), maxDistance, binWidth),
+		column:     column,
+		nearestBin: int(1 / binWidth),
+	}
+
+	groups := append(slices.Clone(elems), spatialGroupAll)
+	clusterHeaders := []string{"Simulation time", "Cluster size"}
+	for _, group := range groups {
+		clusterHeaders = append(clusterHeaders, fmt.Sprintf("%s - Clusters", group))
+	}
+	pairHeaders := []string{"Simulation time", "Distance from"}
+	for _, pair := range spatial.Pairs(len(elems)) {
+		pairHeaders = append(pairHeaders, fmt.Sprintf("%s-%s - Pair correlation", elems[pair[0]], elems[pair[1]]))
+	}
+	distanceHeaders := []string{"Simulation time", "Distance from"}
+	for _, element := range elems {
+		distanceHeaders = append(distanceHeaders, fmt.Sprintf("%s - Atoms", element))
+	}
+
+	var err error
+	if st.clusters, err = openTables(writers, clustersTable, clusterHeaders); err != nil {
+		return nil, err
+	}
+	if st.pairCorrelation, err = openTables(writers, pairCorrelationTable, pairHeaders); err != nil {
+		return nil, err
+	}
+	if st.sDistances, err = openTables(writers, sDistancesTable, distanceHeaders); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// spatialColumns returns the headers of the results table columns with the summary of the spatial statistics:
+// the clusters and the mean distance to the nearest S-center of every element, the clusters of all atoms,
+// and the pair correlation of nearest neighbours of every pair of elements.
+func spatialColumns(elements []configs.Element) []string {
+	var columns []string
+	for _, element := range elements {
+		columns = append(columns,
+			fmt.Sprintf("%s - Clusters", element.Name),
+			fmt.Sprintf("%s - Mean cluster size", element.Name),
+			fmt.Sprintf("%s - Largest cluster", element.Name),
+			fmt.Sprintf("%s - Mean distance to S", element.Name),
+		)
+	}
+	columns = append(columns,
+		fmt.Sprintf("%s - Clusters", spatialGroupAll),
+		fmt.Sprintf("%s - Mean cluster size", spatialGroupAll),
+		fmt.Sprintf("%s - Largest cluster", spatialGroupAll),
+	)
+	for _, pair := range spatial.Pairs(len(elements)) {
+		columns = append(columns, fmt.Sprintf("%s-%s - Nearest neighbour correlation", elements[pair[0]].Name, elements[pair[1]].Name))
+	}
+	return columns
+}
+
+// writeSpatialStatistics computes the spatial statistics of the surface, sets their summary in the extra
+// columns and writes their distributions to the further tables.
+func (s *Simulator) writeSpatialStatistics() error {
+	st := s.spatial
+	statistics := st.analyzer.Analyze(s.occupancyMap(s.currentSimulationTime))
+	round := func(value float64) float64 {
+		return roundToDecimals(value, s.cfg.Simulating.FloatPrecision)
+	}
+
+	extra := s.infoCollector.Extra[st.column:]
+	for group, sizes := range statistics.Clusters {
+		clusters, atoms, largest := 0, 0, 0
+		for size, count := range sizes {
+			clusters += count
+			atoms += size * count
+			largest = max(largest, size)
+		}
+		meanSize := math.NaN()
+		if clusters > 0 {
+			meanSize = float64(atoms) / float64(clusters)
+		}
+		if group < len(s.elems) {
+			extra[0], extra[1], extra[2], extra[3] = float64(clusters), meanSize, float64(largest), statistics.MeanSDistance[group]
+			extra = extra[4:]
+		} else {
+			extra[0], extra[1], extra[2] = float64(clusters), meanSize, float64(largest)
+			extra = extra[3:]
+		}
+	}
+	for pair, correlation := range statistics.PairCorrelation {
+		extra[pair] = correlation[st.nearestBin]
+	}
+
+	time := round(s.currentSimulationTime)
+	var sizes []int
+	for _, groupSizes := range statistics.Clusters {
+		for size := range groupSizes {
+			sizes = append(sizes, size)
+		}
+	}
+	slices.Sort(sizes)
+	for _, size := range slices.Compact(sizes) {
+		row := []float64{time, float64(size)}
+		for _, groupSizes := range statistics.Clusters {
+			row = append(row, float64(groupSizes[size]))
+		}
+		if err := writeTableRow(st.clusters, row); err != nil {
+			return err
+		}
+	}
+
+	for bin := range st.analyzer.Bins() {
+		row := []float64{time, round(st.analyzer.BinStart(bin))}
+		for _, correlation := range statistics.PairCorrelation {
+			row = append(row, round(correlation[bin]))
+		}
+		if err := writeTableRow(st.pairCorrelation, row); err != nil {
+			return err
+		}
+	}
+
+	for bin := range st.analyzer.Bins() + 1 {
+		row := []float64{time, round(st.analyzer.BinStart(bin))}
+		for _, histogram := range statistics.SDistances {
+			row = append(row, float64(histogram[bin]))
+		}
+		if err := writeTableRow(st.sDistances, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openTables creates the further table with the headers in the writers able to store it.
+func openTables(writers []output.Writer, name string, headers []string) ([]output.Writer, error) {
+	var tables []output.Writer
+	for _, writer := range writers {
+		tableWriter, ok := writer.(output.TableWriter)
+		if !ok {
+			continue
+		}
+		table, err := tableWriter.Table(name)
+		if err != nil {
+			return nil, err
+		}
+		if err = table.WriteHeader(headers); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+func writeTableRow(writers []output.Writer, row []float64) error {
+	for _, writer := range writers {
+		if err := writer.WriteRow(row); err != nil {
+			return fmt.Errorf("%s: %w", writer.Path(), err)
+		}
+	}
+	return nil
+}
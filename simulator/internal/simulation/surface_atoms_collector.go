@@ -1,44 +1,57 @@
 package simulation
 
 import (
-	"crypto/rand"
-	"main/configs"
-	"main/internal/generators"
-	"main/internal/random"
-	"math/big"
+	"github.com/zipliZ/surface-atoms/simulator/configs"
+	"github.com/zipliZ/surface-atoms/simulator/internal/random"
+	"unsafe"
 )
 
 type SurfaceAtomsController struct {
-	AtomsOnSurface  map[int]Atom
+	// Atoms on the surface by id. Id 0 is never used, so that a cell can tell it holds no atom;
+	// the ids of removed atoms are reused.
+	atoms   []Atom
+	freeIds []int
+	count   int
+	// Ids of the atoms of every element, indexed like the elements of the config
 	AtomsOnFCenters AtomsOnCenters
 	AtomsOnSCenters AtomsOnCenters
 	MatrixLimitX    int
 	MatrixLimitY    int
 	matrix          *Matrix
-	IdGenerator     *generators.IdGenerator
+
+	// Ids of the atoms of every element on F-centers with at least one occupied neighbouring cell,
+	// and the number of occupied neighbouring cells of every atom by id; nil unless TrackContacts is called
+	AtomsInContact     AtomsOnCenters
+	occupiedNeighbours []uint8
 }
 
 func NewSurfaceAtomsController(matrixLimitX int, matrixLimitY int, matrix *Matrix, elements []configs.Element) *SurfaceAtomsController {
-	atomsOnSurface := make(map[int]Atom)
-	atomsOnFCenters := make(map[string]*random.Map[int, Atom])
-	atomsOnSCenters := make(map[string]*random.Map[int, Atom])
-	for _, element := range elements {
-		atomsOnFCenters[element.Name] = random.NewRandMap[int, Atom]()
-		atomsOnSCenters[element.Name] = random.NewRandMap[int, Atom]()
+	atomsOnFCenters := make(AtomsOnCenters, len(elements))
+	atomsOnSCenters := make(AtomsOnCenters, len(elements))
+	for i := range elements {
+		atomsOnFCenters[i] = random.NewSet(0)
+		atomsOnSCenters[i] = random.NewSet(0)
 	}
 
 	return &SurfaceAtomsController{
-		AtomsOnSurface:  atomsOnSurface,
+		atoms:           make([]Atom, 1),
 		MatrixLimitX:    matrixLimitX,
 		MatrixLimitY:    matrixLimitY,
 		matrix:          matrix,
 		AtomsOnFCenters: atomsOnFCenters,
 		AtomsOnSCenters: atomsOnSCenters,
-		IdGenerator:     generators.NewIdGenerator(),
 	}
 }
 
-type AtomsOnCenters map[string]*random.Map[int, Atom]
+// TrackContacts keeps AtomsInContact up to date from now on. It must be called before atoms are added.
+func (s *SurfaceAtomsController) TrackContacts() {
+	s.AtomsInContact = make(AtomsOnCenters, len(s.AtomsOnFCenters))
+	for i := range s.AtomsInContact {
+		s.AtomsInContact[i] = random.NewSet(0)
+	}
+}
+
+type AtomsOnCenters []*random.Set
 
 func (a AtomsOnCenters) Len() int {
 	total := 0
@@ -48,12 +61,33 @@ func (a AtomsOnCenters) Len() int {
 	return total
 }
 
+// MemoryUsage returns the number of bytes allocated for the ids of the atoms.
+func (a AtomsOnCenters) MemoryUsage() int {
+	bytes := 0
+	for _, set := range a {
+		bytes += set.MemoryUsage()
+	}
+	return bytes
+}
+
 type Atom struct {
 	Id             int
 	X              uint32
 	Y              uint32
 	OccupiedCentre rune
-	ElementName    string
+	// Index of the element in the config
+	Element int
+	Track
+}
+
+// Track is what an atom went through since its adsorption. It follows the atom across its hops.
+type Track struct {
+	// Physical time the atom was adsorbed at and the type of the center it was adsorbed on
+	AdsorbedAt float64
+	AdsorbedOn rune
+	// Displacement of the atom from the cell it was adsorbed on, summed over its hops
+	DX int32
+	DY int32
 }
 
 func (a *Atom) ChangePosition(x uint32, y uint32, center rune) {
@@ -63,83 +97,210 @@ func (a *Atom) ChangePosition(x uint32, y uint32, center rune) {
 }
 
 func (s *SurfaceAtomsController) AddAtomOnSurface(atom Atom) {
-	atom.Id = s.IdGenerator.Generate()
-	s.AtomsOnSurface[atom.Id] = atom
-
-	switch atom.OccupiedCentre {
-	case 'F':
-		s.AtomsOnFCenters[atom.ElementName].Add(atom.Id, atom)
-	case 'S':
-		s.AtomsOnSCenters[atom.ElementName].Add(atom.Id, atom)
+	if n := len(s.freeIds); n > 0 {
+		atom.Id = s.freeIds[n-1]
+		s.freeIds = s.freeIds[:n-1]
+		s.atoms[atom.Id] = atom
+	} else {
+		atom.Id = len(s.atoms)
+		s.atoms = append(s.atoms, atom)
 	}
+	s.count++
+
+	s.centerAtoms(atom.OccupiedCentre)[atom.Element].Add(atom.Id)
 
 	s.matrix.SetAtomOnCell(atom.X, atom.Y, atom.Id)
+	if s.AtomsInContact != nil {
+		s.enter(atom)
+	}
+}
+
+// Coordinates is a pair of cell coordinates on the matrix.
+type Coordinates struct {
+	X uint32
+	Y uint32
+}
+
+// Atom returns the atom with the given id.
+func (s *SurfaceAtomsController) Atom(id int) Atom {
+	return s.atoms[id]
+}
+
+// Count returns the number of atoms on the surface.
+func (s *SurfaceAtomsController) Count() int {
+	return s.count
+}
+
+// MemoryUsage returns the number of bytes allocated for the atoms and the indexes of their ids. As the
+// store of the atoms never shrinks, it is the most the atoms have taken so far.
+func (s *SurfaceAtomsController) MemoryUsage() int {
+	bytes := int(unsafe.Sizeof(Atom{}))*cap(s.atoms) + 8*cap(s.freeIds) + cap(s.occupiedNeighbours)
+	bytes += s.AtomsOnFCenters.MemoryUsage() + s.AtomsOnSCenters.MemoryUsage()
+	if s.AtomsInContact != nil {
+		bytes += s.AtomsInContact.MemoryUsage()
+	}
+	return bytes
+}
+
+// Atoms returns the atoms on the surface in the order of their ids.
+func (s *SurfaceAtomsController) Atoms() []Atom {
+	atoms := make([]Atom, 0, s.count)
+	for _, atom := range s.atoms[1:] {
+		if atom.Id != 0 {
+			atoms = append(atoms, atom)
+		}
+	}
+	return atoms
+}
+
+// RandomContactAtom returns a uniformly chosen atom of AtomsInContact of the element, or false if there is none.
+func (s *SurfaceAtomsController) RandomContactAtom(element int, r *random.Rand) (Atom, bool) {
+	id, ok := s.AtomsInContact[element].Random(r)
+	if !ok {
+		return Atom{}, false
+	}
+	return s.atoms[id], true
+}
+
+// RandomAtom returns a uniformly chosen atom of the element on the center, or false if there is none.
+func (s *SurfaceAtomsController) RandomAtom(center rune, element int, r *random.Rand) (Atom, bool) {
+	id, ok := s.centerAtoms(center)[element].Random(r)
+	if !ok {
+		return Atom{}, false
+	}
+	return s.atoms[id], true
 }
 
-func (s *SurfaceAtomsController) GetNextAtomCoordinates(atomId int) (x, y uint32) {
-	movement := map[int32]struct {
+func (s *SurfaceAtomsController) centerAtoms(center rune) AtomsOnCenters {
+	if center == 'S' {
+		return s.AtomsOnSCenters
+	}
+	return s.AtomsOnFCenters
+}
+
+// GetNeighbourCoordinates returns the cells adjacent to the atom that lie within
+// the matrix, in random order, and their number. The first one is a uniformly chosen hop target.
+func (s *SurfaceAtomsController) GetNeighbourCoordinates(atom Atom, r *random.Rand) ([4]Coordinates, int) {
+	neighbours, count := s.neighbours(atom.X, atom.Y)
+
+	for i := count - 1; i > 0; i-- {
+		j := r.Int(i + 1)
+		neighbours[i], neighbours[j] = neighbours[j], neighbours[i]
+	}
+
+	return neighbours, count
+}
+
+// neighbours returns the cells adjacent to the cell (x, y) that lie within the matrix, and their number.
+func (s *SurfaceAtomsController) neighbours(x, y uint32) ([4]Coordinates, int) {
+	movement := [4]struct {
 		x int32
 		y int32
 	}{
-		1: {-1, 0},
-		2: {0, 1},
-		3: {1, 0},
-		4: {0, -1},
+		{-1, 0},
+		{0, 1},
+		{1, 0},
+		{0, -1},
 	}
-	var (
-		nextX, nextY uint32 = 0, 0
-	)
 
-	atom := s.AtomsOnSurface[atomId]
-
-	positionNotFound := true
-	for positionNotFound {
-		randomDirection, _ := rand.Int(rand.Reader, big.NewInt(4))
-		randomInt := int32(randomDirection.Int64() + 1)
-		possibleX := int32(atom.X) + movement[randomInt].x
-		possibleY := int32(atom.Y) + movement[randomInt].y
+	var neighbours [4]Coordinates
+	count := 0
+	for _, direction := range movement {
+		possibleX := int32(x) + direction.x
+		possibleY := int32(y) + direction.y
 
 		if (0 <= possibleX && possibleX < int32(s.MatrixLimitX)) &&
 			(0 <= possibleY && possibleY < int32(s.MatrixLimitY)) {
-			nextX, nextY = uint32(possibleX), uint32(possibleY)
-			positionNotFound = false
+			neighbours[count] = Coordinates{X: uint32(possibleX), Y: uint32(possibleY)}
+			count++
 		}
 	}
 
-	return nextX, nextY
+	return neighbours, count
 }
 
 func (s *SurfaceAtomsController) RemoveAtomFromSurface(atomId int) {
-	atom := s.AtomsOnSurface[atomId]
+	atom := s.atoms[atomId]
 
-	switch atom.OccupiedCentre {
-	case 'F':
-		s.AtomsOnFCenters[atom.ElementName].Remove(atomId)
-	case 'S':
-		s.AtomsOnSCenters[atom.ElementName].Remove(atomId)
-	}
+	s.centerAtoms(atom.OccupiedCentre)[atom.Element].Remove(atomId)
 
-	delete(s.AtomsOnSurface, atomId)
+	s.atoms[atomId] = Atom{}
+	s.freeIds = append(s.freeIds, atomId)
+	s.count--
 	s.matrix.ClearCell(atom.X, atom.Y)
+	if s.AtomsInContact != nil {
+		s.leave(atom)
+	}
 }
 
 func (s *SurfaceAtomsController) MoveAtom(atom Atom, nextCell CellData) {
-	switch atom.OccupiedCentre {
-	case 'F':
-		s.AtomsOnFCenters[atom.ElementName].Remove(atom.Id)
-	case 'S':
-		s.AtomsOnSCenters[atom.ElementName].Remove(atom.Id)
+	if nextCell.Center != atom.OccupiedCentre {
+		s.centerAtoms(atom.OccupiedCentre)[atom.Element].Remove(atom.Id)
+		s.centerAtoms(nextCell.Center)[atom.Element].Add(atom.Id)
 	}
 
 	s.matrix.ClearCell(atom.X, atom.Y)
+	if s.AtomsInContact != nil {
+		s.leave(atom)
+	}
+	atom.DX += int32(nextCell.X) - int32(atom.X)
+	atom.DY += int32(nextCell.Y) - int32(atom.Y)
 	atom.ChangePosition(nextCell.X, nextCell.Y, nextCell.Center)
-	s.AtomsOnSurface[atom.Id] = atom
+	s.atoms[atom.Id] = atom
 	s.matrix.SetAtomOnCell(nextCell.X, nextCell.Y, atom.Id)
+	if s.AtomsInContact != nil {
+		s.enter(atom)
+	}
+}
+
+// enter counts the atom, just placed on its cell, as an occupied neighbour of the atoms around it.
+func (s *SurfaceAtomsController) enter(atom Atom) {
+	if missing := len(s.atoms) - len(s.occupiedNeighbours); missing > 0 {
+		s.occupiedNeighbours = append(s.occupiedNeighbours, make([]uint8, missing)...)
+	}
+
+	count := uint8(0)
+	neighbours, n := s.neighbours(atom.X, atom.Y)
+	for _, next := range neighbours[:n] {
+		if atomId := s.matrix.AtomAt(next.X, next.Y); atomId != 0 {
+			count++
+			s.changeContacts(atomId, 1)
+		}
+	}
 
-	switch nextCell.Center {
-	case 'F':
-		s.AtomsOnFCenters[atom.ElementName].Add(atom.Id, atom)
-	case 'S':
-		s.AtomsOnSCenters[atom.ElementName].Add(atom.Id, atom)
+	s.occupiedNeighbours[atom.Id] = count
+	if count > 0 && atom.OccupiedCentre == 'F' {
+		s.AtomsInContact[atom.Element].Add(atom.Id)
+	}
+}
+
+// leave stops counting the atom, just taken from its cell, as an occupied neighbour of the atoms around it.
+func (s *SurfaceAtomsController) leave(atom Atom) {
+	neighbours, n := s.neighbours(atom.X, atom.Y)
+	for _, next := range neighbours[:n] {
+		if atomId := s.matrix.AtomAt(next.X, next.Y); atomId != 0 {
+			s.changeContacts(atomId, -1)
+		}
+	}
+
+	if s.occupiedNeighbours[atom.Id] > 0 && atom.OccupiedCentre == 'F' {
+		s.AtomsInContact[atom.Element].Remove(atom.Id)
+	}
+	s.occupiedNeighbours[atom.Id] = 0
+}
+
+func (s *SurfaceAtomsController) changeContacts(atomId int, delta int) {
+	before := s.occupiedNeighbours[atomId]
+	after := uint8(int(before) + delta)
+	s.occupiedNeighbours[atomId] = after
+
+	atom := s.atoms[atomId]
+	if atom.OccupiedCentre != 'F' {
+		return
+	}
+	if before == 0 && after > 0 {
+		s.AtomsInContact[atom.Element].Add(atomId)
+	} else if before > 0 && after == 0 {
+		s.AtomsInContact[atom.Element].Remove(atomId)
 	}
 }
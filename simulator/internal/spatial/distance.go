@@ -0,0 +1,86 @@
+package spatial
+
+import (
+	"github.com/zipliZ/surface-atoms/simulator/internal/occupancy"
+	"math"
+)
+
+// sCenterDistances returns the Euclidean distance of every cell of the map to the nearest S-center,
+// +Inf when there is none, with the exact distance transform of Felzenszwalb and Huttenlocher:
+// the squared distances along the columns, then along the rows.
+func sCenterDistances(m occupancy.Map) []float64 {
+	squared := make([]float64, len(m.Cells))
+	for cell, state := range m.Cells {
+		// S-centers have odd states, free or holding an atom
+		if state%2 == 0 {
+			squared[cell] = math.Inf(1)
+		}
+	}
+
+	column := make([]float64, m.LenY)
+	transformed := make([]float64, max(m.LenX, m.LenY))
+	for x := range m.LenX {
+		for y := range m.LenY {
+			column[y] = squared[y*m.LenX+x]
+		}
+		transform1D(column, transformed)
+		for y := range m.LenY {
+			squared[y*m.LenX+x] = transformed[y]
+		}
+	}
+	for y := range m.LenY {
+		row := squared[y*m.LenX : (y+1)*m.LenX]
+		transform1D(row, transformed)
+		copy(row, transformed[:m.LenX])
+	}
+
+	for cell, value := range squared {
+		squared[cell] = math.Sqrt(value)
+	}
+	return squared
+}
+
+// transform1D writes to out the lower envelope of the parabolas (i-q)² + f(q): the squared distance
+// transform of f along a line.
+func transform1D(f []float64, out []float64) {
+	n := len(f)
+	// Positions of the parabolas of the envelope and the boundaries between them
+	vertices := make([]int, 0, n)
+	boundaries := make([]float64, 0, n+1)
+	for q := range n {
+		if math.IsInf(f[q], 1) {
+			continue
+		}
+		for len(vertices) > 0 {
+			v := vertices[len(vertices)-1]
+			s := ((f[q] + float64(q*q)) - (f[v] + float64(v*v))) / float64(2*(q-v))
+			if s > boundaries[len(boundaries)-1] {
+				boundaries = append(boundaries, s)
+				break
+			}
+			vertices = vertices[:len(vertices)-1]
+			boundaries = boundaries[:len(boundaries)-1]
+		}
+		if len(vertices) == 0 {
+			boundaries = append(boundaries, math.Inf(-1))
+		}
+		vertices = append(vertices, q)
+	}
+
+	if len(vertices) == 0 {
+		for i := range n {
+			out[i] = math.Inf(1)
+		}
+		return
+	}
+
+	boundaries = append(boundaries, math.Inf(1))
+	k := 0
+	for i := range n {
+		for boundaries[k+1] < float64(i) {
+			k++
+		}
+		v := vertices[k]
+		out[i] = float64((i-v)*(i-v)) + f[v]
+	}
+}
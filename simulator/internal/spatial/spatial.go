@@ -0,0 +1,244 @@
+package spatial
+
+import (
+	"github.com/zipliZ/surface-atoms/simulator/internal/occupancy"
+	"math"
+)
+
+// Analyzer computes the spatial statistics of the atoms on an occupancy map of a lattice of fixed size:
+// the sizes of the clusters of atoms, the radial pair correlation function of every pair of elements and
+// the distances of the atoms to the nearest S-center. Cells are adjacent through their sides, as for hops,
+// and the lattice has no periodic boundaries. Distances are Euclidean, in cells, and binned by binWidth
+// up to maxDistance.
+type Analyzer struct {
+	lenX     int
+	lenY     int
+	elements int
+	binWidth float64
+	bins     int
+	// Offsets of the cells within maxDistance of a cell and their distance bins
+	offsets []offset
+	// Number of ordered pairs of cells of the lattice in every distance bin
+	sitePairs []float64
+	// Distance of every cell to the nearest S-center, computed from the first map
+	sDistances []float64
+	// Cluster labels of the cells and the cells left to visit, reused between maps
+	labels []int32
+	stack  []int
+}
+
+type offset struct {
+	dx  int
+	dy  int
+	bin int
+}
+
+// Statistics are the spatial statistics of a map.
+type Statistics struct {
+	// Per group (the elements in order, then all atoms): the number of clusters of every size
+	Clusters []map[int]int
+	// Per pair of elements (0-0, 0-1, ..., 1-1, ...): g(r) of every distance bin, NaN when the pair
+	// cannot be found in the bin
+	PairCorrelation [][]float64
+	// Per element: the number of atoms in every distance bin to the nearest S-center, and in the last one
+	// those beyond maxDistance
+	SDistances [][]int
+	// Per element: the mean distance of its atoms to the nearest S-center, NaN without atoms or S-centers
+	MeanSDistance []float64
+}
+
+// NewAnalyzer returns an analyzer of maps of lenX×lenY cells holding atoms of the given number of elements.
+func NewAnalyzer(lenX, lenY, elements int, maxDistance, binWidth float64) *Analyzer {
+	a := &Analyzer{
+		lenX:     lenX,
+		lenY:     lenY,
+		elements: elements,
+		binWidth: binWidth,
+		bins:     int(math.Ceil(maxDistance / binWidth)),
+		labels:   make([]int32, lenX*lenY),
+	}
+
+	a.sitePairs = make([]float64, a.bins)
+	reach := int(maxDistance)
+	for dy := -reach; dy <= reach; dy++ {
+		for dx := -reach; dx <= reach; dx++ {
+			distance := math.Hypot(float64(dx), float64(dy))
+			if (dx == 0 && dy == 0) || distance >= maxDistance || abs(dx) >= lenX || abs(dy) >= lenY {
+				continue
+			}
+			bin := int(distance / binWidth)
+			a.offsets = append(a.offsets, offset{dx: dx, dy: dy, bin: bin})
+			a.sitePairs[bin] += float64((lenX - abs(dx)) * (lenY - abs(dy)))
+		}
+	}
+	return a
+}
+
+// Bins returns the number of distance bins, the last bin of the S-center distances excluded.
+func (a *Analyzer) Bins() int {
+	return a.bins
+}
+
+// BinStart returns the smallest distance of the bin.
+func (a *Analyzer) BinStart(bin int) float64 {
+	return float64(bin) * a.binWidth
+}
+
+// Pairs returns the pairs of elements of the pair correlation, in the order of Statistics.PairCorrelation.
+func Pairs(elements int) [][2]int {
+	var pairs [][2]int
+	for first := range elements {
+		for second := first; second < elements; second++ {
+			pairs = append(pairs, [2]int{first, second})
+		}
+	}
+	return pairs
+}
+
+// Analyze computes the statistics of the map.
+func (a *Analyzer) Analyze(m occupancy.Map) Statistics {
+	if a.sDistances == nil {
+		a.sDistances = sCenterDistances(m)
+	}
+
+	atoms := make([][]int, a.elements)
+	for cell, state := range m.Cells {
+		if element, ok := elementOf(state); ok {
+			atoms[element] = append(atoms[element], cell)
+		}
+	}
+
+	return Statistics{
+		Clusters:        a.clusters(m),
+		PairCorrelation: a.pairCorrelation(m, atoms),
+		SDistances:      a.sDistanceHistogram(atoms),
+		MeanSDistance:   a.meanSDistances(atoms),
+	}
+}
+
+// clusters labels the connected components of the atoms of every element and of all atoms together.
+func (a *Analyzer) clusters(m occupancy.Map) []map[int]int {
+	clusters := make([]map[int]int, a.elements+1)
+	for group := range clusters {
+		clusters[group] = make(map[int]int)
+		inGroup := func(state uint8) bool {
+			element, ok := elementOf(state)
+			return ok && (group == a.elements || element == group)
+		}
+
+		clear(a.labels)
+		label := int32(0)
+		for start, state := range m.Cells {
+			if a.labels[start] != 0 || !inGroup(state) {
+				continue
+			}
+
+			label++
+			a.labels[start] = label
+			a.stack = append(a.stack[:0], start)
+			size := 0
+			for len(a.stack) > 0 {
+				cell := a.stack[len(a.stack)-1]
+				a.stack = a.stack[:len(a.stack)-1]
+				size++
+
+				x, y := cell%a.lenX, cell/a.lenX
+				for _, next := range [4][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+					if next[0] < 0 || next[0] >= a.lenX || next[1] < 0 || next[1] >= a.lenY {
+						continue
+					}
+					neighbour := next[1]*a.lenX + next[0]
+					if a.labels[neighbour] == 0 && inGroup(m.Cells[neighbour]) {
+						a.labels[neighbour] = label
+						a.stack = append(a.stack, neighbour)
+					}
+				}
+			}
+			clusters[group][size]++
+		}
+	}
+	return clusters
+}
+
+// pairCorrelation counts the ordered pairs of atoms in every distance bin and divides them by the number
+// expected when the atoms are placed at random on the lattice, which accounts for its edges.
+func (a *Analyzer) pairCorrelation(m occupancy.Map, atoms [][]int) [][]float64 {
+	sites := float64(a.lenX * a.lenY)
+	pairs := Pairs(a.elements)
+	correlation := make([][]float64, len(pairs))
+	for i, pair := range pairs {
+		first, second := pair[0], pair[1]
+		counts := make([]float64, a.bins)
+		for _, cell := range atoms[first] {
+			x, y := cell%a.lenX, cell/a.lenX
+			for _, o := range a.offsets {
+				nx, ny := x+o.dx, y+o.dy
+				if nx < 0 || nx >= a.lenX || ny < 0 || ny >= a.lenY {
+					continue
+				}
+				if element, ok := elementOf(m.Cells[ny*a.lenX+nx]); ok && element == second {
+					counts[o.bin]++
+				}
+			}
+		}
+
+		others := float64(len(atoms[second]))
+		if first == second {
+			others--
+		}
+		correlation[i] = make([]float64, a.bins)
+		for bin, count := range counts {
+			expected := a.sitePairs[bin] * float64(len(atoms[first])) * others / (sites * (sites - 1))
+			correlation[i][bin] = math.NaN()
+			if expected > 0 {
+				correlation[i][bin] = count / expected
+			}
+		}
+	}
+	return correlation
+}
+
+func (a *Analyzer) sDistanceHistogram(atoms [][]int) [][]int {
+	histogram := make([][]int, a.elements)
+	for element, cells := range atoms {
+		histogram[element] = make([]int, a.bins+1)
+		for _, cell := range cells {
+			bin := a.bins
+			if distance := a.sDistances[cell]; distance < float64(a.bins)*a.binWidth {
+				bin = int(distance / a.binWidth)
+			}
+			histogram[element][bin]++
+		}
+	}
+	return histogram
+}
+
+func (a *Analyzer) meanSDistances(atoms [][]int) []float64 {
+	means := make([]float64, a.elements)
+	for element, cells := range atoms {
+		sum := 0.0
+		for _, cell := range cells {
+			sum += a.sDistances[cell]
+		}
+		means[element] = sum / float64(len(cells))
+		if len(cells) == 0 || math.IsInf(sum, 1) {
+			means[element] = math.NaN()
+		}
+	}
+	return means
+}
+
+// elementOf returns the index of the element of the atom in a cell in the state, false for a free cell.
+func elementOf(state uint8) (int, bool) {
+	if state < occupancy.AtomOnF {
+		return 0, false
+	}
+	return int(state-occupancy.AtomOnF) / 2, true
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,91 @@
+package spatial
+
+import (
+	"math"
+	"math/rand/v2"
+	"reflect"
+	"testing"
+
+	"github.com/zipliZ/surface-atoms/simulator/internal/occupancy"
+)
+
+func TestAnalyzeClusters(t *testing.T) {
+	m := occupancy.NewMap(0, 5, 4)
+	m.Set(0, 0, occupancy.AtomState(0, 'F'))
+	m.Set(1, 0, occupancy.AtomState(0, 'S'))
+	m.Set(2, 0, occupancy.AtomState(1, 'F'))
+	m.Set(3, 3, occupancy.AtomState(0, 'F'))
+	// Diagonal cells are not adjacent
+	m.Set(4, 2, occupancy.AtomState(1, 'F'))
+	m.Set(4, 0, occupancy.FreeS)
+
+	stats := NewAnalyzer(5, 4, 2, 3, 1).Analyze(m)
+	want := []map[int]int{
+		{2: 1, 1: 1},
+		{1: 2},
+		{3: 1, 1: 2},
+	}
+	if !reflect.DeepEqual(stats.Clusters, want) {
+		t.Errorf("clusters %v, want %v", stats.Clusters, want)
+	}
+
+	// S-centers at (1, 0) and (4, 0)
+	wantMeans := []float64{(1 + 0 + math.Hypot(1, 3)) / 3, (1 + 2) / 2.0}
+	for element, mean := range stats.MeanSDistance {
+		if math.Abs(mean-wantMeans[element]) > 1e-12 {
+			t.Errorf("element %d mean S distance %g, want %g", element, mean, wantMeans[element])
+		}
+	}
+	// Bins [0, 1), [1, 2), [2, 3) and beyond
+	wantHistogram := [][]int{{1, 1, 0, 1}, {0, 1, 1, 0}}
+	if !reflect.DeepEqual(stats.SDistances, wantHistogram) {
+		t.Errorf("S distance histogram %v, want %v", stats.SDistances, wantHistogram)
+	}
+}
+
+func TestPairCorrelationOfFullLattice(t *testing.T) {
+	m := occupancy.NewMap(0, 6, 5)
+	for i := range m.Cells {
+		m.Cells[i] = occupancy.AtomOnF
+	}
+
+	a := NewAnalyzer(6, 5, 1, 4, 0.5)
+	stats := a.Analyze(m)
+	// Without S-centers every distance is infinite
+	if !math.IsNaN(stats.MeanSDistance[0]) || stats.SDistances[0][a.Bins()] != len(m.Cells) {
+		t.Errorf("mean S distance %g and histogram %v without S-centers", stats.MeanSDistance[0], stats.SDistances[0])
+	}
+	// A full lattice is as correlated as a random one; bins without a lattice distance in them have NaN
+	for bin, g := range stats.PairCorrelation[0] {
+		if !math.IsNaN(g) && math.Abs(g-1) > 1e-12 {
+			t.Errorf("g(%g) = %g, want 1", a.BinStart(bin), g)
+		}
+	}
+	if math.IsNaN(stats.PairCorrelation[0][2]) {
+		t.Error("g(1) is NaN")
+	}
+}
+
+func TestSCenterDistances(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	m := occupancy.NewMap(0, 17, 11)
+	for i := range m.Cells {
+		if r.IntN(15) == 0 {
+			m.Cells[i] = occupancy.FreeS
+		}
+	}
+	m.Set(3, 4, occupancy.AtomState(2, 'S'))
+
+	distances := sCenterDistances(m)
+	for cell := range m.Cells {
+		want := math.Inf(1)
+		for other, state := range m.Cells {
+			if state%2 == 1 {
+				want = min(want, math.Hypot(float64(cell%m.LenX-other%m.LenX), float64(cell/m.LenX-other/m.LenX)))
+			}
+		}
+		if math.Abs(distances[cell]-want) > 1e-12 {
+			t.Fatalf("cell (%d, %d) is %g from an S-center, want %g", cell%m.LenX, cell/m.LenX, distances[cell], want)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+// Package kmc runs the surface kinetic Monte Carlo simulation as a library.
+//
+// A run is set up with functional options and started with Run:
+//
+//	cfg, _ := configs.New()
+//	sim, err := kmc.New(
+//		kmc.WithConfig(cfg),
+//		kmc.WithTemperature(600),
+//		kmc.WithDuration(1e-5),
+//		kmc.WithSeed(42),
+//		kmc.WithoutFiles(),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	result, err := sim.Run(ctx)
+//
+// Without WithoutFiles, the run writes its result directory like the command line simulator.
+package kmc
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+
+	"github.com/zipliZ/surface-atoms/simulator/configs"
+	"github.com/zipliZ/surface-atoms/simulator/internal/progress"
+	"github.com/zipliZ/surface-atoms/simulator/internal/simulation"
+)
+
+// Stop reasons of a Result.
+const (
+	StopCompleted        = simulation.StopCompleted
+	StopQuasiSteadyState = simulation.StopQuasiSteadyState
+	StopInterrupted      = simulation.StopInterrupted
+	StopError            = simulation.StopError
+)
+
+type (
+	// Result summarises a finished run: final info per element, formed molecules, steady state and timings.
+	Result = simulation.Result
+	// Info holds the counters and coverages of an element or of the whole surface.
+	Info = simulation.Info
+	// SteadyState tells whether and when the quasi-steady state was detected.
+	SteadyState = simulation.SteadyState
+	// Acceleration is the scaling of the hop rate of an element by the diffusion acceleration.
+	Acceleration = simulation.Acceleration
+	// Residence is the residence time of the atoms of an element that left the surface through an exit channel.
+	Residence = simulation.Residence
+	// Diffusion is the tracer diffusion of the atoms of an element on the surface at the end of a run.
+	Diffusion = simulation.Diffusion
+	// ProcessBudget is the share of a process of an element in the events and the wall time of a run.
+	ProcessBudget = simulation.ProcessBudget
+
+	// Observer watches a run from inside the simulation loop; see WithObserver.
+	Observer = simulation.Observer
+	// BaseObserver implements Observer with callbacks that do nothing; embed it to implement only some of them.
+	BaseObserver = simulation.BaseObserver
+	// Event is an executed kMC event.
+	Event = simulation.Event
+	// Snapshot is the state written as a row of the results table.
+	Snapshot = simulation.Snapshot
+	// Coordinates of a lattice cell.
+	Coordinates = simulation.Coordinates
+)
+
+// Outcomes of an Event.
+const (
+	OutcomeAdsorbed        = simulation.OutcomeAdsorbed
+	OutcomeDesorbed        = simulation.OutcomeDesorbed
+	OutcomeRecombEr        = simulation.OutcomeRecombEr
+	OutcomeHopped          = simulation.OutcomeHopped
+	OutcomeRecombLhF       = simulation.OutcomeRecombLhF
+	OutcomeRecombLhS       = simulation.OutcomeRecombLhS
+	OutcomeBlockedRejected = simulation.OutcomeBlockedRejected
+	OutcomeBlockedDesorbed = simulation.OutcomeBlockedDesorbed
+	OutcomeNone            = simulation.OutcomeNone
+)
+
+// Sink receives the rows of the results table: the column names first, then one row per snapshot.
+type Sink interface {
+	WriteHeader(headers []string) error
+	WriteRow(values []float64) error
+	Close() error
+}
+
+// Option configures a Simulator.
+type Option func(*options)
+
+type options struct {
+	cfg            *configs.Config
+	temperature    int
+	duration       float64
+	seed           uint64
+	source         rand.Source
+	domains        *int
+	outputDir      *string
+	outputs        []string
+	noFiles        bool
+	sinks          []Sink
+	observers      []Observer
+	progressOutput string
+}
+
+// WithConfig sets the configuration. The config loaded by configs.New is used when omitted.
+func WithConfig(cfg configs.Config) Option {
+	return func(o *options) {
+		o.cfg = &cfg
+	}
+}
+
+// WithTemperature sets the surface temperature in Kelvin.
+func WithTemperature(temperature int) Option {
+	return func(o *options) {
+		o.temperature = temperature
+	}
+}
+
+// WithDuration sets the physical time to simulate in seconds.
+func WithDuration(seconds float64) Option {
+	return func(o *options) {
+		o.duration = seconds
+	}
+}
+
+// WithSeed seeds the random number generator, overriding the seed of the config. Runs with the same
+// seed and config are identical, also when other runs are in progress in the process.
+func WithSeed(seed uint64) Option {
+	return func(o *options) {
+		o.seed = seed
+	}
+}
+
+// WithRandSource draws the random numbers of the run from src instead of a generator seeded from the
+// config or WithSeed, e.g. to replay a recorded sequence in a test. The run reads src from a single
+// goroutine; the seed of its result is 0.
+func WithRandSource(src rand.Source) Option {
+	return func(o *options) {
+		o.source = src
+	}
+}
+
+// WithDomains runs the simulation in parallel mode, with the lattice split into the given number of domains
+// simulated by separate goroutines. 0 or 1 runs the serial algorithm.
+func WithDomains(domains int) Option {
+	return func(o *options) {
+		o.domains = &domains
+	}
+}
+
+// WithOutputDir sets the directory the result directory is created in.
+func WithOutputDir(dir string) Option {
+	return func(o *options) {
+		o.outputDir = &dir
+	}
+}
+
+// WithOutputs sets the formats of the results table: "xlsx", "csv", "jsonl", "sqlite".
+func WithOutputs(formats ...string) Option {
+	return func(o *options) {
+		o.outputs = formats
+	}
+}
+
+// WithoutFiles keeps the run in memory: no result directory, tables, plot or run.json are written.
+func WithoutFiles() Option {
+	return func(o *options) {
+		o.noFiles = true
+	}
+}
+
+// WithSink adds a sink receiving the rows of the results table, with or without files.
+func WithSink(sink Sink) Option {
+	return func(o *options) {
+		o.sinks = append(o.sinks, sink)
+	}
+}
+
+// WithObserver registers an observer called from the simulation loop for every event, snapshot,
+// the quasi-steady state and the end of the run. Runs without observers do not pay for them.
+// In parallel mode OnEvent is not called.
+func WithObserver(observer Observer) Option {
+	return func(o *options) {
+		o.observers = append(o.observers, observer)
+	}
+}
+
+// WithProgress writes JSON-lines progress reports to a file, or to the standard output with "stdout".
+func WithProgress(output string) Option {
+	return func(o *options) {
+		o.progressOutput = output
+	}
+}
+
+// Simulator is a prepared run.
+type Simulator struct {
+	simulator *simulation.Simulator
+	ran       bool
+}
+
+// New prepares a run. Temperature and duration are required.
+func New(opts ...Option) (*Simulator, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.cfg == nil {
+		cfg, err := configs.New()
+		if err != nil {
+			return nil, err
+		}
+		o.cfg = &cfg
+	}
+	cfg := *o.cfg
+
+	if o.seed != 0 {
+		cfg.Simulating.Seed = o.seed
+	}
+	if o.domains != nil {
+		cfg.Simulating.Parallel.Domains = *o.domains
+	}
+	if o.outputDir != nil {
+		cfg.Simulating.OutputDir = *o.outputDir
+	}
+	if o.outputs != nil {
+		cfg.Simulating.Outputs = o.outputs
+	}
+	// A library does not draw on the terminal unless the config asks for it explicitly
+	if cfg.Simulating.Progress.Bar != progress.BarOn {
+		cfg.Simulating.Progress.Bar = progress.BarOff
+	}
+	cfg.Simulating.Progress.Output = o.progressOutput
+
+	simulatorOptions := make([]simulation.Option, 0, len(o.sinks)+len(o.observers)+2)
+	if o.noFiles {
+		simulatorOptions = append(simulatorOptions, simulation.WithoutFiles())
+	}
+	for _, sink := range o.sinks {
+		simulatorOptions = append(simulatorOptions, simulation.WithWriters(sinkWriter{sink}))
+	}
+	if o.source != nil {
+		simulatorOptions = append(simulatorOptions, simulation.WithRandSource(o.source))
+	}
+	for _, observer := range o.observers {
+		simulatorOptions = append(simulatorOptions, simulation.WithObserver(observer))
+	}
+
+	simulator, err := simulation.NewSimulator(cfg, o.temperature, o.duration, simulatorOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Simulator{simulator: simulator}, nil
+}
+
+// ResultDir returns the directory the results are written to, empty with WithoutFiles.
+func (s *Simulator) ResultDir() string {
+	return s.simulator.ResultDir()
+}
+
+// Run simulates until the duration is reached, the quasi-steady state is detected or ctx is done.
+// A cancelled run stops after the current event, writes its partial results, is recorded as
+// interrupted and returns its result together with the context error; a run that ended before ctx
+// was done returns no error. A Simulator runs once.
+func (s *Simulator) Run(ctx context.Context) (Result, error) {
+	if s.ran {
+		return Result{}, errors.New("simulator has already run")
+	}
+	s.ran = true
+
+	if err := s.simulator.Simulate(ctx); err != nil {
+		return s.simulator.Result(), err
+	}
+
+	result := s.simulator.Result()
+	if result.StopReason == StopInterrupted {
+		return result, ctx.Err()
+	}
+	return result, nil
+}
+
+// sinkWriter adapts a Sink to the writers of the results table.
+type sinkWriter struct {
+	Sink
+}
+
+func (w sinkWriter) Path() string {
+	return ""
+}
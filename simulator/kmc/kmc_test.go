@@ -0,0 +1,147 @@
+package kmc_test
+
+import (
+	"context"
+	"math/rand/v2"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/zipliZ/surface-atoms/simulator/configs"
+	"github.com/zipliZ/surface-atoms/simulator/kmc"
+)
+
+// recorder keeps what a run reported to its observer.
+type recorder struct {
+	kmc.BaseObserver
+	events    int
+	snapshots []kmc.Snapshot
+	result    *kmc.Result
+}
+
+func (r *recorder) OnEvent(kmc.Event) { r.events++ }
+
+func (r *recorder) OnSnapshot(snapshot kmc.Snapshot) {
+	r.snapshots = append(r.snapshots, snapshot)
+}
+
+func (r *recorder) OnEnd(result kmc.Result) { r.result = &result }
+
+// testConfig builds a small run of the bundled silica presets from overrides alone.
+func testConfig(t *testing.T) configs.Config {
+	t.Helper()
+	cfg, _, err := configs.Load(configs.Sources{Set: []string{
+		"simulating.matrixLenX=40",
+		"simulating.matrixLenY=40",
+		"simulating.logPercent=1",
+		"simulating.floatPrecision=8",
+		"simulating.outputDir=" + t.TempDir(),
+		"consts.fDensity=1.5e15",
+		"consts.fi=0.002",
+		"consts.sDensity=3e12",
+		`elements=[{preset: "N@silica"}, {preset: "O@silica"}]`,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func runInMemory(t *testing.T, cfg configs.Config, src rand.Source) *recorder {
+	t.Helper()
+	observer := &recorder{}
+	sim, err := kmc.New(
+		kmc.WithConfig(cfg),
+		kmc.WithTemperature(600),
+		kmc.WithDuration(1e-4),
+		kmc.WithRandSource(src),
+		kmc.WithoutFiles(),
+		kmc.WithObserver(observer),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir := sim.ResultDir(); dir != "" {
+		t.Fatalf("ResultDir() = %q without files", dir)
+	}
+
+	result, err := sim.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if observer.result == nil {
+		t.Fatal("OnEnd was not called")
+	}
+	if !reflect.DeepEqual(observer.result.Total, result.Total) {
+		t.Errorf("OnEnd total %+v, Run returned %+v", observer.result.Total, result.Total)
+	}
+	if result.StopReason != kmc.StopCompleted {
+		t.Errorf("StopReason = %q, want %q", result.StopReason, kmc.StopCompleted)
+	}
+	if result.Seed != 0 {
+		t.Errorf("Seed = %d with a supplied source, want 0", result.Seed)
+	}
+	return observer
+}
+
+func TestRunInMemory(t *testing.T) {
+	cfg := testConfig(t)
+
+	first := runInMemory(t, cfg, rand.NewPCG(1, 2))
+	if first.events == 0 || first.result.Events != int64(first.events) {
+		t.Fatalf("observer saw %d events, result has %d", first.events, first.result.Events)
+	}
+	if len(first.snapshots) == 0 {
+		t.Fatal("no snapshots were reported")
+	}
+	last := first.snapshots[len(first.snapshots)-1]
+	if !reflect.DeepEqual(last.Total, first.result.Total) {
+		t.Errorf("last snapshot %+v differs from the result %+v", last.Total, first.result.Total)
+	}
+
+	// The same source replays the same run
+	second := runInMemory(t, cfg, rand.NewPCG(1, 2))
+	if !reflect.DeepEqual(first.snapshots, second.snapshots) {
+		t.Error("runs with the same source reported different snapshots")
+	}
+
+	entries, err := os.ReadDir(cfg.Simulating.OutputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("the run wrote %d entries to the output directory", len(entries))
+	}
+}
+
+// cancelOnEnd cancels the context of the run as soon as it has ended.
+type cancelOnEnd struct {
+	kmc.BaseObserver
+	cancel context.CancelFunc
+}
+
+func (c cancelOnEnd) OnEnd(kmc.Result) { c.cancel() }
+
+func TestRunCancelledAfterEnd(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sim, err := kmc.New(
+		kmc.WithConfig(testConfig(t)),
+		kmc.WithTemperature(600),
+		kmc.WithDuration(1e-5),
+		kmc.WithSeed(1),
+		kmc.WithoutFiles(),
+		kmc.WithObserver(cancelOnEnd{cancel: cancel}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := sim.Run(ctx)
+	if err != nil {
+		t.Errorf("a run completed before the cancellation returned %v", err)
+	}
+	if result.StopReason != kmc.StopCompleted || ctx.Err() == nil {
+		t.Errorf("StopReason = %q with the context error %v", result.StopReason, ctx.Err())
+	}
+}
@@ -1,15 +1,25 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"github.com/zipliZ/surface-atoms/simulator/configs"
+	"github.com/zipliZ/surface-atoms/simulator/internal/server"
+	"github.com/zipliZ/surface-atoms/simulator/internal/simulation"
 	"log"
 	"log/slog"
-	"main/configs"
-	"main/internal/simulation"
+	"net/http"
 	"os"
+	"os/signal"
 	"runtime/debug"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 )
 
 func main() {
@@ -22,7 +32,43 @@ func main() {
 		}
 	}()
 
-	cfg, err := configs.New()
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err := runConfig(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		if err := runBench(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "check-parallel" {
+		if err := runCheckParallel(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		if err := runReplay(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		if err := runServe(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	var cfgFlags configFlags
+	cfgFlags.register(flag.CommandLine)
+	resume := flag.String("resume", "", "continue an interrupted run from its checkpoint.json.gz")
+	flag.Parse()
+
+	cfg, _, err := configs.Load(cfgFlags.sources())
 	if err != nil {
 		log.Panic(err)
 	}
@@ -30,14 +76,27 @@ func main() {
 	var temperature int
 	var simulationTime float64
 
-	args := os.Args
-	if len(args) == 3 {
-		temperature, err = strconv.Atoi(args[1])
+	var simulatorOptions []simulation.Option
+	if *resume != "" {
+		checkpoint, err := simulation.ReadCheckpoint(*resume)
+		if err != nil {
+			log.Fatal(err)
+		}
+		simulatorOptions = append(simulatorOptions, simulation.WithCheckpoint(checkpoint))
+		temperature, simulationTime = checkpoint.Temperature, checkpoint.SimulationTime
+	}
+
+	args := flag.Args()
+	batch := len(args) == 2 || *resume != ""
+	if *resume != "" {
+		slog.SetLogLoggerLevel(slog.LevelError)
+	} else if batch {
+		temperature, err = strconv.Atoi(args[0])
 		if err != nil {
 			log.Fatal(err) // nolint
 		}
-		args[2] = strings.ReplaceAll(args[2], "_", "")
-		simulationTime, err = strconv.ParseFloat(args[2], 64)
+		args[1] = strings.ReplaceAll(args[1], "_", "")
+		simulationTime, err = strconv.ParseFloat(args[1], 64)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -50,17 +109,134 @@ func main() {
 		fmt.Scanln(&simulationTime)
 	}
 
-	simulator, err := simulation.NewSimulator(cfg, temperature, simulationTime)
+	simulator, err := simulation.NewSimulator(cfg, temperature, simulationTime, simulatorOptions...)
 	if err != nil {
 		log.Fatal(err)
 	}
-	if err = simulator.Simulate(); err != nil {
+
+	// Ctrl+C stops the run with partial results only while it simulates, not at the prompts
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err = simulator.Simulate(ctx)
+	stop()
+	if err != nil {
 		log.Fatal(err)
 	}
+	result := simulator.Result()
+	if result.Budget != nil {
+		if err = printBudget(result.Budget); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if result.StopReason == simulation.StopInterrupted {
+		fmt.Printf("Interrupted at %g s, partial results are in %s\n", result.PhysicalTime, result.ResultDir)
+	}
 
-	if len(args) != 3 {
+	if !batch {
 		// Wait for Enter key press before exiting
 		fmt.Println("Press Enter to exit...")
 		fmt.Scanln() // Waits for Enter key press
 	}
 }
+
+// printBudget prints the share of every process of every element in the events and the wall time of the run,
+// the process with the most events first.
+func printBudget(budget map[string]map[string]simulation.ProcessBudget) error {
+	type row struct {
+		element string
+		process string
+		simulation.ProcessBudget
+	}
+	var rows []row
+	for element, processes := range budget {
+		for process, processBudget := range processes {
+			rows = append(rows, row{element: element, process: process, ProcessBudget: processBudget})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Events != rows[j].Events {
+			return rows[i].Events > rows[j].Events
+		}
+		return rows[i].element+rows[i].process < rows[j].element+rows[j].process
+	})
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "element\tprocess\tevents\tevents %\tmean lambda\twall time s\twall time %\t")
+	for _, r := range rows {
+		fmt.Fprintf(table, "%s\t%s\t%d\t%.2f\t%.4g\t%.3f\t%.2f\t\n", r.element, r.process, r.Events,
+			100*r.EventShare, r.MeanLambda, r.WallTime, 100*r.WallTimeShare)
+	}
+	return table.Flush()
+}
+
+// runConfig handles "config print": it shows the merged config with the origin of every value.
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errors.New("usage: config print [-config file] [-overlay file]... [-set key=value]...")
+	}
+
+	fs := flag.NewFlagSet("config print", flag.ExitOnError)
+	var cfgFlags configFlags
+	cfgFlags.register(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	cfg, origins, err := configs.Load(cfgFlags.sources())
+	if err != nil {
+		return err
+	}
+
+	return configs.Print(os.Stdout, cfg, origins)
+}
+
+// runServe handles "serve": it runs submitted jobs from an HTTP API until SIGINT or SIGTERM.
+// Job configs are applied over the config given by the config flags.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	var cfgFlags configFlags
+	cfgFlags.register(fs)
+	addr := fs.String("addr", "127.0.0.1:8080", "address to listen on")
+	dir := fs.String("jobs", "jobs", "directory of the job queue and results")
+	workers := fs.Int("workers", 1, "number of jobs run at the same time")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// The server config is checked once at start-up
+	if _, _, err := configs.Load(cfgFlags.sources()); err != nil {
+		return err
+	}
+
+	queue, err := server.NewQueue(*dir, cfgFlags.sources(), *workers)
+	if err != nil {
+		return err
+	}
+
+	httpServer := &http.Server{Addr: *addr, Handler: server.Handler(queue)}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("serving", "addr", *addr, "jobs", *dir, "workers", *workers)
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serveErr:
+		queue.Close()
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down")
+	queue.Close()
+
+	// Progress streams of stopped jobs do not end by themselves
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = httpServer.Shutdown(shutdownCtx); err != nil {
+		return httpServer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/zipliZ/surface-atoms/simulator/internal/simulation"
+	"math"
+	"os"
+	"text/tabwriter"
+)
+
+// runReplay handles "replay": it rebuilds the surface of a run written with the event log at a physical time
+// and prints the atoms on it. It can list the events on the way and save the surface as a checkpoint.
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	until := fs.Float64("time", math.Inf(1), "physical time to replay to, the end of the log when omitted")
+	list := fs.Bool("list", false, "print every event applied")
+	listFrom := fs.Float64("from", 0, "physical time -list starts at")
+	output := fs.String("o", "", "save the surface as a checkpoint, to be continued with -resume")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: replay [-time t] [-list [-from t]] [-o checkpoint.json.gz] <result directory>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("replay needs the result directory of a run written with simulating.eventLog")
+	}
+
+	replay, err := simulation.OpenReplay(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer replay.Close()
+
+	var visit func(simulation.LoggedEvent)
+	if *list {
+		visit = func(event simulation.LoggedEvent) {
+			if event.PhysicalTime >= *listFrom {
+				printEvent(event)
+			}
+		}
+	}
+	more, err := replay.Advance(*until, visit)
+	if err != nil {
+		return err
+	}
+	if !more && !math.IsInf(*until, 1) && replay.PhysicalTime() < *until {
+		fmt.Printf("the event log ends at %g s\n", replay.PhysicalTime())
+	}
+
+	checkpoint := replay.Checkpoint()
+	if err = printSurface(checkpoint); err != nil {
+		return err
+	}
+
+	if *output != "" {
+		return checkpoint.Write(*output)
+	}
+	return nil
+}
+
+func printEvent(event simulation.LoggedEvent) {
+	fmt.Printf("%d\t%g\t%s\t%s\t%s\t(%d, %d)", event.Number, event.PhysicalTime, event.Process, event.Element,
+		event.Outcome, event.From.X, event.From.Y)
+	if event.To != event.From {
+		fmt.Printf(" -> (%d, %d)", event.To.X, event.To.Y)
+	}
+	if event.Partner != "" {
+		fmt.Printf("\twith %s", event.Partner)
+	}
+	fmt.Println()
+}
+
+// printSurface prints the physical time of the checkpoint and its atoms by element.
+func printSurface(checkpoint simulation.Checkpoint) error {
+	fmt.Printf("physical time %g s after %d events\n", checkpoint.PhysicalTime, checkpoint.Events)
+
+	counts := make(map[string]int, len(checkpoint.Elements))
+	for _, atom := range checkpoint.Atoms {
+		counts[atom.Element]++
+	}
+
+	sites := float64(checkpoint.MatrixLenX) * float64(checkpoint.MatrixLenY)
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "element\tatoms\tcoverage\t")
+	for _, element := range checkpoint.Elements {
+		fmt.Fprintf(table, "%s\t%d\t%.6g\t\n", element, counts[element], float64(counts[element])/sites)
+	}
+	fmt.Fprintf(table, "total\t%d\t%.6g\t\n", len(checkpoint.Atoms), float64(len(checkpoint.Atoms))/sites)
+	return table.Flush()
+}